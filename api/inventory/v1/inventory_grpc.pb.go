@@ -0,0 +1,474 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v25.3.0
+// source: inventory/v1/inventory.proto
+
+package inventoryv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Inventory_AddGood_FullMethodName          = "/inventory.v1.Inventory/AddGood"
+	Inventory_GetGood_FullMethodName          = "/inventory.v1.Inventory/GetGood"
+	Inventory_ListGoods_FullMethodName        = "/inventory.v1.Inventory/ListGoods"
+	Inventory_AddStorage_FullMethodName       = "/inventory.v1.Inventory/AddStorage"
+	Inventory_ListStorages_FullMethodName     = "/inventory.v1.Inventory/ListStorages"
+	Inventory_SetStorageAccess_FullMethodName = "/inventory.v1.Inventory/SetStorageAccess"
+	Inventory_Reserve_FullMethodName          = "/inventory.v1.Inventory/Reserve"
+	Inventory_Release_FullMethodName          = "/inventory.v1.Inventory/Release"
+	Inventory_Remains_FullMethodName          = "/inventory.v1.Inventory/Remains"
+	Inventory_StreamRemains_FullMethodName    = "/inventory.v1.Inventory/StreamRemains"
+)
+
+// InventoryClient is the client API for Inventory service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type InventoryClient interface {
+	AddGood(ctx context.Context, in *AddGoodRequest, opts ...grpc.CallOption) (*AddGoodResponse, error)
+	GetGood(ctx context.Context, in *GetGoodRequest, opts ...grpc.CallOption) (*GoodStock, error)
+	ListGoods(ctx context.Context, in *ListGoodsRequest, opts ...grpc.CallOption) (*ListGoodsResponse, error)
+	AddStorage(ctx context.Context, in *AddStorageRequest, opts ...grpc.CallOption) (*AddStorageResponse, error)
+	ListStorages(ctx context.Context, in *ListStoragesRequest, opts ...grpc.CallOption) (*ListStoragesResponse, error)
+	SetStorageAccess(ctx context.Context, in *SetStorageAccessRequest, opts ...grpc.CallOption) (*SetStorageAccessResponse, error)
+	Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*ReserveResponse, error)
+	Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*Reservation, error)
+	// Remains returns one page of free goods on available storages.
+	Remains(ctx context.Context, in *RemainsRequest, opts ...grpc.CallOption) (*RemainsResponse, error)
+	// StreamRemains pages through all remains matching the request, starting from its cursor.
+	StreamRemains(ctx context.Context, in *RemainsRequest, opts ...grpc.CallOption) (Inventory_StreamRemainsClient, error)
+}
+
+type inventoryClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInventoryClient(cc grpc.ClientConnInterface) InventoryClient {
+	return &inventoryClient{cc}
+}
+
+func (c *inventoryClient) AddGood(ctx context.Context, in *AddGoodRequest, opts ...grpc.CallOption) (*AddGoodResponse, error) {
+	out := new(AddGoodResponse)
+	err := c.cc.Invoke(ctx, Inventory_AddGood_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) GetGood(ctx context.Context, in *GetGoodRequest, opts ...grpc.CallOption) (*GoodStock, error) {
+	out := new(GoodStock)
+	err := c.cc.Invoke(ctx, Inventory_GetGood_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) ListGoods(ctx context.Context, in *ListGoodsRequest, opts ...grpc.CallOption) (*ListGoodsResponse, error) {
+	out := new(ListGoodsResponse)
+	err := c.cc.Invoke(ctx, Inventory_ListGoods_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) AddStorage(ctx context.Context, in *AddStorageRequest, opts ...grpc.CallOption) (*AddStorageResponse, error) {
+	out := new(AddStorageResponse)
+	err := c.cc.Invoke(ctx, Inventory_AddStorage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) ListStorages(ctx context.Context, in *ListStoragesRequest, opts ...grpc.CallOption) (*ListStoragesResponse, error) {
+	out := new(ListStoragesResponse)
+	err := c.cc.Invoke(ctx, Inventory_ListStorages_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) SetStorageAccess(ctx context.Context, in *SetStorageAccessRequest, opts ...grpc.CallOption) (*SetStorageAccessResponse, error) {
+	out := new(SetStorageAccessResponse)
+	err := c.cc.Invoke(ctx, Inventory_SetStorageAccess_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*ReserveResponse, error) {
+	out := new(ReserveResponse)
+	err := c.cc.Invoke(ctx, Inventory_Reserve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*Reservation, error) {
+	out := new(Reservation)
+	err := c.cc.Invoke(ctx, Inventory_Release_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) Remains(ctx context.Context, in *RemainsRequest, opts ...grpc.CallOption) (*RemainsResponse, error) {
+	out := new(RemainsResponse)
+	err := c.cc.Invoke(ctx, Inventory_Remains_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryClient) StreamRemains(ctx context.Context, in *RemainsRequest, opts ...grpc.CallOption) (Inventory_StreamRemainsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Inventory_ServiceDesc.Streams[0], Inventory_StreamRemains_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryStreamRemainsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Inventory_StreamRemainsClient interface {
+	Recv() (*GoodRemains, error)
+	grpc.ClientStream
+}
+
+type inventoryStreamRemainsClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryStreamRemainsClient) Recv() (*GoodRemains, error) {
+	m := new(GoodRemains)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// InventoryServer is the server API for Inventory service.
+// All implementations must embed UnimplementedInventoryServer
+// for forward compatibility
+type InventoryServer interface {
+	AddGood(context.Context, *AddGoodRequest) (*AddGoodResponse, error)
+	GetGood(context.Context, *GetGoodRequest) (*GoodStock, error)
+	ListGoods(context.Context, *ListGoodsRequest) (*ListGoodsResponse, error)
+	AddStorage(context.Context, *AddStorageRequest) (*AddStorageResponse, error)
+	ListStorages(context.Context, *ListStoragesRequest) (*ListStoragesResponse, error)
+	SetStorageAccess(context.Context, *SetStorageAccessRequest) (*SetStorageAccessResponse, error)
+	Reserve(context.Context, *ReserveRequest) (*ReserveResponse, error)
+	Release(context.Context, *ReleaseRequest) (*Reservation, error)
+	// Remains returns one page of free goods on available storages.
+	Remains(context.Context, *RemainsRequest) (*RemainsResponse, error)
+	// StreamRemains pages through all remains matching the request, starting from its cursor.
+	StreamRemains(*RemainsRequest, Inventory_StreamRemainsServer) error
+	mustEmbedUnimplementedInventoryServer()
+}
+
+// UnimplementedInventoryServer must be embedded to have forward compatible implementations.
+type UnimplementedInventoryServer struct {
+}
+
+func (UnimplementedInventoryServer) AddGood(context.Context, *AddGoodRequest) (*AddGoodResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddGood not implemented")
+}
+func (UnimplementedInventoryServer) GetGood(context.Context, *GetGoodRequest) (*GoodStock, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGood not implemented")
+}
+func (UnimplementedInventoryServer) ListGoods(context.Context, *ListGoodsRequest) (*ListGoodsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGoods not implemented")
+}
+func (UnimplementedInventoryServer) AddStorage(context.Context, *AddStorageRequest) (*AddStorageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddStorage not implemented")
+}
+func (UnimplementedInventoryServer) ListStorages(context.Context, *ListStoragesRequest) (*ListStoragesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStorages not implemented")
+}
+func (UnimplementedInventoryServer) SetStorageAccess(context.Context, *SetStorageAccessRequest) (*SetStorageAccessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetStorageAccess not implemented")
+}
+func (UnimplementedInventoryServer) Reserve(context.Context, *ReserveRequest) (*ReserveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reserve not implemented")
+}
+func (UnimplementedInventoryServer) Release(context.Context, *ReleaseRequest) (*Reservation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Release not implemented")
+}
+func (UnimplementedInventoryServer) Remains(context.Context, *RemainsRequest) (*RemainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remains not implemented")
+}
+func (UnimplementedInventoryServer) StreamRemains(*RemainsRequest, Inventory_StreamRemainsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamRemains not implemented")
+}
+func (UnimplementedInventoryServer) mustEmbedUnimplementedInventoryServer() {}
+
+// UnsafeInventoryServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InventoryServer will
+// result in compilation errors.
+type UnsafeInventoryServer interface {
+	mustEmbedUnimplementedInventoryServer()
+}
+
+func RegisterInventoryServer(s grpc.ServiceRegistrar, srv InventoryServer) {
+	s.RegisterService(&Inventory_ServiceDesc, srv)
+}
+
+func _Inventory_AddGood_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddGoodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).AddGood(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_AddGood_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).AddGood(ctx, req.(*AddGoodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_GetGood_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGoodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).GetGood(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_GetGood_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).GetGood(ctx, req.(*GetGoodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_ListGoods_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGoodsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).ListGoods(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_ListGoods_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).ListGoods(ctx, req.(*ListGoodsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_AddStorage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddStorageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).AddStorage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_AddStorage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).AddStorage(ctx, req.(*AddStorageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_ListStorages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStoragesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).ListStorages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_ListStorages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).ListStorages(ctx, req.(*ListStoragesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_SetStorageAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetStorageAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).SetStorageAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_SetStorageAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).SetStorageAccess(ctx, req.(*SetStorageAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_Reserve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).Reserve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_Reserve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).Reserve(ctx, req.(*ReserveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_Release_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).Release(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_Release_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).Release(ctx, req.(*ReleaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_Remains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServer).Remains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inventory_Remains_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServer).Remains(ctx, req.(*RemainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inventory_StreamRemains_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RemainsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServer).StreamRemains(m, &inventoryStreamRemainsServer{stream})
+}
+
+type Inventory_StreamRemainsServer interface {
+	Send(*GoodRemains) error
+	grpc.ServerStream
+}
+
+type inventoryStreamRemainsServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryStreamRemainsServer) Send(m *GoodRemains) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Inventory_ServiceDesc is the grpc.ServiceDesc for Inventory service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Inventory_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "inventory.v1.Inventory",
+	HandlerType: (*InventoryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddGood",
+			Handler:    _Inventory_AddGood_Handler,
+		},
+		{
+			MethodName: "GetGood",
+			Handler:    _Inventory_GetGood_Handler,
+		},
+		{
+			MethodName: "ListGoods",
+			Handler:    _Inventory_ListGoods_Handler,
+		},
+		{
+			MethodName: "AddStorage",
+			Handler:    _Inventory_AddStorage_Handler,
+		},
+		{
+			MethodName: "ListStorages",
+			Handler:    _Inventory_ListStorages_Handler,
+		},
+		{
+			MethodName: "SetStorageAccess",
+			Handler:    _Inventory_SetStorageAccess_Handler,
+		},
+		{
+			MethodName: "Reserve",
+			Handler:    _Inventory_Reserve_Handler,
+		},
+		{
+			MethodName: "Release",
+			Handler:    _Inventory_Release_Handler,
+		},
+		{
+			MethodName: "Remains",
+			Handler:    _Inventory_Remains_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamRemains",
+			Handler:       _Inventory_StreamRemains_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "inventory/v1/inventory.proto",
+}
@@ -1,10 +1,67 @@
 package goods
 
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
 type Good struct {
-	Id       int    `json:"id"`
-	Name     string `json:"name"`
-	Size     string `json:"size"`
-	UniqCode int    `json:"uniq_code"`
+	Id        int        `json:"id"`
+	Name      string     `json:"name"`
+	Size      string     `json:"size"`
+	UniqCode  int        `json:"uniq_code"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Sizes is the size vocabulary of goods, sizes are stored upper case.
+var Sizes = []string{"XXS", "XS", "S", "M", "L", "XL", "XXL", "XXXL", "ONESIZE"}
+
+// NormalizeSize brings a size sent by a client to the stored form.
+func NormalizeSize(size string) string {
+	return strings.ToUpper(strings.TrimSpace(size))
+}
+
+func ValidSize(size string) bool {
+	return slices.Contains(Sizes, NormalizeSize(size))
+}
+
+// Filter narrows goods lists, zero fields are not applied.
+// StorageId and MinAvailable are applied to remains only.
+type Filter struct {
+	Name           string // substring of the name
+	Size           string
+	StorageId      int
+	MinAvailable   int  // free goods summed over the available storages
+	IncludeDeleted bool // list soft deleted goods too
+}
+
+// Update is a partial change of a good, nil fields are left as they are.
+type Update struct {
+	Name *string
+	Size *string
+}
+
+func (u Update) Empty() bool {
+	return u.Name == nil && u.Size == nil
+}
+
+// Stock is a good with its remains summed over all storages and broken down by storage.
+// Available counts only free goods on available storages, that is what can be reserved right now.
+type Stock struct {
+	Good
+	Count     int            `json:"count"`
+	Reserved  int            `json:"reserved"`
+	Available int            `json:"available"`
+	Storages  []StorageStock `json:"storages"`
+}
+
+type StorageStock struct {
+	StorageId        int  `json:"storage_id"`
+	StorageAvailable bool `json:"storage_available"`
+	Count            int  `json:"count"`
+	Reserved         int  `json:"reserved"`
+	Available        int  `json:"available"` // zero on unavailable storages
 }
 
 type RemainsDTO struct {
@@ -15,11 +72,35 @@ type RemainsDTO struct {
 
 type ReservedDTO struct {
 	UniqCode       int              `json:"uniq_code"`
+	ReservationId  int64            `json:"reservation_id,omitempty"`
+	Status         string           `json:"status,omitempty"`
+	CreatedAt      *time.Time       `json:"created_at,omitempty"`
+	ExpiresAt      *time.Time       `json:"expires_at,omitempty"`
 	Storages       []map[string]int `json:"storages"`
+	ErrorCode      string           `json:"error_code,omitempty"`
 	AdditionalInfo string           `json:"additional_info,omitempty"`
 }
 
 type ReleasedDTO struct {
+	ReservationId  int64  `json:"reservation_id"`
+	UniqCode       int    `json:"uniq_code,omitempty"`
+	ErrorCode      string `json:"error_code,omitempty"`
+	AdditionalInfo string `json:"additional_info,omitempty"`
+}
+
+type ShippedDTO struct {
+	ReservationId  int64            `json:"reservation_id"`
+	ShipmentId     int64            `json:"shipment_id,omitempty"`
+	UniqCode       int              `json:"uniq_code,omitempty"`
+	Storages       []map[string]int `json:"storages"`
+	ErrorCode      string           `json:"error_code,omitempty"`
+	AdditionalInfo string           `json:"additional_info,omitempty"`
+}
+
+type ReceivedDTO struct {
 	UniqCode       int    `json:"uniq_code"`
+	StorageId      int    `json:"storage_id"`
+	Count          int    `json:"count"`
+	ErrorCode      string `json:"error_code,omitempty"`
 	AdditionalInfo string `json:"additional_info,omitempty"`
 }
@@ -0,0 +1,26 @@
+package movements
+
+import "time"
+
+// Types of stock movements written to the ledger.
+const (
+	TypeReceive     = "receive"
+	TypeReserve     = "reserve"
+	TypeRelease     = "release"
+	TypeExpire      = "expire"
+	TypeShip        = "ship"
+	TypeTransferOut = "transfer_out"
+	TypeTransferIn  = "transfer_in"
+)
+
+// Movement is a single change of `count` and/or `reserved` of one good on one storage.
+type Movement struct {
+	ID            int64     `json:"id"`
+	UniqCode      int       `json:"uniq_code"`
+	StorageId     int       `json:"storage_id"`
+	Type          string    `json:"type"`
+	CountDelta    int       `json:"count_delta"`
+	ReservedDelta int       `json:"reserved_delta"`
+	CorrelationId string    `json:"correlation_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
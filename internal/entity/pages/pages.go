@@ -0,0 +1,14 @@
+package pages
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Query asks for one page of a list. Cursor is the `next_cursor` of the previous page,
+// Sort is a field name with an optional "-" prefix for descending order.
+type Query struct {
+	Cursor string `form:"cursor" json:"cursor"`
+	Limit  int    `form:"limit" json:"limit" binding:"min=0,max=1000"`
+	Sort   string `form:"sort" json:"sort"`
+}
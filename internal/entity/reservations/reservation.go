@@ -0,0 +1,39 @@
+package reservations
+
+import "time"
+
+const (
+	StatusActive   = "active"
+	StatusReleased = "released"
+	StatusExpired  = "expired"
+	StatusShipped  = "shipped"
+)
+
+type Line struct {
+	StorageId int `json:"storage"`
+	Count     int `json:"count"`
+}
+
+type Reservation struct {
+	ID        int64      `json:"id"`
+	UniqCode  int        `json:"uniq_code"`
+	Lines     []Line     `json:"lines"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Status    string     `json:"status"`
+}
+
+type Request struct {
+	UniqCode int
+	Count    int
+	TTL      time.Duration
+	Storages []int // pinned storages, empty means the registry allocation strategy chooses them
+}
+
+type Shipment struct {
+	ID            int64     `json:"id"`
+	ReservationID int64     `json:"reservation_id"`
+	UniqCode      int       `json:"uniq_code"`
+	Lines         []Line    `json:"lines"`
+	CreatedAt     time.Time `json:"created_at"`
+}
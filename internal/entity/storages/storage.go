@@ -1,8 +1,44 @@
 package storages
 
 type Storage struct {
-	ID           uint64 `json:"id"`
-	Name         string `json:"name"`
-	RawAvailable string `json:"-"`
-	Available    bool   `json:"available"`
+	ID           uint64            `json:"id"`
+	Name         string            `json:"name"`
+	RawAvailable string            `json:"-"`
+	Available    bool              `json:"available"`
+	Address      string            `json:"address"`
+	Region       string            `json:"region"`
+	Priority     int               `json:"priority"` // storages with higher priority are drained first by the priority allocation
+	Capacity     int               `json:"capacity"` // zero means the capacity is unknown
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+// Remains is the stock of one good on a storage.
+type Remains struct {
+	UniqCode int `json:"uniq_code"`
+	Count    int `json:"count"`
+	Reserved int `json:"reserved"`
+	// History is set for an empty note that reservation or shipment lines still point to.
+	History bool `json:"history,omitempty"`
+}
+
+// Filter narrows the storage list, zero fields are not applied.
+type Filter struct {
+	Name      string // substring of the name
+	Available *bool
+}
+
+// Update is a partial change of a storage, nil fields are left as they are.
+type Update struct {
+	Name      *string
+	Available *bool
+	Address   *string
+	Region    *string
+	Priority  *int
+	Capacity  *int
+	Tags      map[string]string // replaces all tags of the storage
+}
+
+func (u Update) Empty() bool {
+	return u.Name == nil && u.Available == nil && u.Address == nil && u.Region == nil &&
+		u.Priority == nil && u.Capacity == nil && u.Tags == nil
 }
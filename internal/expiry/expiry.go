@@ -0,0 +1,46 @@
+package expiry
+
+import (
+	"LamodaTest/internal/registry"
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type Worker struct {
+	registry registry.Db
+	log      logrus.FieldLogger
+	interval time.Duration
+}
+
+func NewWorker(registry registry.Db, log logrus.FieldLogger, interval time.Duration) *Worker {
+	return &Worker{registry: registry, log: log, interval: interval}
+}
+
+// Run sweeps expired reservations every interval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep expires reservations once, movements of the sweep share the `expiry-<unix time>` correlation ID.
+func (w *Worker) Sweep(ctx context.Context) {
+	now := time.Now().UTC()
+	ctx = registry.WithCorrelationID(ctx, fmt.Sprintf("expiry-%d", now.Unix()))
+	expired, err := w.registry.ExpireReservations(ctx, now)
+	if err != nil {
+		w.log.Errorf("can't expire reservations: %s", err.Error())
+	}
+	if expired > 0 {
+		w.log.Infof("expired %d reservations", expired)
+	}
+}
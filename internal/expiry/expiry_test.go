@@ -0,0 +1,70 @@
+package expiry
+
+import (
+	"LamodaTest/internal/logger"
+	"LamodaTest/internal/registry"
+	mock_registry "LamodaTest/internal/registry/mocks"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestWorker_Sweep(t *testing.T) {
+	l := logger.New(false)
+	tests := []struct {
+		name    string
+		expired int
+		err     error
+	}{
+		{
+			name:    "normal",
+			expired: 2,
+		}, {
+			name:    "nothing to expire",
+			expired: 0,
+		}, {
+			name:    "err from db",
+			expired: 1,
+			err:     errors.New("test"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mock_registry.NewMockDb(ctrl)
+			m.EXPECT().ExpireReservations(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, now time.Time) (int, error) {
+				if id := registry.CorrelationID(ctx); id != fmt.Sprintf("expiry-%d", now.Unix()) {
+					t.Errorf("Sweep() correlation id = %q", id)
+				}
+				return tt.expired, tt.err
+			}).Times(1)
+			w := NewWorker(m, l, time.Minute)
+			w.Sweep(context.Background())
+		})
+	}
+}
+
+func TestWorker_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mock_registry.NewMockDb(ctrl)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.EXPECT().ExpireReservations(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, time.Time) (int, error) {
+		cancel()
+		return 0, nil
+	}).MinTimes(1)
+	w := NewWorker(m, logger.New(false), time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() didn't stop after context cancel")
+	}
+}
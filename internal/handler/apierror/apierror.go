@@ -0,0 +1,126 @@
+package apierror
+
+import (
+	"LamodaTest/internal/registry"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Machine-readable codes returned in `error_code` next to the HTTP status.
+const (
+	CodeInvalidJSON     = "invalid_json"
+	CodeInvalidQuery    = "invalid_query"
+	CodeInvalidParam    = "invalid_param"
+	CodeNothingToUpdate = "nothing_to_update"
+
+	CodeRouteNotFound    = "route_not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+
+	CodeGoodNotFound        = "good_not_found"
+	CodeStorageNotFound     = "storage_not_found"
+	CodeReservationNotFound = "reservation_not_found"
+
+	CodeDuplicateUniqCode  = "duplicate_uniq_code"
+	CodeConflict           = "conflict"
+	CodeGoodReserved       = "good_reserved"
+	CodeStorageNotEmpty    = "storage_not_empty"
+	CodeStorageUnavailable = "storage_unavailable"
+	CodeReservationClosed  = "reservation_closed"
+
+	CodeInsufficientStock = "insufficient_stock"
+	CodeExceedsReserved   = "exceeds_reserved"
+	CodeInvalidArgument   = "invalid_argument"
+
+	CodeInternal = "internal_error"
+)
+
+// Error is what the client gets instead of the registry error.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// mapping is checked in order, so more specific errors must go first.
+var mapping = []struct {
+	err error
+	Error
+}{
+	{registry.ErrGoodNotFound, Error{http.StatusNotFound, CodeGoodNotFound, "Good not found"}},
+	{registry.ErrStorageNotFound, Error{http.StatusNotFound, CodeStorageNotFound, "Storage not found"}},
+	{registry.ErrReservationNotFound, Error{http.StatusNotFound, CodeReservationNotFound, "Reservation not found"}},
+	{registry.ErrDuplicateUniqCode, Error{http.StatusConflict, CodeDuplicateUniqCode, "Good with this uniq_code already exists"}},
+	{registry.ErrConflict, Error{http.StatusConflict, CodeConflict, "Conflicting concurrent change, retry the request"}},
+	{registry.ErrGoodReserved, Error{http.StatusConflict, CodeGoodReserved, "Good has active reservations"}},
+	{registry.ErrStorageNotEmpty, Error{http.StatusConflict, CodeStorageNotEmpty, "Storage still holds goods, pass migrate_to to move them"}},
+	{registry.ErrStorageUnavailable, Error{http.StatusConflict, CodeStorageUnavailable, "Storage is not available"}},
+	{registry.ErrReservationClosed, Error{http.StatusConflict, CodeReservationClosed, "Reservation is not active"}},
+	{registry.ErrInsufficientStock, Error{http.StatusUnprocessableEntity, CodeInsufficientStock, "Not enough goods on available storages"}},
+	{registry.ErrExceedsReserved, Error{http.StatusUnprocessableEntity, CodeExceedsReserved, "Quantity exceeds reserved"}},
+	{registry.ErrInvalidArgument, Error{http.StatusUnprocessableEntity, CodeInvalidArgument, "Invalid argument"}},
+	{registry.ErrInvalidQuery, Error{http.StatusBadRequest, CodeInvalidQuery, "Invalid query"}},
+}
+
+// From maps a registry error to the API error, unknown errors are internal and described by fallback.
+func From(err error, fallback string) Error {
+	for _, m := range mapping {
+		if errors.Is(err, m.err) {
+			return m.Error
+		}
+	}
+	return Error{http.StatusInternalServerError, CodeInternal, fallback}
+}
+
+// Internal reports whether the error is not caused by the request.
+func (e Error) Internal() bool {
+	return e.Status >= http.StatusInternalServerError
+}
+
+// Respond logs err and writes it to the client as From maps it.
+func Respond(c *gin.Context, log logrus.FieldLogger, err error, fallback string) {
+	RespondWith(c, log, err, From(err, fallback), nil)
+}
+
+// RespondWith logs err and writes e to the client, data is added to the body when it isn't nil.
+func RespondWith(c *gin.Context, log logrus.FieldLogger, err error, e Error, data any) {
+	log = log.WithField("route", c.FullPath())
+	if e.Internal() {
+		log.Error(err)
+	} else {
+		log.Warn(err)
+	}
+	Write(c, e, data)
+}
+
+// BadRequest writes a 400 for a request that can't be parsed, the caller logs err.
+// Problem documents list the fields err points at.
+func BadRequest(c *gin.Context, err error, code string, message string) {
+	write(c, Error{http.StatusBadRequest, code, message}, Fields(err), nil)
+}
+
+// Write sends e as a problem document when the client accepts one, otherwise in the envelope
+// of every response: `code`, `error_code`, `message`, optional `errors` of fields and `data`.
+func Write(c *gin.Context, e Error, data any) {
+	write(c, e, nil, data)
+}
+
+func write(c *gin.Context, e Error, fields []FieldError, data any) {
+	if wantsProblem(c) {
+		writeProblem(c, e, fields, data)
+		return
+	}
+	body := gin.H{
+		"code":       e.Status,
+		"error_code": e.Code,
+		"message":    e.Message,
+	}
+	if len(fields) > 0 {
+		body["errors"] = fields
+	}
+	if data != nil {
+		body["data"] = data
+	}
+	c.JSON(e.Status, body)
+}
@@ -0,0 +1,51 @@
+package apierror
+
+import (
+	"LamodaTest/internal/registry"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Error
+	}{
+		{
+			name: "wrapped not found",
+			err:  fmt.Errorf("can't get stock of good %d: %w", 1, registry.ErrGoodNotFound),
+			want: Error{http.StatusNotFound, CodeGoodNotFound, "Good not found"},
+		}, {
+			name: "duplicate uniq_code",
+			err:  fmt.Errorf("can't add good: %w", registry.ErrDuplicateUniqCode),
+			want: Error{http.StatusConflict, CodeDuplicateUniqCode, "Good with this uniq_code already exists"},
+		}, {
+			name: "retries exhausted",
+			err:  fmt.Errorf("%w: %w", registry.ErrConflict, errors.New("deadlock")),
+			want: Error{http.StatusConflict, CodeConflict, "Conflicting concurrent change, retry the request"},
+		}, {
+			name: "insufficient stock",
+			err:  fmt.Errorf("can't reserve: %w", registry.ErrInsufficientStock),
+			want: Error{http.StatusUnprocessableEntity, CodeInsufficientStock, "Not enough goods on available storages"},
+		}, {
+			name: "storage not empty",
+			err:  &registry.StorageNotEmptyError{StorageId: 1},
+			want: Error{http.StatusConflict, CodeStorageNotEmpty, "Storage still holds goods, pass migrate_to to move them"},
+		}, {
+			name: "unknown error",
+			err:  errors.New("connection refused"),
+			want: Error{http.StatusInternalServerError, CodeInternal, "Not added"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := From(tt.err, "Not added")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.Status == http.StatusInternalServerError, got.Internal())
+		})
+	}
+}
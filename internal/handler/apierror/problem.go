@@ -0,0 +1,174 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details, clients opt in to it with the Accept header.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes `error_code` to build the `type` URI of a problem document.
+const ProblemTypeBase = "/problems/"
+
+// Problem is an RFC 7807 problem document, Code and Data are extension members.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"error_code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	Data     any          `json:"data,omitempty"`
+}
+
+// FieldError tells which field of the request failed validation and why.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// titles are the same for every occurrence of the problem, `detail` carries the message of the occurrence.
+var titles = map[string]string{
+	CodeInvalidJSON:      "Invalid JSON",
+	CodeInvalidQuery:     "Invalid query",
+	CodeInvalidParam:     "Invalid path parameter",
+	CodeNothingToUpdate:  "Nothing to update",
+	CodeValidationFailed: "Validation failed",
+	CodeRouteNotFound:    "Page not found",
+	CodeMethodNotAllowed: "Method not allowed",
+	CodeInternal:         "Internal server error",
+}
+
+func init() {
+	for _, m := range mapping {
+		titles[m.Code] = m.Message
+	}
+	// report fields the way clients send them instead of Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(requestName)
+	}
+}
+
+func requestName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// wantsProblem reports whether the client asked for problem documents, the legacy envelope is the default.
+func wantsProblem(c *gin.Context) bool {
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != ProblemContentType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func writeProblem(c *gin.Context, e Error, fields []FieldError, data any) {
+	title, ok := titles[e.Code]
+	if !ok {
+		title = http.StatusText(e.Status)
+	}
+	problem := Problem{
+		Type:     ProblemTypeBase + e.Code,
+		Title:    title,
+		Status:   e.Status,
+		Detail:   e.Message,
+		Instance: c.Request.URL.RequestURI(),
+		Code:     e.Code,
+		Errors:   fields,
+		Data:     data,
+	}
+	body, err := json.Marshal(problem)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(e.Status, ProblemContentType, body)
+}
+
+// Fields lists the fields of a request that failed binding, nil when err doesn't point at fields.
+func Fields(err error) []FieldError {
+	var fields []FieldError
+	switch e := err.(type) {
+	case nil:
+	case *LineError:
+		for _, field := range Fields(e.Err) {
+			field.Field = strings.TrimSuffix(fmt.Sprintf("[%d].%s", e.Index, field.Field), ".")
+			fields = append(fields, field)
+		}
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			fields = append(fields, Fields(inner)...)
+		}
+	case *InvalidFieldError:
+		fields = append(fields, FieldError{Field: e.Field, Reason: e.Reason})
+	case validator.ValidationErrors:
+		for _, fe := range e {
+			reason := fe.Tag()
+			switch {
+			case strings.HasSuffix(fe.Tag(), "field"):
+				// rules comparing fields name the other field by its Go name
+				reason += "=" + snakeCase(fe.Param())
+			case fe.Param() != "":
+				reason += "=" + fe.Param()
+			}
+			fields = append(fields, FieldError{Field: fieldPath(fe.Namespace()), Reason: reason})
+		}
+	case *json.UnmarshalTypeError:
+		if e.Field != "" {
+			fields = append(fields, FieldError{Field: e.Field, Reason: "must be " + e.Type.String()})
+		}
+	default:
+		return Fields(errors.Unwrap(err))
+	}
+	return fields
+}
+
+// snakeCase turns a Go field name like FromStorageId into the from_storage_id used in requests.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 && !unicode.IsUpper(rune(name[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// fieldPath drops the name of the top level struct from the validator namespace.
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
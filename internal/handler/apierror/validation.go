@@ -0,0 +1,84 @@
+package apierror
+
+import (
+	"LamodaTest/internal/entity/goods"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"strings"
+)
+
+// CodeValidationFailed is returned with the list of fields that failed the validation rules.
+const CodeValidationFailed = "validation_failed"
+
+// Validation rules registered in addition to the validator built-ins.
+const (
+	RuleNotBlank = "notblank" // string with something besides spaces
+	RuleSize     = "size"     // one of goods.Sizes in any case
+)
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation(RuleNotBlank, func(fl validator.FieldLevel) bool {
+			return strings.TrimSpace(fl.Field().String()) != ""
+		})
+		_ = v.RegisterValidation(RuleSize, func(fl validator.FieldLevel) bool {
+			return goods.ValidSize(fl.Field().String())
+		})
+	}
+}
+
+// InvalidBody writes the error of binding a request body: 422 with the fields that failed validation,
+// or 400 when the body isn't JSON at all.
+func InvalidBody(c *gin.Context, err error) {
+	if fields := Fields(err); len(fields) > 0 {
+		write(c, Error{http.StatusUnprocessableEntity, CodeValidationFailed, "Validation failed"}, fields, nil)
+		return
+	}
+	write(c, Error{http.StatusBadRequest, CodeInvalidJSON, "Invalid JSON"}, nil, nil)
+}
+
+// InvalidFieldError is a field that failed a rule checked by the handler itself.
+// An empty Field means the whole body.
+type InvalidFieldError struct {
+	Field  string
+	Reason string
+	Err    error
+}
+
+// Invalid reports that field failed the rule.
+func Invalid(field string, rule string) error {
+	return &InvalidFieldError{Field: field, Reason: rule}
+}
+
+// Param describes err of parsing the path parameter name, so it is reported as a field error.
+func Param(name string, err error) error {
+	return &InvalidFieldError{Field: name, Reason: "must be an integer", Err: err}
+}
+
+func (e *InvalidFieldError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid field %q: %s: %s", e.Field, e.Reason, e.Err.Error())
+	}
+	return fmt.Sprintf("invalid field %q: %s", e.Field, e.Reason)
+}
+
+func (e *InvalidFieldError) Unwrap() error {
+	return e.Err
+}
+
+// LineError is the validation error of a line of a batch body, its fields are reported with the line index.
+type LineError struct {
+	Index int
+	Err   error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Index, e.Err.Error())
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
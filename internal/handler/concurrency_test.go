@@ -0,0 +1,92 @@
+//go:build integration
+
+package handler
+
+import (
+	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/storages"
+	"LamodaTest/internal/logger"
+	"LamodaTest/internal/registry"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReserveConcurrently fires parallel /goods/reserve calls for one item each
+// against the database from MYSQL_TEST_DSN and checks that no more than the stock is reserved.
+func TestReserveConcurrently(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("can't connect to mysql: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(50)
+	reg := registry.New(db)
+
+	ctx := context.Background()
+	uniqCode := int(time.Now().UnixNano() % 1_000_000_000)
+	storageId, err := reg.StoragesAdd(ctx, storages.Storage{Name: fmt.Sprintf("concurrency %d", uniqCode), Available: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = reg.GoodAdd(ctx, "concurrency", "M", uniqCode); err != nil {
+		t.Fatal(err)
+	}
+	if err = reg.ReceiveGood(ctx, uniqCode, int(storageId), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	router := Router(logger.New(false), false, reg)
+	body := fmt.Sprintf(`[{"uniq_code": %d, "count": 1}]`, uniqCode)
+	const clients = 40
+	var wg sync.WaitGroup
+	results := make(chan goods.ReservedDTO, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/goods/reserve", strings.NewReader(body))
+			router.ServeHTTP(w, req)
+			var res struct {
+				Data []goods.ReservedDTO `json:"data"`
+			}
+			if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &res) != nil || len(res.Data) != 1 {
+				t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+				return
+			}
+			results <- res.Data[0]
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	reserved := 0
+	for result := range results {
+		if result.ReservationId != 0 {
+			reserved++
+		}
+	}
+	if reserved != 10 {
+		t.Errorf("%d reservations are created, want 10", reserved)
+	}
+	stock, err := reg.GoodStock(ctx, uniqCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.Count != 10 || stock.Reserved != 10 {
+		t.Errorf("GoodStock() count = %d, reserved = %d, want 10 and 10", stock.Count, stock.Reserved)
+	}
+}
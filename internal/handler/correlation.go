@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"LamodaTest/internal/registry"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+)
+
+const CorrelationHeader = "X-Request-ID"
+
+// maxCorrelationLen matches stock_movements.correlation_id, longer client IDs are replaced.
+const maxCorrelationLen = 64
+
+// correlationID takes the request ID sent by the client or generates a new one, returns it in the response
+// and puts it into the request context, so the registry writes it to the stock movement ledger.
+func correlationID(c *gin.Context) {
+	id := c.GetHeader(CorrelationHeader)
+	if id == "" || len(id) > maxCorrelationLen {
+		id = newCorrelationID()
+	}
+	c.Header(CorrelationHeader, id)
+	c.Request = c.Request.WithContext(registry.WithCorrelationID(c.Request.Context(), id))
+	c.Next()
+}
+
+func newCorrelationID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -2,11 +2,21 @@ package goods
 
 import (
 	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
+	"LamodaTest/internal/handler/apierror"
 	"LamodaTest/internal/registry"
-	"context"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -14,13 +24,84 @@ const (
 	DeleteRoute  = "/goods/delete"
 	ReserveRoute = "/goods/reserve"
 	ReleaseRoute = "/goods/release"
+	ShipRoute    = "/goods/ship"
+	ReceiveRoute = "/goods/receive"
 	RemainsRoute = "/goods/remains"
 	AllRoute     = "/goods/all"
+
+	GoodRoute      = "/goods/:uniq_code"
+	RestoreRoute   = "/goods/:uniq_code/restore"
+	MovementsRoute = "/goods/:uniq_code/movements"
+)
+
+// Resource routes of the versioned API, GoodRoute, RestoreRoute, MovementsRoute and RemainsRoute are mounted there too.
+const (
+	GoodsRoute        = "/goods"
+	ReservationsRoute = "/reservations"
+	ReleasesRoute     = "/reservations/releases"
+	ShipmentsRoute    = "/shipments"
+	ReceiptsRoute     = "/receipts"
 )
 
+const defaultMovementsLimit = 100
+
 type goodWithCount struct {
-	UniqCode int `json:"uniq_code" binding:"required"`
-	Count    int `json:"count" binding:"required"`
+	UniqCode int   `json:"uniq_code" binding:"required,gt=0"`
+	Count    int   `json:"count" binding:"required,gt=0"`
+	TTL      int   `json:"ttl" binding:"min=0"`          // seconds, zero means the reservation never expires
+	Storages []int `json:"storages" binding:"dive,gt=0"` // pinned storages in the order they are drained
+}
+
+func (g goodWithCount) request() reservations.Request {
+	return reservations.Request{
+		UniqCode: g.UniqCode,
+		Count:    g.Count,
+		TTL:      time.Duration(g.TTL) * time.Second,
+		Storages: g.Storages,
+	}
+}
+
+// reserveInput accepts either a bare array of goods or an object with the `atomic` flag.
+type reserveInput struct {
+	Atomic bool            `json:"atomic"`
+	Goods  []goodWithCount `json:"goods"` // validated by validateBatch, so fields are reported as in other batches
+}
+
+func (r *reserveInput) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(trimmed, &r.Goods)
+	}
+	type plain reserveInput
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// maxBatch bounds the lines of batch requests.
+const maxBatch = 100
+
+// bindBatch binds a JSON array of lines and validates it with validateBatch.
+func bindBatch[T any](c *gin.Context, lines *[]T) error {
+	if err := json.NewDecoder(c.Request.Body).Decode(lines); err != nil {
+		return err
+	}
+	return validateBatch(*lines)
+}
+
+// validateBatch checks that there are 1 to maxBatch lines and validates every line on its own,
+// so failed fields are reported with the index of their line, e.g. `[0].count`.
+func validateBatch[T any](lines []T) error {
+	switch {
+	case len(lines) == 0:
+		return apierror.Invalid("", "min=1")
+	case len(lines) > maxBatch:
+		return apierror.Invalid("", fmt.Sprintf("max=%d", maxBatch))
+	}
+	var errs []error
+	for i, line := range lines {
+		if err := binding.Validator.ValidateStruct(line); err != nil {
+			errs = append(errs, &apierror.LineError{Index: i, Err: err})
+		}
+	}
+	return errors.Join(errs...)
 }
 
 type Handler struct {
@@ -34,19 +115,25 @@ func NewHandler(registry registry.Db, log logrus.FieldLogger) *Handler {
 
 func (h *Handler) Add(c *gin.Context) {
 	var input struct {
-		Name     string `json:"name" binding:"required"`
-		Size     string `json:"size" binding:"required"`
-		UniqCode int    `json:"uniq_code" binding:"required"`
+		Name     string `json:"name" binding:"required,notblank,max=45"`
+		Size     string `json:"size" binding:"required,size"`
+		UniqCode int    `json:"uniq_code" binding:"required,gt=0"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.log.Errorf("can't parse body from `/good/add` request: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid JSON"})
+		apierror.InvalidBody(c, err)
+		return
+	}
+	name, size := strings.TrimSpace(input.Name), goods.NormalizeSize(input.Size)
+	goodId, err := h.registry.GoodAdd(c.Request.Context(), name, size, input.UniqCode)
+	var duplicate *registry.DuplicateUniqCodeError
+	if errors.As(err, &duplicate) && duplicate.GoodId != 0 {
+		// the client gets the id of the existing good, as if it was just added
+		apierror.RespondWith(c, h.log, err, apierror.From(err, ""), duplicate.GoodId)
 		return
 	}
-	goodId, err := h.registry.GoodAdd(context.Background(), input.Name, input.Size, input.UniqCode)
 	if err != nil {
-		h.log.Errorf("can't add good: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Not added"})
+		apierror.Respond(c, h.log, err, "Not added")
 		return
 	}
 	c.JSON(200, gin.H{
@@ -55,19 +142,110 @@ func (h *Handler) Add(c *gin.Context) {
 	})
 }
 
+// Get returns the good with its totals and remains on every storage.
+func (h *Handler) Get(c *gin.Context) {
+	uniqCode, err := strconv.Atoi(c.Param("uniq_code"))
+	if err != nil {
+		h.log.Errorf("can't parse uniq_code from `/goods/:uniq_code` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("uniq_code", err), apierror.CodeInvalidParam, "Invalid uniq_code")
+		return
+	}
+	stock, err := h.registry.GoodStock(c.Request.Context(), uniqCode)
+	if err != nil {
+		apierror.Respond(c, h.log, err, "Internal server error")
+		return
+	}
+	c.JSON(200, gin.H{
+		"code": http.StatusOK,
+		"data": stock,
+	})
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	uniqCode, err := strconv.Atoi(c.Param("uniq_code"))
+	if err != nil {
+		h.log.Errorf("can't parse uniq_code from `/goods/:uniq_code` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("uniq_code", err), apierror.CodeInvalidParam, "Invalid uniq_code")
+		return
+	}
+	var input struct {
+		Name *string `json:"name" binding:"omitempty,notblank,max=45"`
+		Size *string `json:"size" binding:"omitempty,size"`
+	}
+	if err = c.ShouldBindJSON(&input); err != nil {
+		h.log.Errorf("can't parse body from `/goods/:uniq_code` request: %s", err.Error())
+		apierror.InvalidBody(c, err)
+		return
+	}
+	update := goods.Update(input)
+	if update.Name != nil {
+		*update.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Size != nil {
+		*update.Size = goods.NormalizeSize(*update.Size)
+	}
+	if update.Empty() {
+		apierror.BadRequest(c, nil, apierror.CodeNothingToUpdate, "Nothing to update")
+		return
+	}
+	good, err := h.registry.GoodUpdate(c.Request.Context(), uniqCode, update)
+	if err != nil {
+		apierror.Respond(c, h.log, err, "Can't update this good")
+		return
+	}
+	c.JSON(200, gin.H{
+		"code": http.StatusOK,
+		"data": good,
+	})
+}
+
+// Restore brings back a deleted good together with its remains.
+func (h *Handler) Restore(c *gin.Context) {
+	uniqCode, err := strconv.Atoi(c.Param("uniq_code"))
+	if err != nil {
+		h.log.Errorf("can't parse uniq_code from `/goods/:uniq_code/restore` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("uniq_code", err), apierror.CodeInvalidParam, "Invalid uniq_code")
+		return
+	}
+	good, err := h.registry.GoodRestore(c.Request.Context(), uniqCode)
+	if err != nil {
+		apierror.Respond(c, h.log, err, "Can't restore this good")
+		return
+	}
+	c.JSON(200, gin.H{
+		"code": http.StatusOK,
+		"data": good,
+	})
+}
+
+// Delete hides the good from the catalog, remains and reservations, see Restore.
 func (h *Handler) Delete(c *gin.Context) {
 	var input struct {
-		UniqCode int `json:"uniq_code" binding:"required"`
+		UniqCode int `json:"uniq_code" binding:"required,gt=0"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.log.Errorf("can't parse body from `/good/delete` request: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid JSON"})
+		apierror.InvalidBody(c, err)
+		return
+	}
+	h.delete(c, input.UniqCode)
+}
+
+// DeleteByCode is Delete of the good named by the path, for clients that can't send a body with DELETE.
+func (h *Handler) DeleteByCode(c *gin.Context) {
+	uniqCode, err := strconv.Atoi(c.Param("uniq_code"))
+	if err != nil {
+		h.log.Errorf("can't parse uniq_code from `/goods/:uniq_code` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("uniq_code", err), apierror.CodeInvalidParam, "Invalid uniq_code")
 		return
 	}
-	deleted, err := h.registry.GoodDelete(context.Background(), input.UniqCode)
+	h.delete(c, uniqCode)
+}
+
+func (h *Handler) delete(c *gin.Context, uniqCode int) {
+	deleted, err := h.registry.GoodDelete(c.Request.Context(), uniqCode)
 	if err != nil {
-		h.log.Errorf("can't delete good: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Can't delete this good"})
+		apierror.Respond(c, h.log, err, "Can't delete this good")
 		return
 	}
 	if deleted == 0 {
@@ -84,61 +262,192 @@ func (h *Handler) Delete(c *gin.Context) {
 }
 
 func (h *Handler) Release(c *gin.Context) {
-	var inputArr []goodWithCount
-	if err := c.ShouldBindJSON(&inputArr); err != nil {
+	var inputArr []struct {
+		ReservationId int64 `json:"reservation_id" binding:"required,gt=0"`
+	}
+	if err := bindBatch(c, &inputArr); err != nil {
 		h.log.Errorf("can't parse body from `/good/release` request: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid JSON"})
+		apierror.InvalidBody(c, err)
 		return
 	}
 	var result []goods.ReleasedDTO
+	errs := make([]error, 0, len(inputArr))
 	for _, obj := range inputArr {
-		err := h.registry.ReleaseGood(context.Background(), obj.UniqCode, obj.Count)
+		reservation, err := h.registry.ReleaseGood(c.Request.Context(), obj.ReservationId)
+		errs = append(errs, err)
 		tmp := goods.ReleasedDTO{}
-		tmp.UniqCode = obj.UniqCode
+		tmp.ReservationId = obj.ReservationId
+		tmp.UniqCode = reservation.UniqCode
 		if err != nil {
 			h.log.Warn(err)
-			tmp.AdditionalInfo = "can't release this good"
+			tmp.ErrorCode = apierror.From(err, "").Code
+			tmp.AdditionalInfo = "can't release this reservation"
 		} else {
 			tmp.AdditionalInfo = "OK"
 		}
 		result = append(result, tmp)
 	}
-	c.JSON(200, gin.H{
-		"code": http.StatusOK,
-		"data": result,
-	})
+	respondBatch(c, result, errs, "Nothing is released")
+}
+
+func (h *Handler) Ship(c *gin.Context) {
+	var inputArr []struct {
+		ReservationId int64 `json:"reservation_id" binding:"required,gt=0"`
+		Count         int   `json:"count" binding:"min=0"` // zero ships everything that is still reserved
+	}
+	if err := bindBatch(c, &inputArr); err != nil {
+		h.log.Errorf("can't parse body from `/good/ship` request: %s", err.Error())
+		apierror.InvalidBody(c, err)
+		return
+	}
+	var result []goods.ShippedDTO
+	errs := make([]error, 0, len(inputArr))
+	for _, obj := range inputArr {
+		shipment, err := h.registry.ShipGood(c.Request.Context(), obj.ReservationId, obj.Count)
+		errs = append(errs, err)
+		tmp := goods.ShippedDTO{
+			ReservationId: obj.ReservationId,
+			UniqCode:      shipment.UniqCode,
+			Storages:      []map[string]int{},
+		}
+		switch {
+		case errors.Is(err, registry.ErrExceedsReserved):
+			h.log.Warn(err)
+			tmp.ErrorCode = apierror.CodeExceedsReserved
+			tmp.AdditionalInfo = "can't ship more than is reserved"
+		case err != nil:
+			h.log.Warn(err)
+			tmp.ErrorCode = apierror.From(err, "").Code
+			tmp.AdditionalInfo = "can't ship this reservation"
+		default:
+			tmp.ShipmentId = shipment.ID
+			for _, line := range shipment.Lines {
+				tmp.Storages = append(tmp.Storages, map[string]int{
+					"storage": line.StorageId,
+					"shipped": line.Count,
+				})
+			}
+			tmp.AdditionalInfo = "OK"
+		}
+		result = append(result, tmp)
+	}
+	respondBatch(c, result, errs, "Nothing is shipped")
+}
+
+func (h *Handler) Receive(c *gin.Context) {
+	var inputArr []struct {
+		UniqCode  int `json:"uniq_code" binding:"required,gt=0"`
+		StorageId int `json:"storage_id" binding:"required,gt=0"`
+		Count     int `json:"count" binding:"required,gt=0"`
+	}
+	if err := bindBatch(c, &inputArr); err != nil {
+		h.log.Errorf("can't parse body from `/good/receive` request: %s", err.Error())
+		apierror.InvalidBody(c, err)
+		return
+	}
+	var result []goods.ReceivedDTO
+	errs := make([]error, 0, len(inputArr))
+	for _, obj := range inputArr {
+		err := h.registry.ReceiveGood(c.Request.Context(), obj.UniqCode, obj.StorageId, obj.Count)
+		errs = append(errs, err)
+		tmp := goods.ReceivedDTO{
+			UniqCode:  obj.UniqCode,
+			StorageId: obj.StorageId,
+			Count:     obj.Count,
+		}
+		switch {
+		case errors.Is(err, registry.ErrGoodNotFound):
+			h.log.Warn(err)
+			tmp.ErrorCode = apierror.CodeGoodNotFound
+			tmp.AdditionalInfo = "good not found"
+		case errors.Is(err, registry.ErrStorageNotFound):
+			h.log.Warn(err)
+			tmp.ErrorCode = apierror.CodeStorageNotFound
+			tmp.AdditionalInfo = "storage not found"
+		case err != nil:
+			h.log.Warn(err)
+			tmp.ErrorCode = apierror.From(err, "").Code
+			tmp.AdditionalInfo = "can't receive this good"
+		default:
+			tmp.AdditionalInfo = "OK"
+		}
+		result = append(result, tmp)
+	}
+	respondBatch(c, result, errs, "Nothing is received")
 }
 
 func (h *Handler) Reserve(c *gin.Context) {
-	var inputArr []goodWithCount
-	if err := c.ShouldBindJSON(&inputArr); err != nil {
+	var input reserveInput
+	err := json.NewDecoder(c.Request.Body).Decode(&input)
+	if err == nil {
+		err = validateBatch(input.Goods)
+	}
+	if err != nil {
 		h.log.Errorf("can't parse body from `/good/reserve` request: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid JSON"})
+		apierror.InvalidBody(c, err)
+		return
+	}
+	if input.Atomic {
+		h.reserveAtomic(c, input.Goods)
 		return
 	}
 	var result []goods.ReservedDTO
-	for _, obj := range inputArr {
-		reserved, err := h.registry.ReserveGood(context.Background(), obj.UniqCode, obj.Count)
+	errs := make([]error, 0, len(input.Goods))
+	for _, obj := range input.Goods {
+		reservation, err := h.registry.ReserveGood(c.Request.Context(), obj.request())
+		errs = append(errs, err)
 		if err != nil {
 			h.log.Warn(err)
+			result = append(result, goods.ReservedDTO{
+				UniqCode:       obj.UniqCode,
+				Storages:       []map[string]int{},
+				ErrorCode:      apierror.From(err, "").Code,
+				AdditionalInfo: "Can't reserve this good",
+			})
+			continue
 		}
-		tmp := goods.ReservedDTO{
-			UniqCode:       obj.UniqCode,
-			Storages:       []map[string]int{},
-			AdditionalInfo: "",
-		}
-		if len(reserved) > 0 {
-			for storage, count := range reserved {
-				tmp.Storages = append(tmp.Storages, map[string]int{
-					"storage":  storage,
-					"reserved": count,
-				})
+		result = append(result, reservedDTO(reservation))
+	}
+	respondBatch(c, result, errs, "Nothing is reserved")
+}
+
+func (h *Handler) reserveAtomic(c *gin.Context, inputArr []goodWithCount) {
+	reqs := make([]reservations.Request, 0, len(inputArr))
+	for _, obj := range inputArr {
+		reqs = append(reqs, obj.request())
+	}
+	reserved, err := h.registry.ReserveGoods(c.Request.Context(), reqs)
+	var batchErr *registry.BatchError
+	if errors.As(err, &batchErr) && len(batchErr.Errors) == len(inputArr) {
+		// the whole request is answered by the first line that failed
+		var first apierror.Error
+		result := make([]goods.ReservedDTO, 0, len(inputArr))
+		for i, obj := range inputArr {
+			dto := goods.ReservedDTO{
+				UniqCode:       obj.UniqCode,
+				Storages:       []map[string]int{},
+				AdditionalInfo: failureReason(batchErr.Errors[i]),
 			}
-			result = append(result, tmp)
-		} else {
-			tmp.AdditionalInfo = "Can't reserve this good"
-			result = append(result, tmp)
+			if batchErr.Errors[i] != nil {
+				lineErr := apierror.From(batchErr.Errors[i], "Nothing is reserved")
+				if first.Code == "" {
+					first = lineErr
+				}
+				dto.ErrorCode = lineErr.Code
+			}
+			result = append(result, dto)
 		}
+		first.Message = "Nothing is reserved"
+		apierror.RespondWith(c, h.log, err, first, result)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, h.log, err, "Nothing is reserved")
+		return
+	}
+	result := make([]goods.ReservedDTO, 0, len(reserved))
+	for _, reservation := range reserved {
+		result = append(result, reservedDTO(reservation))
 	}
 	c.JSON(200, gin.H{
 		"code": http.StatusOK,
@@ -146,28 +455,160 @@ func (h *Handler) Reserve(c *gin.Context) {
 	})
 }
 
+// respondBatch answers the lines of a batch with errs of the lines: 200 when every line succeeded,
+// 207 when some of them failed, and the error of the first line, with all the lines in data, when every line failed.
+// Failed lines are logged by the caller.
+func respondBatch(c *gin.Context, result any, errs []error, message string) {
+	var first error
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		c.JSON(200, gin.H{
+			"code": http.StatusOK,
+			"data": result,
+		})
+	case failed < len(errs):
+		c.JSON(http.StatusMultiStatus, gin.H{
+			"code": http.StatusMultiStatus,
+			"data": result,
+		})
+	default:
+		e := apierror.From(first, message)
+		e.Message = message
+		apierror.Write(c, e, result)
+	}
+}
+
+// failureReason explains to the client why a line of an atomic reserve wasn't reserved.
+func failureReason(err error) string {
+	switch {
+	case err == nil:
+		return "Not reserved: other goods of the order can't be reserved"
+	case errors.Is(err, registry.ErrGoodNotFound):
+		return "Can't reserve this good: good not found"
+	case errors.Is(err, registry.ErrInsufficientStock):
+		return "Can't reserve this good: not enough goods on available storages"
+	default:
+		return "Can't reserve this good"
+	}
+}
+
+func reservedDTO(reservation reservations.Reservation) goods.ReservedDTO {
+	tmp := goods.ReservedDTO{
+		UniqCode:      reservation.UniqCode,
+		ReservationId: reservation.ID,
+		Status:        reservation.Status,
+		CreatedAt:     &reservation.CreatedAt,
+		ExpiresAt:     reservation.ExpiresAt,
+		Storages:      []map[string]int{},
+	}
+	for _, line := range reservation.Lines {
+		tmp.Storages = append(tmp.Storages, map[string]int{
+			"storage":  line.StorageId,
+			"reserved": line.Count,
+		})
+	}
+	return tmp
+}
+
+// Remains returns a page of free goods on available storages, see `All` for the paging parameters.
 func (h *Handler) Remains(c *gin.Context) {
-	list, err := h.registry.AvailableGoods(context.Background())
+	var query struct {
+		pages.Query
+		Name         string `form:"name"`
+		Size         string `form:"size"`
+		StorageId    int    `form:"storage_id" binding:"min=0"`
+		MinAvailable int    `form:"min_available" binding:"min=0"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.log.Errorf("can't parse query from `/goods/remains` request: %s", err.Error())
+		apierror.BadRequest(c, err, apierror.CodeInvalidQuery, "Invalid query")
+		return
+	}
+	filter := goods.Filter{Name: query.Name, Size: goods.NormalizeSize(query.Size), StorageId: query.StorageId, MinAvailable: query.MinAvailable}
+	list, next, err := h.registry.AvailableGoods(c.Request.Context(), filter, query.Query)
 	if err != nil {
-		h.log.Errorf("can't get available goods: %s", err.Error())
-		c.JSON(500, gin.H{"code": http.StatusInternalServerError, "message": "Internal server error"})
+		apierror.Respond(c, h.log, err, "Internal server error")
 		return
 	}
-	c.JSON(200, gin.H{
+	c.JSON(200, page(list, next))
+}
+
+// All returns a page of the catalog, deleted goods are listed only with `include_deleted`.
+// `sort` is one of id, name, size, uniq_code with an optional "-" prefix for descending order,
+// `next_cursor` is returned while there are more goods and is passed back as `cursor`.
+func (h *Handler) All(c *gin.Context) {
+	var query struct {
+		pages.Query
+		Name           string `form:"name"`
+		Size           string `form:"size"`
+		IncludeDeleted bool   `form:"include_deleted"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.log.Errorf("can't parse query from `/goods/all` request: %s", err.Error())
+		apierror.BadRequest(c, err, apierror.CodeInvalidQuery, "Invalid query")
+		return
+	}
+	filter := goods.Filter{Name: query.Name, Size: goods.NormalizeSize(query.Size), IncludeDeleted: query.IncludeDeleted}
+	list, next, err := h.registry.Goods(c.Request.Context(), filter, query.Query)
+	if err != nil {
+		apierror.Respond(c, h.log, err, "Internal server error")
+		return
+	}
+	c.JSON(200, page(list, next))
+}
+
+func page(list any, next string) gin.H {
+	result := gin.H{
 		"code": http.StatusOK,
 		"data": list,
-	})
+	}
+	if next != "" {
+		result["next_cursor"] = next
+	}
+	return result
 }
 
-func (h *Handler) All(c *gin.Context) {
-	list, err := h.registry.Goods(context.Background())
+// Movements pages through the stock movement ledger of the good, oldest movements first.
+// `next_cursor` is returned while there can be more movements and is passed back as `cursor`.
+func (h *Handler) Movements(c *gin.Context) {
+	uniqCode, err := strconv.Atoi(c.Param("uniq_code"))
 	if err != nil {
-		h.log.Errorf("can't get all goods: %s", err.Error())
-		c.JSON(500, gin.H{"code": http.StatusInternalServerError, "message": "Internal server error"})
+		h.log.Errorf("can't parse uniq_code from `/goods/:uniq_code/movements` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("uniq_code", err), apierror.CodeInvalidParam, "Invalid uniq_code")
 		return
 	}
-	c.JSON(200, gin.H{
+	var query struct {
+		Cursor int64 `form:"cursor" binding:"min=0"`
+		Limit  int   `form:"limit" binding:"min=0,max=1000"`
+	}
+	if err = c.ShouldBindQuery(&query); err != nil {
+		h.log.Errorf("can't parse query from `/goods/:uniq_code/movements` request: %s", err.Error())
+		apierror.BadRequest(c, err, apierror.CodeInvalidQuery, "Invalid query")
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultMovementsLimit
+	}
+	list, err := h.registry.Movements(c.Request.Context(), uniqCode, query.Cursor, query.Limit)
+	if err != nil {
+		apierror.Respond(c, h.log, err, "Internal server error")
+		return
+	}
+	result := gin.H{
 		"code": http.StatusOK,
 		"data": list,
-	})
+	}
+	if len(list) == query.Limit {
+		result["next_cursor"] = strconv.FormatInt(list[len(list)-1].ID, 10)
+	}
+	c.JSON(200, result)
 }
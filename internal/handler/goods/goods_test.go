@@ -2,11 +2,16 @@ package goods
 
 import (
 	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/movements"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
+	"LamodaTest/internal/handler/apierror"
 	"LamodaTest/internal/logger"
 	"LamodaTest/internal/registry"
 	mock_registry "LamodaTest/internal/registry/mocks"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +21,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandler_Add(t *testing.T) {
@@ -42,7 +48,7 @@ func TestHandler_Add(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().GoodAdd(context.Background(), "test", "l", 1).Return(int64(1), nil).Times(1).AnyTimes()
+					m.EXPECT().GoodAdd(context.Background(), "test", "L", 1).Return(int64(1), nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -70,7 +76,7 @@ func TestHandler_Add(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().GoodAdd(context.Background(), "test", "l", 1).Return(int64(0), errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().GoodAdd(context.Background(), "test", "L", 1).Return(int64(0), errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -88,8 +94,67 @@ func TestHandler_Add(t *testing.T) {
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Not added",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Not added",
+			},
+		}, {
+			name: "duplicate uniq_code",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().GoodAdd(context.Background(), "test", "L", 1).
+						Return(int64(-1), &registry.DuplicateUniqCodeError{UniqCode: 1, GoodId: 6}).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body:   `{"name": "test", "size": "l", "uniq_code": 1}`,
+			},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"data":       6,
+				"error_code": apierror.CodeDuplicateUniqCode,
+				"message":    "Good with this uniq_code already exists",
+			},
+		}, {
+			name: "blank name and unknown size",
+			fields: fields{
+				registry: mock_registry.NewMockDb(gomock.NewController(t)),
+				log:      l,
+			},
+			args: args{
+				method: "POST",
+				body:   `{"name": "  ", "size": "huge", "uniq_code": -1}`,
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors": []apierror.FieldError{
+					{Field: "name", Reason: "notblank"},
+					{Field: "size", Reason: "size"},
+					{Field: "uniq_code", Reason: "gt=0"},
+				},
+				"message": "Validation failed",
+			},
+		}, {
+			name: "broken json",
+			fields: fields{
+				registry: mock_registry.NewMockDb(gomock.NewController(t)),
+				log:      l,
+			},
+			args:     args{method: "POST", body: `{"name": "test",`},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidJSON,
+				"message":    "Invalid JSON",
 			},
 		}, {
 			name: "invalid json",
@@ -98,7 +163,7 @@ func TestHandler_Add(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().GoodAdd(context.Background(), "test", "l", 1).Return(int64(0), nil).Times(1).AnyTimes()
+					m.EXPECT().GoodAdd(context.Background(), "test", "L", 1).Return(int64(0), nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -112,10 +177,12 @@ func TestHandler_Add(t *testing.T) {
 					return string(marshal)
 				}(),
 			},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 			wantRes: map[string]interface{}{
-				"code":    http.StatusBadRequest,
-				"message": "Invalid JSON",
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "name", Reason: "required"}, {Field: "size", Reason: "required"}, {Field: "uniq_code", Reason: "required"}},
+				"message":    "Validation failed",
 			},
 		},
 	}
@@ -147,9 +214,18 @@ func TestHandler_All(t *testing.T) {
 		log      logrus.FieldLogger
 	}
 	l := logger.New(false)
+	list := []goods.Good{
+		{
+			Id:       1,
+			Name:     "test",
+			Size:     "l",
+			UniqCode: 1,
+		},
+	}
 	tests := []struct {
 		name     string
 		fields   fields
+		query    string
 		wantRes  map[string]interface{}
 		wantCode int
 	}{
@@ -161,15 +237,8 @@ func TestHandler_All(t *testing.T) {
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
 					m.EXPECT().
-						Goods(context.Background()).
-						Return([]goods.Good{
-							{
-								Id:       1,
-								Name:     "test",
-								Size:     "l",
-								UniqCode: 1,
-							},
-						}, nil).
+						Goods(context.Background(), goods.Filter{}, pages.Query{}).
+						Return(list, "", nil).
 						Times(1).
 						AnyTimes()
 					return m
@@ -179,14 +248,89 @@ func TestHandler_All(t *testing.T) {
 			wantCode: 200,
 			wantRes: map[string]interface{}{
 				"code": 200,
-				"data": []goods.Good{
-					{
-						Id:       1,
-						Name:     "test",
-						Size:     "l",
-						UniqCode: 1,
-					},
-				},
+				"data": list,
+			},
+		}, {
+			name: "page with filters",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().
+						Goods(context.Background(), goods.Filter{Name: "te", Size: "L"}, pages.Query{Cursor: "abc", Limit: 1, Sort: "-name"}).
+						Return(list, "next", nil).
+						Times(1).
+						AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?name=te&size=l&cursor=abc&limit=1&sort=-name",
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code":        200,
+				"data":        list,
+				"next_cursor": "next",
+			},
+		}, {
+			name: "include deleted",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().
+						Goods(context.Background(), goods.Filter{IncludeDeleted: true}, pages.Query{}).
+						Return(list, "", nil).
+						AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?include_deleted=true",
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": list,
+			},
+		}, {
+			name: "invalid sort",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().Goods(context.Background(), goods.Filter{}, pages.Query{Sort: "price"}).
+						Return(nil, "", registry.ErrInvalidQuery).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?sort=price",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"message":    "Invalid query",
+			},
+		}, {
+			name: "limit too big",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					return mock_registry.NewMockDb(ctrl)
+				}(),
+				log: l,
+			},
+			query:    "?limit=5000",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"errors":     []apierror.FieldError{{Field: "limit", Reason: "max=1000"}},
+				"message":    "Invalid query",
 			},
 		}, {
 			name: "err from db",
@@ -195,15 +339,16 @@ func TestHandler_All(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().Goods(context.Background()).Return(nil, errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().Goods(context.Background(), goods.Filter{}, pages.Query{}).Return(nil, "", errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Internal server error",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Internal server error",
 			},
 		},
 	}
@@ -218,7 +363,7 @@ func TestHandler_All(t *testing.T) {
 			router.GET(AllRoute, h.All)
 
 			w := httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", AllRoute, nil)
+			req, _ := http.NewRequest("GET", AllRoute+tt.query, nil)
 
 			router.ServeHTTP(w, req)
 
@@ -321,8 +466,36 @@ func TestHandler_Delete(t *testing.T) {
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Can't delete this good",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Can't delete this good",
+			},
+		}, {
+			name: "good has active reservations",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().GoodDelete(context.Background(), 1).Return(int64(-1), registry.ErrGoodReserved).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "DELETE",
+				body: func() string {
+					marshal, _ := json.Marshal(map[string]interface{}{
+						"uniq_code": 1,
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"error_code": apierror.CodeGoodReserved,
+				"message":    "Good has active reservations",
 			},
 		}, {
 			name: "invalid json",
@@ -345,10 +518,12 @@ func TestHandler_Delete(t *testing.T) {
 					return string(marshal)
 				}(),
 			},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 			wantRes: map[string]interface{}{
-				"code":    http.StatusBadRequest,
-				"message": "Invalid JSON",
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "uniq_code", Reason: "required"}},
+				"message":    "Validation failed",
 			},
 		},
 	}
@@ -398,7 +573,7 @@ func TestHandler_Release(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().ReleaseGood(context.Background(), 1, 5).Return(nil).Times(1).AnyTimes()
+					m.EXPECT().ReleaseGood(context.Background(), int64(7)).Return(reservations.Reservation{ID: 7, UniqCode: 1}, nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -407,8 +582,7 @@ func TestHandler_Release(t *testing.T) {
 				method: "POST",
 				body: func() string {
 					marshal, _ := json.Marshal([]map[string]interface{}{{
-						"uniq_code": 1,
-						"count":     5,
+						"reservation_id": 7,
 					}})
 					return string(marshal)
 				}(),
@@ -417,8 +591,9 @@ func TestHandler_Release(t *testing.T) {
 			wantRes: map[string]interface{}{
 				"code": 200,
 				"data": []goods.ReleasedDTO{{
-					1,
-					"OK",
+					ReservationId:  7,
+					UniqCode:       1,
+					AdditionalInfo: "OK",
 				}},
 			},
 		}, {
@@ -428,8 +603,8 @@ func TestHandler_Release(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().ReleaseGood(context.Background(), 1, 5).Return(nil).AnyTimes()
-					m.EXPECT().ReleaseGood(context.Background(), 2, 1).Return(errors.New("test")).AnyTimes()
+					m.EXPECT().ReleaseGood(context.Background(), int64(7)).Return(reservations.Reservation{ID: 7, UniqCode: 1}, nil).AnyTimes()
+					m.EXPECT().ReleaseGood(context.Background(), int64(8)).Return(reservations.Reservation{ID: 8}, errors.New("test")).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -439,27 +614,27 @@ func TestHandler_Release(t *testing.T) {
 				body: func() string {
 					marshal, _ := json.Marshal([]map[string]interface{}{
 						{
-							"uniq_code": 1,
-							"count":     5,
+							"reservation_id": 7,
 						}, {
-							"uniq_code": 2,
-							"count":     1,
+							"reservation_id": 8,
 						},
 					})
 					return string(marshal)
 				}(),
 			},
-			wantCode: 200,
+			wantCode: http.StatusMultiStatus,
 			wantRes: map[string]interface{}{
-				"code": 200,
+				"code": http.StatusMultiStatus,
 				"data": []goods.ReleasedDTO{
 					{
-						1,
-						"OK",
+						ReservationId:  7,
+						UniqCode:       1,
+						AdditionalInfo: "OK",
 					},
 					{
-						2,
-						"can't release this good",
+						ReservationId:  8,
+						ErrorCode:      apierror.CodeInternal,
+						AdditionalInfo: "can't release this reservation",
 					},
 				},
 			},
@@ -470,7 +645,7 @@ func TestHandler_Release(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().ReleaseGood(context.Background(), 1, 5).Return(errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().ReleaseGood(context.Background(), int64(7)).Return(reservations.Reservation{ID: 7}, errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -480,19 +655,21 @@ func TestHandler_Release(t *testing.T) {
 				body: func() string {
 					marshal, _ := json.Marshal([]map[string]interface{}{
 						{
-							"uniq_code": 1,
-							"count":     5,
+							"reservation_id": 7,
 						},
 					})
 					return string(marshal)
 				}(),
 			},
-			wantCode: 200,
+			wantCode: http.StatusInternalServerError,
 			wantRes: map[string]interface{}{
-				"code": 200,
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Nothing is released",
 				"data": []goods.ReleasedDTO{{
-					1,
-					"can't release this good",
+					ReservationId:  7,
+					ErrorCode:      apierror.CodeInternal,
+					AdditionalInfo: "can't release this reservation",
 				}},
 			},
 		}, {
@@ -518,8 +695,9 @@ func TestHandler_Release(t *testing.T) {
 			},
 			wantCode: http.StatusBadRequest,
 			wantRes: map[string]interface{}{
-				"code":    http.StatusBadRequest,
-				"message": "Invalid JSON",
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidJSON,
+				"message":    "Invalid JSON",
 			},
 		},
 	}
@@ -551,9 +729,19 @@ func TestHandler_Remains(t *testing.T) {
 		log      logrus.FieldLogger
 	}
 	l := logger.New(false)
+	remains := map[int]goods.RemainsDTO{
+		1: {
+			Name: "test",
+			Size: "l",
+			StorageAvailable: map[int]int{
+				1: 1,
+			},
+		},
+	}
 	tests := []struct {
 		name     string
 		fields   fields
+		query    string
 		wantRes  map[string]interface{}
 		wantCode int
 	}{
@@ -565,16 +753,8 @@ func TestHandler_Remains(t *testing.T) {
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
 					m.EXPECT().
-						AvailableGoods(context.Background()).
-						Return(map[int]goods.RemainsDTO{
-							1: {
-								Name: "test",
-								Size: "l",
-								StorageAvailable: map[int]int{
-									1: 1,
-								},
-							},
-						}, nil).
+						AvailableGoods(context.Background(), goods.Filter{}, pages.Query{}).
+						Return(remains, "", nil).
 						Times(1).
 						AnyTimes()
 					return m
@@ -584,15 +764,70 @@ func TestHandler_Remains(t *testing.T) {
 			wantCode: 200,
 			wantRes: map[string]interface{}{
 				"code": 200,
-				"data": map[int]goods.RemainsDTO{
-					1: {
-						Name: "test",
-						Size: "l",
-						StorageAvailable: map[int]int{
-							1: 1,
-						},
-					},
-				},
+				"data": remains,
+			},
+		}, {
+			name: "page with filters",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().
+						AvailableGoods(context.Background(),
+							goods.Filter{Name: "te", Size: "L", StorageId: 1, MinAvailable: 1},
+							pages.Query{Cursor: "abc", Limit: 1, Sort: "uniq_code"}).
+						Return(remains, "next", nil).
+						Times(1).
+						AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?name=te&size=l&storage_id=1&min_available=1&cursor=abc&limit=1&sort=uniq_code",
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code":        200,
+				"data":        remains,
+				"next_cursor": "next",
+			},
+		}, {
+			name: "invalid cursor",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().AvailableGoods(context.Background(), goods.Filter{}, pages.Query{Cursor: "abc"}).
+						Return(nil, "", registry.ErrInvalidQuery).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?cursor=abc",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"message":    "Invalid query",
+			},
+		}, {
+			name: "negative min_available",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					return mock_registry.NewMockDb(ctrl)
+				}(),
+				log: l,
+			},
+			query:    "?min_available=-1",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"errors":     []apierror.FieldError{{Field: "min_available", Reason: "min=0"}},
+				"message":    "Invalid query",
 			},
 		}, {
 			name: "err from db",
@@ -601,15 +836,16 @@ func TestHandler_Remains(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().AvailableGoods(context.Background()).Return(nil, errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().AvailableGoods(context.Background(), goods.Filter{}, pages.Query{}).Return(nil, "", errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Internal server error",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Internal server error",
 			},
 		},
 	}
@@ -624,7 +860,7 @@ func TestHandler_Remains(t *testing.T) {
 			router.GET(RemainsRoute, h.Remains)
 
 			w := httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", RemainsRoute, nil)
+			req, _ := http.NewRequest("GET", RemainsRoute+tt.query, nil)
 
 			router.ServeHTTP(w, req)
 
@@ -645,6 +881,17 @@ func TestHandler_Reserve(t *testing.T) {
 		body   string
 	}
 	l := logger.New(false)
+	created := time.Date(2024, 2, 14, 21, 56, 3, 0, time.UTC)
+	reservation := reservations.Reservation{
+		ID:        7,
+		UniqCode:  1,
+		Lines:     []reservations.Line{{StorageId: 1, Count: 5}},
+		CreatedAt: created,
+		Status:    "active",
+	}
+	expires := created.Add(10 * time.Minute)
+	expiring := reservation
+	expiring.ExpiresAt = &expires
 	tests := []struct {
 		name     string
 		fields   fields
@@ -659,7 +906,7 @@ func TestHandler_Reserve(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().ReserveGood(context.Background(), 1, 5).Return(map[int]int{1: 5}, nil).Times(1).AnyTimes()
+					m.EXPECT().ReserveGood(context.Background(), reservations.Request{UniqCode: 1, Count: 5}).Return(reservation, nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -678,7 +925,10 @@ func TestHandler_Reserve(t *testing.T) {
 			wantRes: map[string]interface{}{
 				"code": 200,
 				"data": []goods.ReservedDTO{{
-					UniqCode: 1,
+					UniqCode:      1,
+					ReservationId: 7,
+					Status:        "active",
+					CreatedAt:     &created,
 					Storages: []map[string]int{
 						{
 							"reserved": 5,
@@ -688,14 +938,13 @@ func TestHandler_Reserve(t *testing.T) {
 				}},
 			},
 		}, {
-			name: "one normal, but one is corrupted",
+			name: "with pinned storages",
 			fields: fields{
 				registry: func() *mock_registry.MockDb {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().ReserveGood(context.Background(), 1, 5).Return(map[int]int{1: 5}, nil).AnyTimes()
-					m.EXPECT().ReserveGood(context.Background(), 2, 1).Return(nil, errors.New("test")).AnyTimes()
+					m.EXPECT().ReserveGood(context.Background(), reservations.Request{UniqCode: 1, Count: 5, Storages: []int{1, 3}}).Return(reservation, nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -703,46 +952,38 @@ func TestHandler_Reserve(t *testing.T) {
 			args: args{
 				method: "POST",
 				body: func() string {
-					marshal, _ := json.Marshal([]map[string]interface{}{
-						{
-							"uniq_code": 1,
-							"count":     5,
-						}, {
-							"uniq_code": 2,
-							"count":     1,
-						},
-					})
+					marshal, _ := json.Marshal([]map[string]interface{}{{
+						"uniq_code": 1,
+						"count":     5,
+						"storages":  []int{1, 3},
+					}})
 					return string(marshal)
 				}(),
 			},
 			wantCode: 200,
 			wantRes: map[string]interface{}{
 				"code": 200,
-				"data": []goods.ReservedDTO{
-					{
-						UniqCode: 1,
-						Storages: []map[string]int{
-							{
-								"reserved": 5,
-								"storage":  1,
-							},
+				"data": []goods.ReservedDTO{{
+					UniqCode:      1,
+					ReservationId: 7,
+					Status:        "active",
+					CreatedAt:     &created,
+					Storages: []map[string]int{
+						{
+							"reserved": 5,
+							"storage":  1,
 						},
 					},
-					{
-						UniqCode:       2,
-						Storages:       []map[string]int{},
-						AdditionalInfo: "Can't reserve this good",
-					},
-				},
+				}},
 			},
 		}, {
-			name: "err from db",
+			name: "with ttl",
 			fields: fields{
 				registry: func() *mock_registry.MockDb {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().ReserveGood(context.Background(), 1, 5).Return(nil, errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().ReserveGood(context.Background(), reservations.Request{UniqCode: 1, Count: 5, TTL: 10 * time.Minute}).Return(expiring, nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -750,28 +991,33 @@ func TestHandler_Reserve(t *testing.T) {
 			args: args{
 				method: "POST",
 				body: func() string {
-					marshal, _ := json.Marshal([]map[string]interface{}{
-						{
-							"uniq_code": 1,
-							"count":     5,
-						},
-					})
+					marshal, _ := json.Marshal([]map[string]interface{}{{
+						"uniq_code": 1,
+						"count":     5,
+						"ttl":       600,
+					}})
 					return string(marshal)
 				}(),
 			},
 			wantCode: 200,
 			wantRes: map[string]interface{}{
 				"code": 200,
-				"data": []goods.ReservedDTO{
-					{
-						UniqCode:       1,
-						Storages:       []map[string]int{},
-						AdditionalInfo: "Can't reserve this good",
+				"data": []goods.ReservedDTO{{
+					UniqCode:      1,
+					ReservationId: 7,
+					Status:        "active",
+					CreatedAt:     &created,
+					ExpiresAt:     &expires,
+					Storages: []map[string]int{
+						{
+							"reserved": 5,
+							"storage":  1,
+						},
 					},
-				},
+				}},
 			},
 		}, {
-			name: "invalid json",
+			name: "negative ttl",
 			fields: fields{
 				registry: func() *mock_registry.MockDb {
 					ctrl := gomock.NewController(t)
@@ -784,29 +1030,312 @@ func TestHandler_Reserve(t *testing.T) {
 			args: args{
 				method: "POST",
 				body: func() string {
-					marshal, _ := json.Marshal(map[string]interface{}{
-						"d": 1,
-					})
+					marshal, _ := json.Marshal([]map[string]interface{}{{
+						"uniq_code": 1,
+						"count":     5,
+						"ttl":       -1,
+					}})
 					return string(marshal)
 				}(),
 			},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 			wantRes: map[string]interface{}{
-				"code":    http.StatusBadRequest,
-				"message": "Invalid JSON",
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "[0].ttl", Reason: "min=0"}},
+				"message":    "Validation failed",
 			},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			h := &Handler{
-				registry: tt.fields.registry,
-				log:      tt.fields.log,
-			}
-			router := gin.Default()
-			gin.SetMode(gin.ReleaseMode)
-			router.POST(ReserveRoute, h.Reserve)
-
+		}, {
+			name: "atomic with an invalid line",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal(map[string]interface{}{
+						"atomic": true,
+						"goods":  []map[string]interface{}{{"uniq_code": 1, "count": 5}, {"uniq_code": 2}},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "[1].count", Reason: "required"}},
+				"message":    "Validation failed",
+			},
+		}, {
+			name: "one normal, but one is corrupted",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().ReserveGood(context.Background(), reservations.Request{UniqCode: 1, Count: 5}).Return(reservation, nil).AnyTimes()
+					m.EXPECT().ReserveGood(context.Background(), reservations.Request{UniqCode: 2, Count: 1}).Return(reservations.Reservation{}, errors.New("test")).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal([]map[string]interface{}{
+						{
+							"uniq_code": 1,
+							"count":     5,
+						}, {
+							"uniq_code": 2,
+							"count":     1,
+						},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusMultiStatus,
+			wantRes: map[string]interface{}{
+				"code": http.StatusMultiStatus,
+				"data": []goods.ReservedDTO{
+					{
+						UniqCode:      1,
+						ReservationId: 7,
+						Status:        "active",
+						CreatedAt:     &created,
+						Storages: []map[string]int{
+							{
+								"reserved": 5,
+								"storage":  1,
+							},
+						},
+					},
+					{
+						UniqCode:       2,
+						Storages:       []map[string]int{},
+						ErrorCode:      apierror.CodeInternal,
+						AdditionalInfo: "Can't reserve this good",
+					},
+				},
+			},
+		}, {
+			name: "err from db",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().ReserveGood(context.Background(), reservations.Request{UniqCode: 1, Count: 5}).Return(reservations.Reservation{}, errors.New("test")).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal([]map[string]interface{}{
+						{
+							"uniq_code": 1,
+							"count":     5,
+						},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Nothing is reserved",
+				"data": []goods.ReservedDTO{
+					{
+						UniqCode:       1,
+						Storages:       []map[string]int{},
+						ErrorCode:      apierror.CodeInternal,
+						AdditionalInfo: "Can't reserve this good",
+					},
+				},
+			},
+		}, {
+			name: "atomic",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().ReserveGoods(context.Background(), []reservations.Request{{UniqCode: 1, Count: 5}}).Return([]reservations.Reservation{reservation}, nil).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal(map[string]interface{}{
+						"atomic": true,
+						"goods": []map[string]interface{}{{
+							"uniq_code": 1,
+							"count":     5,
+						}},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": []goods.ReservedDTO{{
+					UniqCode:      1,
+					ReservationId: 7,
+					Status:        "active",
+					CreatedAt:     &created,
+					Storages: []map[string]int{
+						{
+							"reserved": 5,
+							"storage":  1,
+						},
+					},
+				}},
+			},
+		}, {
+			name: "atomic, but one line can't be reserved",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().
+						ReserveGoods(context.Background(), []reservations.Request{{UniqCode: 1, Count: 5}, {UniqCode: 2, Count: 1}, {UniqCode: 3, Count: 1}}).
+						Return(nil, &registry.BatchError{Errors: []error{nil, registry.ErrInsufficientStock, registry.ErrGoodNotFound}}).
+						Times(1).
+						AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal(map[string]interface{}{
+						"atomic": true,
+						"goods": []map[string]interface{}{
+							{
+								"uniq_code": 1,
+								"count":     5,
+							}, {
+								"uniq_code": 2,
+								"count":     1,
+							}, {
+								"uniq_code": 3,
+								"count":     1,
+							},
+						},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeInsufficientStock,
+				"message":    "Nothing is reserved",
+				"data": []goods.ReservedDTO{
+					{
+						UniqCode:       1,
+						Storages:       []map[string]int{},
+						AdditionalInfo: "Not reserved: other goods of the order can't be reserved",
+					},
+					{
+						UniqCode:       2,
+						Storages:       []map[string]int{},
+						ErrorCode:      apierror.CodeInsufficientStock,
+						AdditionalInfo: "Can't reserve this good: not enough goods on available storages",
+					},
+					{
+						UniqCode:       3,
+						Storages:       []map[string]int{},
+						ErrorCode:      apierror.CodeGoodNotFound,
+						AdditionalInfo: "Can't reserve this good: good not found",
+					},
+				},
+			},
+		}, {
+			name: "atomic, err from db",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().ReserveGoods(context.Background(), []reservations.Request{{UniqCode: 1, Count: 5}}).Return(nil, errors.New("test")).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal(map[string]interface{}{
+						"atomic": true,
+						"goods": []map[string]interface{}{{
+							"uniq_code": 1,
+							"count":     5,
+						}},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Nothing is reserved",
+			},
+		}, {
+			name: "invalid json",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal(map[string]interface{}{
+						"d": 1,
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "", Reason: "min=1"}},
+				"message":    "Validation failed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.POST(ReserveRoute, h.Reserve)
+
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(tt.args.method, ReserveRoute, strings.NewReader(tt.args.body))
 
@@ -818,3 +1347,864 @@ func TestHandler_Reserve(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_Ship(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		method string
+		body   string
+	}
+	l := logger.New(false)
+	shipment := reservations.Shipment{
+		ID:            3,
+		ReservationID: 7,
+		UniqCode:      1,
+		Lines:         []reservations.Line{{StorageId: 1, Count: 5}},
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "normal",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().ShipGood(context.Background(), int64(7), 5).Return(shipment, nil).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal([]map[string]interface{}{{
+						"reservation_id": 7,
+						"count":          5,
+					}})
+					return string(marshal)
+				}(),
+			},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": []goods.ShippedDTO{{
+					ReservationId: 7,
+					ShipmentId:    3,
+					UniqCode:      1,
+					Storages: []map[string]int{
+						{
+							"shipped": 5,
+							"storage": 1,
+						},
+					},
+					AdditionalInfo: "OK",
+				}},
+			},
+		}, {
+			name: "more than reserved and err from db",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().ShipGood(context.Background(), int64(7), 50).Return(reservations.Shipment{ReservationID: 7, UniqCode: 1}, registry.ErrExceedsReserved).AnyTimes()
+					m.EXPECT().ShipGood(context.Background(), int64(8), 0).Return(reservations.Shipment{ReservationID: 8}, errors.New("test")).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal([]map[string]interface{}{
+						{
+							"reservation_id": 7,
+							"count":          50,
+						}, {
+							"reservation_id": 8,
+						},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeExceedsReserved,
+				"message":    "Nothing is shipped",
+				"data": []goods.ShippedDTO{
+					{
+						ReservationId:  7,
+						UniqCode:       1,
+						Storages:       []map[string]int{},
+						ErrorCode:      apierror.CodeExceedsReserved,
+						AdditionalInfo: "can't ship more than is reserved",
+					},
+					{
+						ReservationId:  8,
+						Storages:       []map[string]int{},
+						ErrorCode:      apierror.CodeInternal,
+						AdditionalInfo: "can't ship this reservation",
+					},
+				},
+			},
+		}, {
+			name: "invalid json",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal([]map[string]interface{}{{
+						"reservation_id": 7,
+						"count":          -1,
+					}})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "[0].count", Reason: "min=0"}},
+				"message":    "Validation failed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.POST(ShipRoute, h.Ship)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.args.method, ShipRoute, strings.NewReader(tt.args.body))
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Receive(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		method string
+		body   string
+	}
+	l := logger.New(false)
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "normal and errors from db",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().ReceiveGood(context.Background(), 1, 2, 10).Return(nil).AnyTimes()
+					m.EXPECT().ReceiveGood(context.Background(), 5, 2, 10).Return(registry.ErrGoodNotFound).AnyTimes()
+					m.EXPECT().ReceiveGood(context.Background(), 1, 9, 10).Return(registry.ErrStorageNotFound).AnyTimes()
+					m.EXPECT().ReceiveGood(context.Background(), 1, 3, 10).Return(errors.New("test")).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal([]map[string]interface{}{
+						{"uniq_code": 1, "storage_id": 2, "count": 10},
+						{"uniq_code": 5, "storage_id": 2, "count": 10},
+						{"uniq_code": 1, "storage_id": 9, "count": 10},
+						{"uniq_code": 1, "storage_id": 3, "count": 10},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusMultiStatus,
+			wantRes: map[string]interface{}{
+				"code": http.StatusMultiStatus,
+				"data": []goods.ReceivedDTO{
+					{UniqCode: 1, StorageId: 2, Count: 10, AdditionalInfo: "OK"},
+					{UniqCode: 5, StorageId: 2, Count: 10, ErrorCode: apierror.CodeGoodNotFound, AdditionalInfo: "good not found"},
+					{UniqCode: 1, StorageId: 9, Count: 10, ErrorCode: apierror.CodeStorageNotFound, AdditionalInfo: "storage not found"},
+					{UniqCode: 1, StorageId: 3, Count: 10, ErrorCode: apierror.CodeInternal, AdditionalInfo: "can't receive this good"},
+				},
+			},
+		}, {
+			name: "negative count",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal([]map[string]interface{}{{
+						"uniq_code":  1,
+						"storage_id": 2,
+						"count":      -1,
+					}})
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "[0].count", Reason: "gt=0"}},
+				"message":    "Validation failed",
+			},
+		}, {
+			name: "every failed line",
+			fields: fields{
+				registry: mock_registry.NewMockDb(gomock.NewController(t)),
+				log:      l,
+			},
+			args: args{
+				method: "POST",
+				body:   `[{"uniq_code": 1, "storage_id": 2, "count": 1}, {"storage_id": -2, "count": 1}, {"uniq_code": 1, "storage_id": 2}]`,
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors": []apierror.FieldError{
+					{Field: "[1].uniq_code", Reason: "required"},
+					{Field: "[1].storage_id", Reason: "gt=0"},
+					{Field: "[2].count", Reason: "required"},
+				},
+				"message": "Validation failed",
+			},
+		}, {
+			name: "empty batch",
+			fields: fields{
+				registry: mock_registry.NewMockDb(gomock.NewController(t)),
+				log:      l,
+			},
+			args:     args{method: "POST", body: `[]`},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "", Reason: "min=1"}},
+				"message":    "Validation failed",
+			},
+		}, {
+			name: "too long batch",
+			fields: fields{
+				registry: mock_registry.NewMockDb(gomock.NewController(t)),
+				log:      l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					lines := make([]map[string]int, maxBatch+1)
+					for i := range lines {
+						lines[i] = map[string]int{"uniq_code": 1, "storage_id": 2, "count": 1}
+					}
+					marshal, _ := json.Marshal(lines)
+					return string(marshal)
+				}(),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "", Reason: "max=100"}},
+				"message":    "Validation failed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.POST(ReceiveRoute, h.Receive)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.args.method, ReceiveRoute, strings.NewReader(tt.args.body))
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Movements(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		method string
+		path   string
+	}
+	l := logger.New(false)
+	created := time.Date(2024, 2, 14, 21, 56, 3, 0, time.UTC)
+	list := []movements.Movement{
+		{ID: 4, UniqCode: 1, StorageId: 2, Type: movements.TypeReceive, CountDelta: 10, CorrelationId: "req-1", CreatedAt: created},
+		{ID: 5, UniqCode: 1, StorageId: 2, Type: movements.TypeReserve, ReservedDelta: 3, CreatedAt: created},
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "full page",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().Movements(context.Background(), 1, int64(3), 2).Return(list, nil).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{method: "GET", path: "/goods/1/movements?cursor=3&limit=2"},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code":        200,
+				"data":        list,
+				"next_cursor": "5",
+			},
+		}, {
+			name: "last page with default limit",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().Movements(context.Background(), 1, int64(0), 100).Return(list, nil).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{method: "GET", path: "/goods/1/movements"},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": list,
+			},
+		}, {
+			name: "err from db",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().Movements(context.Background(), 1, int64(0), 100).Return(nil, errors.New("test")).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{method: "GET", path: "/goods/1/movements"},
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Internal server error",
+			},
+		}, {
+			name: "invalid uniq_code",
+			fields: fields{
+				registry: mock_registry.NewMockDb(gomock.NewController(t)),
+				log:      l,
+			},
+			args:     args{method: "GET", path: "/goods/abc/movements"},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "uniq_code", Reason: "must be an integer"}},
+				"message":    "Invalid uniq_code",
+			},
+		}, {
+			name: "invalid limit",
+			fields: fields{
+				registry: mock_registry.NewMockDb(gomock.NewController(t)),
+				log:      l,
+			},
+			args:     args{method: "GET", path: "/goods/1/movements?limit=5000"},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"errors":     []apierror.FieldError{{Field: "limit", Reason: "max=1000"}},
+				"message":    "Invalid query",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.GET(MovementsRoute, h.Movements)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.args.method, tt.args.path, nil)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Update(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		method string
+		path   string
+		body   string
+	}
+	l := logger.New(false)
+	size := "XL"
+	update := goods.Update{Size: &size}
+	good := goods.Good{Id: 2, Name: "Test2", Size: "XL", UniqCode: 2}
+	body := func(values map[string]interface{}) string {
+		marshal, _ := json.Marshal(values)
+		return string(marshal)
+	}
+	withResult := func(good goods.Good, err error) *mock_registry.MockDb {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		m := mock_registry.NewMockDb(ctrl)
+		m.EXPECT().GoodUpdate(context.Background(), 2, update).Return(good, err).AnyTimes()
+		return m
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name:     "normal",
+			fields:   fields{registry: withResult(good, nil), log: l},
+			args:     args{method: "PATCH", path: "/goods/2", body: body(map[string]interface{}{"size": "XL"})},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": good,
+			},
+		}, {
+			name:     "good not found",
+			fields:   fields{registry: withResult(goods.Good{}, registry.ErrGoodNotFound), log: l},
+			args:     args{method: "PATCH", path: "/goods/2", body: body(map[string]interface{}{"size": "XL"})},
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeGoodNotFound,
+				"message":    "Good not found",
+			},
+		}, {
+			name:     "err from db",
+			fields:   fields{registry: withResult(goods.Good{}, errors.New("test")), log: l},
+			args:     args{method: "PATCH", path: "/goods/2", body: body(map[string]interface{}{"size": "XL"})},
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Can't update this good",
+			},
+		}, {
+			name:     "nothing to update",
+			fields:   fields{registry: withResult(good, nil), log: l},
+			args:     args{method: "PATCH", path: "/goods/2", body: body(map[string]interface{}{})},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeNothingToUpdate,
+				"message":    "Nothing to update",
+			},
+		}, {
+			name:     "invalid uniq_code",
+			fields:   fields{registry: withResult(good, nil), log: l},
+			args:     args{method: "PATCH", path: "/goods/abc", body: body(map[string]interface{}{"size": "XL"})},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "uniq_code", Reason: "must be an integer"}},
+				"message":    "Invalid uniq_code",
+			},
+		}, {
+			name:     "empty name",
+			fields:   fields{registry: withResult(good, nil), log: l},
+			args:     args{method: "PATCH", path: "/goods/2", body: body(map[string]interface{}{"name": ""})},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "name", Reason: "notblank"}},
+				"message":    "Validation failed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.PATCH(GoodRoute, h.Update)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.args.method, tt.args.path, strings.NewReader(tt.args.body))
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Get(t *testing.T) {
+	l := logger.New(false)
+	stock := goods.Stock{
+		Good:      goods.Good{Id: 1, Name: "Test1", Size: "L", UniqCode: 1},
+		Count:     25,
+		Reserved:  5,
+		Available: 10,
+		Storages: []goods.StorageStock{
+			{StorageId: 1, StorageAvailable: true, Count: 15, Reserved: 5, Available: 10},
+			{StorageId: 2, StorageAvailable: false, Count: 10},
+		},
+	}
+	tests := []struct {
+		name     string
+		registry registry.Db
+		path     string
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "normal",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				m := mock_registry.NewMockDb(ctrl)
+				m.EXPECT().GoodStock(context.Background(), 1).Return(stock, nil).AnyTimes()
+				return m
+			}(),
+			path:     "/goods/1",
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": stock,
+			},
+		}, {
+			name: "good not found",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				m := mock_registry.NewMockDb(ctrl)
+				m.EXPECT().GoodStock(context.Background(), 7).Return(goods.Stock{}, registry.ErrGoodNotFound).AnyTimes()
+				return m
+			}(),
+			path:     "/goods/7",
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeGoodNotFound,
+				"message":    "Good not found",
+			},
+		}, {
+			name: "err from db",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				m := mock_registry.NewMockDb(ctrl)
+				m.EXPECT().GoodStock(context.Background(), 1).Return(goods.Stock{}, errors.New("test")).AnyTimes()
+				return m
+			}(),
+			path:     "/goods/1",
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Internal server error",
+			},
+		}, {
+			name: "invalid uniq_code",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				return mock_registry.NewMockDb(ctrl)
+			}(),
+			path:     "/goods/abc",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "uniq_code", Reason: "must be an integer"}},
+				"message":    "Invalid uniq_code",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.registry,
+				log:      l,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.GET(GoodRoute, h.Get)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Restore(t *testing.T) {
+	l := logger.New(false)
+	good := goods.Good{Id: 1, Name: "Test1", Size: "L", UniqCode: 1}
+	tests := []struct {
+		name     string
+		registry registry.Db
+		path     string
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "normal",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				m := mock_registry.NewMockDb(ctrl)
+				m.EXPECT().GoodRestore(context.Background(), 1).Return(good, nil).AnyTimes()
+				return m
+			}(),
+			path:     "/goods/1/restore",
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": good,
+			},
+		}, {
+			name: "good not found",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				m := mock_registry.NewMockDb(ctrl)
+				m.EXPECT().GoodRestore(context.Background(), 7).Return(goods.Good{}, registry.ErrGoodNotFound).AnyTimes()
+				return m
+			}(),
+			path:     "/goods/7/restore",
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeGoodNotFound,
+				"message":    "Good not found",
+			},
+		}, {
+			name: "err from db",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				m := mock_registry.NewMockDb(ctrl)
+				m.EXPECT().GoodRestore(context.Background(), 1).Return(goods.Good{}, errors.New("test")).AnyTimes()
+				return m
+			}(),
+			path:     "/goods/1/restore",
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Can't restore this good",
+			},
+		}, {
+			name: "invalid uniq_code",
+			registry: func() *mock_registry.MockDb {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				return mock_registry.NewMockDb(ctrl)
+			}(),
+			path:     "/goods/abc/restore",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "uniq_code", Reason: "must be an integer"}},
+				"message":    "Invalid uniq_code",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.registry,
+				log:      l,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.POST(RestoreRoute, h.Restore)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, nil)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_DeleteByCode(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		path string
+	}
+	l := logger.New(false)
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "normal",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().GoodDelete(gomock.Any(), 1).Return(int64(1), nil).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{path: "/goods/1"},
+			wantCode: http.StatusOK,
+			wantRes: map[string]interface{}{
+				"code":    http.StatusOK,
+				"message": "OK",
+			},
+		}, {
+			name: "good reserved",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().GoodDelete(gomock.Any(), 1).Return(int64(0), fmt.Errorf("test: %w", registry.ErrGoodReserved)).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{path: "/goods/1"},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"error_code": apierror.CodeGoodReserved,
+				"message":    "Good has active reservations",
+			},
+		}, {
+			name:     "invalid uniq_code",
+			fields:   fields{registry: mock_registry.NewMockDb(gomock.NewController(t)), log: l},
+			args:     args{path: "/goods/abc"},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "uniq_code", Reason: "must be an integer"}},
+				"message":    "Invalid uniq_code",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.DELETE(GoodRoute, h.DeleteByCode)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", tt.args.path, nil)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package grpcapi
+
+import (
+	inventoryv1 "LamodaTest/api/inventory/v1"
+	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
+	"LamodaTest/internal/entity/storages"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sort"
+	"time"
+)
+
+func pageQuery(p *inventoryv1.PageQuery) pages.Query {
+	return pages.Query{Cursor: p.GetCursor(), Limit: int(p.GetLimit()), Sort: p.GetSort()}
+}
+
+func goodToProto(g goods.Good) *inventoryv1.Good {
+	res := &inventoryv1.Good{Id: int64(g.Id), Name: g.Name, Size: g.Size, UniqCode: int32(g.UniqCode)}
+	if g.DeletedAt != nil {
+		res.DeletedAt = timestamppb.New(*g.DeletedAt)
+	}
+	return res
+}
+
+func stockToProto(s goods.Stock) *inventoryv1.GoodStock {
+	res := &inventoryv1.GoodStock{
+		Good:      goodToProto(s.Good),
+		Count:     int32(s.Count),
+		Reserved:  int32(s.Reserved),
+		Available: int32(s.Available),
+	}
+	for _, st := range s.Storages {
+		res.Storages = append(res.Storages, &inventoryv1.StorageStock{
+			StorageId:        int32(st.StorageId),
+			StorageAvailable: st.StorageAvailable,
+			Count:            int32(st.Count),
+			Reserved:         int32(st.Reserved),
+			Available:        int32(st.Available),
+		})
+	}
+	return res
+}
+
+func storageToProto(s storages.Storage) *inventoryv1.Storage {
+	return &inventoryv1.Storage{
+		Id:        s.ID,
+		Name:      s.Name,
+		Available: s.Available,
+		Address:   s.Address,
+		Region:    s.Region,
+		Priority:  int32(s.Priority),
+		Capacity:  int32(s.Capacity),
+		Tags:      s.Tags,
+	}
+}
+
+func reserveRequest(l *inventoryv1.ReserveLine) reservations.Request {
+	req := reservations.Request{
+		UniqCode: int(l.GetUniqCode()),
+		Count:    int(l.GetCount()),
+		TTL:      time.Duration(l.GetTtlSeconds()) * time.Second,
+	}
+	for _, id := range l.GetStorages() {
+		req.Storages = append(req.Storages, int(id))
+	}
+	return req
+}
+
+func reservationToProto(r reservations.Reservation) *inventoryv1.Reservation {
+	res := &inventoryv1.Reservation{
+		Id:        r.ID,
+		UniqCode:  int32(r.UniqCode),
+		CreatedAt: timestamppb.New(r.CreatedAt),
+		Status:    r.Status,
+	}
+	if r.ExpiresAt != nil {
+		res.ExpiresAt = timestamppb.New(*r.ExpiresAt)
+	}
+	for _, line := range r.Lines {
+		res.Lines = append(res.Lines, &inventoryv1.ReservationLine{StorageId: int32(line.StorageId), Count: int32(line.Count)})
+	}
+	return res
+}
+
+// remainsToProto lists remains by uniq_code, the registry returns them keyed by it.
+func remainsToProto(list map[int]goods.RemainsDTO) []*inventoryv1.GoodRemains {
+	codes := make([]int, 0, len(list))
+	for uniqCode := range list {
+		codes = append(codes, uniqCode)
+	}
+	sort.Ints(codes)
+	res := make([]*inventoryv1.GoodRemains, 0, len(list))
+	for _, uniqCode := range codes {
+		r := list[uniqCode]
+		available := make(map[int32]int32, len(r.StorageAvailable))
+		for storageId, count := range r.StorageAvailable {
+			available[int32(storageId)] = int32(count)
+		}
+		res = append(res, &inventoryv1.GoodRemains{UniqCode: int32(uniqCode), Name: r.Name, Size: r.Size, StorageAvailable: available})
+	}
+	return res
+}
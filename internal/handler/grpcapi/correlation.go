@@ -0,0 +1,49 @@
+package grpcapi
+
+import (
+	"LamodaTest/internal/registry"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// CorrelationMetadata is the metadata key of the request ID, the counterpart of the X-Request-ID header of the HTTP API.
+const CorrelationMetadata = "x-request-id"
+
+// maxCorrelationLen matches stock_movements.correlation_id, longer client IDs are replaced.
+const maxCorrelationLen = 64
+
+// correlationUnary takes the request ID sent by the client or generates a new one, returns it in the response header
+// and puts it into the call context, so the registry writes it to the stock movement ledger.
+func correlationUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := correlationID(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(CorrelationMetadata, id))
+	return handler(registry.WithCorrelationID(ctx, id), req)
+}
+
+// correlationStream is correlationUnary for streaming calls.
+func correlationStream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id := correlationID(ss.Context())
+	_ = ss.SetHeader(metadata.Pairs(CorrelationMetadata, id))
+	return handler(srv, &correlatedStream{ServerStream: ss, ctx: registry.WithCorrelationID(ss.Context(), id)})
+}
+
+type correlatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *correlatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func correlationID(ctx context.Context) string {
+	if ids := metadata.ValueFromIncomingContext(ctx, CorrelationMetadata); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= maxCorrelationLen {
+		return ids[0]
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
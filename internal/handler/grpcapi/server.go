@@ -0,0 +1,279 @@
+package grpcapi
+
+import (
+	inventoryv1 "LamodaTest/api/inventory/v1"
+	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
+	"LamodaTest/internal/entity/storages"
+	"LamodaTest/internal/handler/apierror"
+	"LamodaTest/internal/registry"
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBatch bounds the lines of a reserve call, the same as the lines of REST batch requests.
+const maxBatch = 100
+
+// Server is the Inventory service over the registry the HTTP API uses.
+type Server struct {
+	inventoryv1.UnimplementedInventoryServer
+	registry registry.Db
+	log      logrus.FieldLogger
+}
+
+func NewServer(registry registry.Db, log logrus.FieldLogger) *Server {
+	return &Server{registry: registry, log: log}
+}
+
+// NewGRPCServer creates a gRPC server serving the Inventory service.
+// Every call carries the x-request-id correlation ID, taken from the client metadata or generated.
+func NewGRPCServer(reg registry.Db, log logrus.FieldLogger, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(correlationUnary),
+		grpc.ChainStreamInterceptor(correlationStream),
+	}, opts...)
+	srv := grpc.NewServer(opts...)
+	inventoryv1.RegisterInventoryServer(srv, NewServer(reg, log))
+	return srv
+}
+
+func (s *Server) AddGood(ctx context.Context, req *inventoryv1.AddGoodRequest) (*inventoryv1.AddGoodResponse, error) {
+	var v violations
+	name := strings.TrimSpace(req.GetName())
+	v.check(name != "", "name", "notblank")
+	v.check(utf8.RuneCountInString(name) <= 45, "name", "max=45")
+	v.check(goods.ValidSize(req.GetSize()), "size", "size")
+	v.check(req.GetUniqCode() > 0, "uniq_code", "gt=0")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	id, err := s.registry.GoodAdd(ctx, name, goods.NormalizeSize(req.GetSize()), int(req.GetUniqCode()))
+	if err != nil {
+		return nil, registryError(s.log, "AddGood", err, "Not added")
+	}
+	return &inventoryv1.AddGoodResponse{Id: id}, nil
+}
+
+func (s *Server) GetGood(ctx context.Context, req *inventoryv1.GetGoodRequest) (*inventoryv1.GoodStock, error) {
+	var v violations
+	v.check(req.GetUniqCode() > 0, "uniq_code", "gt=0")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	stock, err := s.registry.GoodStock(ctx, int(req.GetUniqCode()))
+	if err != nil {
+		return nil, registryError(s.log, "GetGood", err, "Internal server error")
+	}
+	return stockToProto(stock), nil
+}
+
+func (s *Server) ListGoods(ctx context.Context, req *inventoryv1.ListGoodsRequest) (*inventoryv1.ListGoodsResponse, error) {
+	var v violations
+	checkPage(&v, req.GetPage())
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	filter := goods.Filter{Name: req.GetName(), Size: goods.NormalizeSize(req.GetSize()), IncludeDeleted: req.GetIncludeDeleted()}
+	list, next, err := s.registry.Goods(ctx, filter, pageQuery(req.GetPage()))
+	if err != nil {
+		return nil, registryError(s.log, "ListGoods", err, "Internal server error")
+	}
+	res := &inventoryv1.ListGoodsResponse{NextCursor: next}
+	for _, g := range list {
+		res.Goods = append(res.Goods, goodToProto(g))
+	}
+	return res, nil
+}
+
+func (s *Server) AddStorage(ctx context.Context, req *inventoryv1.AddStorageRequest) (*inventoryv1.AddStorageResponse, error) {
+	var v violations
+	name := strings.TrimSpace(req.GetName())
+	v.check(name != "", "name", "notblank")
+	v.check(utf8.RuneCountInString(name) <= 45, "name", "max=45")
+	v.check(utf8.RuneCountInString(req.GetAddress()) <= 255, "address", "max=255")
+	v.check(utf8.RuneCountInString(req.GetRegion()) <= 64, "region", "max=64")
+	v.check(req.GetCapacity() >= 0, "capacity", "min=0")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	id, err := s.registry.StoragesAdd(ctx, storages.Storage{
+		Name:      name,
+		Available: req.GetAvailable(),
+		Address:   req.GetAddress(),
+		Region:    req.GetRegion(),
+		Priority:  int(req.GetPriority()),
+		Capacity:  int(req.GetCapacity()),
+		Tags:      req.GetTags(),
+	})
+	if err != nil {
+		return nil, registryError(s.log, "AddStorage", err, "Not added")
+	}
+	return &inventoryv1.AddStorageResponse{Id: id}, nil
+}
+
+func (s *Server) ListStorages(ctx context.Context, req *inventoryv1.ListStoragesRequest) (*inventoryv1.ListStoragesResponse, error) {
+	var v violations
+	checkPage(&v, req.GetPage())
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	filter := storages.Filter{Name: req.GetName(), Available: req.Available}
+	list, next, err := s.registry.Storages(ctx, filter, pageQuery(req.GetPage()))
+	if err != nil {
+		return nil, registryError(s.log, "ListStorages", err, "Internal server error")
+	}
+	res := &inventoryv1.ListStoragesResponse{NextCursor: next}
+	for _, st := range list {
+		res.Storages = append(res.Storages, storageToProto(st))
+	}
+	return res, nil
+}
+
+func (s *Server) SetStorageAccess(ctx context.Context, req *inventoryv1.SetStorageAccessRequest) (*inventoryv1.SetStorageAccessResponse, error) {
+	var v violations
+	v.check(req.GetId() > 0, "id", "gt=0")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	changed, err := s.registry.StoragesChangeAccess(ctx, int(req.GetId()), req.GetAvailable())
+	if err != nil {
+		return nil, registryError(s.log, "SetStorageAccess", err, "Can't change this storage")
+	}
+	return &inventoryv1.SetStorageAccessResponse{Changed: changed}, nil
+}
+
+// Reserve reserves every line on its own, failed lines get their `error_code`. An atomic call reserves
+// every line or fails with the error of the first line that can't be reserved.
+func (s *Server) Reserve(ctx context.Context, req *inventoryv1.ReserveRequest) (*inventoryv1.ReserveResponse, error) {
+	var v violations
+	v.check(len(req.GetLines()) > 0, "lines", "min=1")
+	v.check(len(req.GetLines()) <= maxBatch, "lines", "max=100")
+	for i, line := range req.GetLines() {
+		v.check(line.GetUniqCode() > 0, lineField(i, "uniq_code"), "gt=0")
+		v.check(line.GetCount() > 0, lineField(i, "count"), "gt=0")
+		v.check(line.GetTtlSeconds() >= 0, lineField(i, "ttl_seconds"), "min=0")
+		for _, id := range line.GetStorages() {
+			v.check(id > 0, lineField(i, "storages"), "gt=0")
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	reqs := make([]reservations.Request, 0, len(req.GetLines()))
+	for _, line := range req.GetLines() {
+		reqs = append(reqs, reserveRequest(line))
+	}
+	if req.GetAtomic() {
+		return s.reserveAtomic(ctx, reqs)
+	}
+	res := &inventoryv1.ReserveResponse{}
+	for _, r := range reqs {
+		result := &inventoryv1.ReserveResult{UniqCode: int32(r.UniqCode)}
+		reservation, err := s.registry.ReserveGood(ctx, r)
+		if err != nil {
+			s.log.WithField("method", "Reserve").Warn(err)
+			result.ErrorCode = apierror.From(err, "").Code
+		} else {
+			result.Reservation = reservationToProto(reservation)
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res, nil
+}
+
+func (s *Server) reserveAtomic(ctx context.Context, reqs []reservations.Request) (*inventoryv1.ReserveResponse, error) {
+	reserved, err := s.registry.ReserveGoods(ctx, reqs)
+	var batchErr *registry.BatchError
+	if errors.As(err, &batchErr) {
+		for _, lineErr := range batchErr.Errors {
+			if lineErr != nil {
+				return nil, registryError(s.log, "Reserve", lineErr, "Nothing is reserved")
+			}
+		}
+	}
+	if err != nil {
+		return nil, registryError(s.log, "Reserve", err, "Nothing is reserved")
+	}
+	res := &inventoryv1.ReserveResponse{}
+	for _, reservation := range reserved {
+		res.Results = append(res.Results, &inventoryv1.ReserveResult{
+			UniqCode:    int32(reservation.UniqCode),
+			Reservation: reservationToProto(reservation),
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) Release(ctx context.Context, req *inventoryv1.ReleaseRequest) (*inventoryv1.Reservation, error) {
+	var v violations
+	v.check(req.GetReservationId() > 0, "reservation_id", "gt=0")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	reservation, err := s.registry.ReleaseGood(ctx, req.GetReservationId())
+	if err != nil {
+		return nil, registryError(s.log, "Release", err, "Can't release this reservation")
+	}
+	return reservationToProto(reservation), nil
+}
+
+func (s *Server) Remains(ctx context.Context, req *inventoryv1.RemainsRequest) (*inventoryv1.RemainsResponse, error) {
+	filter, err := remainsFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	list, next, err := s.registry.AvailableGoods(ctx, filter, pageQuery(req.GetPage()))
+	if err != nil {
+		return nil, registryError(s.log, "Remains", err, "Internal server error")
+	}
+	return &inventoryv1.RemainsResponse{Remains: remainsToProto(list), NextCursor: next}, nil
+}
+
+// StreamRemains sends the remains page by page, so a large catalog is never held in memory at once.
+// The page limit of the request sets the size of the pages read from the registry.
+func (s *Server) StreamRemains(req *inventoryv1.RemainsRequest, stream inventoryv1.Inventory_StreamRemainsServer) error {
+	filter, err := remainsFilter(req)
+	if err != nil {
+		return err
+	}
+	query := pageQuery(req.GetPage())
+	for {
+		list, next, err := s.registry.AvailableGoods(stream.Context(), filter, query)
+		if err != nil {
+			return registryError(s.log, "StreamRemains", err, "Internal server error")
+		}
+		for _, remains := range remainsToProto(list) {
+			if err = stream.Send(remains); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Cursor = next
+	}
+}
+
+func remainsFilter(req *inventoryv1.RemainsRequest) (goods.Filter, error) {
+	var v violations
+	checkPage(&v, req.GetPage())
+	v.check(req.GetStorageId() >= 0, "storage_id", "min=0")
+	v.check(req.GetMinAvailable() >= 0, "min_available", "min=0")
+	filter := goods.Filter{
+		Name:         req.GetName(),
+		Size:         goods.NormalizeSize(req.GetSize()),
+		StorageId:    int(req.GetStorageId()),
+		MinAvailable: int(req.GetMinAvailable()),
+	}
+	return filter, v.err()
+}
+
+func checkPage(v *violations, p *inventoryv1.PageQuery) {
+	v.check(p.GetLimit() >= 0, "page.limit", "min=0")
+	v.check(p.GetLimit() <= pages.MaxLimit, "page.limit", "max=1000")
+}
@@ -0,0 +1,351 @@
+package grpcapi
+
+import (
+	inventoryv1 "LamodaTest/api/inventory/v1"
+	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
+	"LamodaTest/internal/logger"
+	"LamodaTest/internal/registry"
+	mock_registry "LamodaTest/internal/registry/mocks"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// dial serves the Inventory service over an in-memory listener and returns a client of it.
+func dial(t *testing.T, reg registry.Db) inventoryv1.InventoryClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := NewGRPCServer(reg, logger.New(false))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return inventoryv1.NewInventoryClient(conn)
+}
+
+// errorCode is the reason of the ErrorInfo attached to err.
+func errorCode(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+func TestServer(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name          string
+		mock          func(m *mock_registry.MockDb)
+		call          func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error)
+		want          proto.Message
+		wantCode      codes.Code
+		wantErrorCode string
+		wantFields    []string
+	}{
+		{
+			name: "add good",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().GoodAdd(gomock.Any(), "test", "L", 7).Return(int64(3), nil)
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.AddGood(ctx, &inventoryv1.AddGoodRequest{Name: " test ", Size: "l", UniqCode: 7})
+			},
+			want: &inventoryv1.AddGoodResponse{Id: 3},
+		}, {
+			name: "add duplicate good",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().GoodAdd(gomock.Any(), "test", "L", 7).Return(int64(0), &registry.DuplicateUniqCodeError{UniqCode: 7, GoodId: 3})
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.AddGood(ctx, &inventoryv1.AddGoodRequest{Name: "test", Size: "L", UniqCode: 7})
+			},
+			wantCode:      codes.AlreadyExists,
+			wantErrorCode: "duplicate_uniq_code",
+		}, {
+			name: "add invalid good",
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.AddGood(ctx, &inventoryv1.AddGoodRequest{Name: " ", Size: "XXXXL"})
+			},
+			wantCode:      codes.InvalidArgument,
+			wantErrorCode: "validation_failed",
+			wantFields:    []string{"name", "size", "uniq_code"},
+		}, {
+			name: "get good",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().GoodStock(gomock.Any(), 7).Return(goods.Stock{
+					Good:  goods.Good{Id: 3, Name: "test", Size: "L", UniqCode: 7},
+					Count: 5, Reserved: 2, Available: 3,
+					Storages: []goods.StorageStock{{StorageId: 1, StorageAvailable: true, Count: 5, Reserved: 2, Available: 3}},
+				}, nil)
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.GetGood(ctx, &inventoryv1.GetGoodRequest{UniqCode: 7})
+			},
+			want: &inventoryv1.GoodStock{
+				Good:  &inventoryv1.Good{Id: 3, Name: "test", Size: "L", UniqCode: 7},
+				Count: 5, Reserved: 2, Available: 3,
+				Storages: []*inventoryv1.StorageStock{{StorageId: 1, StorageAvailable: true, Count: 5, Reserved: 2, Available: 3}},
+			},
+		}, {
+			name: "good not found",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().GoodStock(gomock.Any(), 7).Return(goods.Stock{}, fmt.Errorf("can't get stock of good 7: %w", registry.ErrGoodNotFound))
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.GetGood(ctx, &inventoryv1.GetGoodRequest{UniqCode: 7})
+			},
+			wantCode:      codes.NotFound,
+			wantErrorCode: "good_not_found",
+		}, {
+			name: "reserve",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReserveGood(gomock.Any(), reservations.Request{UniqCode: 7, Count: 2, TTL: time.Minute, Storages: []int{1}}).
+					Return(reservations.Reservation{ID: 10, UniqCode: 7, Lines: []reservations.Line{{StorageId: 1, Count: 2}},
+						CreatedAt: created, Status: reservations.StatusActive}, nil)
+				m.EXPECT().ReserveGood(gomock.Any(), reservations.Request{UniqCode: 8, Count: 1}).
+					Return(reservations.Reservation{}, fmt.Errorf("can't reserve: %w", registry.ErrInsufficientStock))
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.Reserve(ctx, &inventoryv1.ReserveRequest{Lines: []*inventoryv1.ReserveLine{
+					{UniqCode: 7, Count: 2, TtlSeconds: 60, Storages: []int32{1}},
+					{UniqCode: 8, Count: 1},
+				}})
+			},
+			want: &inventoryv1.ReserveResponse{Results: []*inventoryv1.ReserveResult{
+				{UniqCode: 7, Reservation: &inventoryv1.Reservation{Id: 10, UniqCode: 7, Status: reservations.StatusActive,
+					Lines: []*inventoryv1.ReservationLine{{StorageId: 1, Count: 2}}, CreatedAt: timestamppb.New(created)}},
+				{UniqCode: 8, ErrorCode: "insufficient_stock"},
+			}},
+		}, {
+			name: "reserve atomically",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReserveGoods(gomock.Any(), []reservations.Request{{UniqCode: 7, Count: 2}, {UniqCode: 8, Count: 1}}).
+					Return(nil, &registry.BatchError{Errors: []error{nil, registry.ErrInsufficientStock}})
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.Reserve(ctx, &inventoryv1.ReserveRequest{Atomic: true, Lines: []*inventoryv1.ReserveLine{
+					{UniqCode: 7, Count: 2},
+					{UniqCode: 8, Count: 1},
+				}})
+			},
+			wantCode:      codes.FailedPrecondition,
+			wantErrorCode: "insufficient_stock",
+		}, {
+			name: "reserve invalid lines",
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.Reserve(ctx, &inventoryv1.ReserveRequest{Lines: []*inventoryv1.ReserveLine{
+					{UniqCode: 7, Count: 2},
+					{UniqCode: 8, TtlSeconds: -1},
+				}})
+			},
+			wantCode:      codes.InvalidArgument,
+			wantErrorCode: "validation_failed",
+			wantFields:    []string{"lines[1].count", "lines[1].ttl_seconds"},
+		}, {
+			name: "release closed reservation",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReleaseGood(gomock.Any(), int64(10)).
+					Return(reservations.Reservation{}, fmt.Errorf("can't release: %w", registry.ErrReservationClosed))
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.Release(ctx, &inventoryv1.ReleaseRequest{ReservationId: 10})
+			},
+			wantCode:      codes.FailedPrecondition,
+			wantErrorCode: "reservation_closed",
+		}, {
+			name: "remains",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().AvailableGoods(gomock.Any(), goods.Filter{Size: "M", StorageId: 1}, pages.Query{Limit: 2}).
+					Return(map[int]goods.RemainsDTO{
+						8: {Name: "b", Size: "M", StorageAvailable: map[int]int{1: 4}},
+						7: {Name: "a", Size: "L", StorageAvailable: map[int]int{1: 3}},
+					}, "next", nil)
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.Remains(ctx, &inventoryv1.RemainsRequest{Page: &inventoryv1.PageQuery{Limit: 2}, StorageId: 1, Size: "m"})
+			},
+			want: &inventoryv1.RemainsResponse{NextCursor: "next", Remains: []*inventoryv1.GoodRemains{
+				{UniqCode: 7, Name: "a", Size: "L", StorageAvailable: map[int32]int32{1: 3}},
+				{UniqCode: 8, Name: "b", Size: "M", StorageAvailable: map[int32]int32{1: 4}},
+			}},
+		}, {
+			name: "internal error",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().StoragesChangeAccess(gomock.Any(), 1, true).Return(int64(0), errors.New("connection refused"))
+			},
+			call: func(ctx context.Context, c inventoryv1.InventoryClient) (proto.Message, error) {
+				return c.SetStorageAccess(ctx, &inventoryv1.SetStorageAccessRequest{Id: 1, Available: true})
+			},
+			wantCode:      codes.Internal,
+			wantErrorCode: "internal_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mock_registry.NewMockDb(ctrl)
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			client := dial(t, m)
+
+			got, err := tt.call(context.Background(), client)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantErrorCode, errorCode(err))
+				var fields []string
+				for _, d := range status.Convert(err).Details() {
+					if br, ok := d.(*errdetails.BadRequest); ok {
+						for _, v := range br.GetFieldViolations() {
+							fields = append(fields, v.GetField())
+						}
+					}
+				}
+				assert.Equal(t, tt.wantFields, fields)
+				return
+			}
+			assert.True(t, proto.Equal(tt.want, got), "got %v", got)
+		})
+	}
+}
+
+func TestServer_StreamRemains(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mock_registry.NewMockDb(ctrl)
+	gomock.InOrder(
+		m.EXPECT().AvailableGoods(gomock.Any(), goods.Filter{MinAvailable: 1}, pages.Query{Limit: 2}).
+			Return(map[int]goods.RemainsDTO{
+				1: {Name: "a", Size: "S", StorageAvailable: map[int]int{1: 1}},
+				2: {Name: "b", Size: "M", StorageAvailable: map[int]int{1: 2}},
+			}, "page2", nil),
+		m.EXPECT().AvailableGoods(gomock.Any(), goods.Filter{MinAvailable: 1}, pages.Query{Limit: 2, Cursor: "page2"}).
+			Return(map[int]goods.RemainsDTO{
+				3: {Name: "c", Size: "L", StorageAvailable: map[int]int{2: 3}},
+			}, "", nil),
+	)
+	client := dial(t, m)
+
+	stream, err := client.StreamRemains(context.Background(),
+		&inventoryv1.RemainsRequest{Page: &inventoryv1.PageQuery{Limit: 2}, MinAvailable: 1})
+	require.NoError(t, err)
+	var got []int32
+	for {
+		remains, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, remains.GetUniqCode())
+	}
+	assert.Equal(t, []int32{1, 2, 3}, got)
+}
+
+func TestServer_StreamRemains_error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mock_registry.NewMockDb(ctrl)
+	m.EXPECT().AvailableGoods(gomock.Any(), goods.Filter{}, pages.Query{Cursor: "broken"}).
+		Return(nil, "", fmt.Errorf("%w: bad cursor", registry.ErrInvalidQuery))
+	client := dial(t, m)
+
+	stream, err := client.StreamRemains(context.Background(), &inventoryv1.RemainsRequest{Page: &inventoryv1.PageQuery{Cursor: "broken"}})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "invalid_query", errorCode(err))
+}
+
+func TestServer_correlationID(t *testing.T) {
+	reserved := reservations.Reservation{ID: 10, UniqCode: 7, Status: reservations.StatusActive}
+	tests := []struct {
+		name string
+		sent string
+		want func(t *testing.T, id string)
+	}{
+		{
+			name: "sent by the client",
+			sent: "req-1",
+			want: func(t *testing.T, id string) {
+				assert.Equal(t, "req-1", id)
+			},
+		}, {
+			name: "generated",
+			want: func(t *testing.T, id string) {
+				assert.Len(t, id, 32)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mock_registry.NewMockDb(ctrl)
+			var got string
+			m.EXPECT().ReleaseGood(gomock.Any(), int64(10)).DoAndReturn(func(ctx context.Context, _ int64) (reservations.Reservation, error) {
+				got = registry.CorrelationID(ctx)
+				return reserved, nil
+			})
+			client := dial(t, m)
+
+			ctx := context.Background()
+			if tt.sent != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, CorrelationMetadata, tt.sent)
+			}
+			var header metadata.MD
+			_, err := client.Release(ctx, &inventoryv1.ReleaseRequest{ReservationId: 10}, grpc.Header(&header))
+			require.NoError(t, err)
+			tt.want(t, got)
+			assert.Equal(t, []string{got}, header.Get(CorrelationMetadata))
+		})
+	}
+}
+
+func TestServer_StreamRemains_correlationID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mock_registry.NewMockDb(ctrl)
+	var got string
+	m.EXPECT().AvailableGoods(gomock.Any(), goods.Filter{}, pages.Query{}).
+		DoAndReturn(func(ctx context.Context, _ goods.Filter, _ pages.Query) (map[int]goods.RemainsDTO, string, error) {
+			got = registry.CorrelationID(ctx)
+			return nil, "", nil
+		})
+	client := dial(t, m)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), CorrelationMetadata, "req-2")
+	stream, err := client.StreamRemains(ctx, &inventoryv1.RemainsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+	header, err := stream.Header()
+	require.NoError(t, err)
+	assert.Equal(t, "req-2", got)
+	assert.Equal(t, []string{"req-2"}, header.Get(CorrelationMetadata))
+}
@@ -0,0 +1,80 @@
+package grpcapi
+
+import (
+	"LamodaTest/internal/handler/apierror"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// Domain of the google.rpc.ErrorInfo attached to failed calls.
+const Domain = "inventory.v1"
+
+// grpcCode tells the gRPC code of the API error, the HTTP status alone is too coarse for 409 and 422.
+func grpcCode(e apierror.Error) codes.Code {
+	switch {
+	case e.Code == apierror.CodeDuplicateUniqCode:
+		return codes.AlreadyExists
+	case e.Code == apierror.CodeConflict:
+		return codes.Aborted
+	case e.Code == apierror.CodeInvalidArgument || e.Code == apierror.CodeInvalidQuery:
+		return codes.InvalidArgument
+	case e.Status == http.StatusNotFound:
+		return codes.NotFound
+	case e.Status == http.StatusConflict || e.Status == http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+// registryError logs err and maps it the way the HTTP API does, the `error_code` goes to the ErrorInfo reason.
+func registryError(log logrus.FieldLogger, method string, err error, fallback string) error {
+	e := apierror.From(err, fallback)
+	log = log.WithField("method", method)
+	if e.Internal() {
+		log.Error(err)
+	} else {
+		log.Warn(err)
+	}
+	st := status.New(grpcCode(e), e.Message)
+	detailed, detailsErr := st.WithDetails(&errdetails.ErrorInfo{Reason: e.Code, Domain: Domain})
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// violations collects the fields of a request that failed its rules, they are reported all at once.
+type violations []*errdetails.BadRequest_FieldViolation
+
+func (v *violations) check(ok bool, field string, rule string) {
+	if !ok {
+		*v = append(*v, &errdetails.BadRequest_FieldViolation{Field: field, Description: rule})
+	}
+}
+
+// err is nil when every rule passed, otherwise InvalidArgument with `validation_failed`
+// and the failed fields in a google.rpc.BadRequest.
+func (v violations) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	st := status.New(codes.InvalidArgument, "Validation failed")
+	detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{Reason: apierror.CodeValidationFailed, Domain: Domain},
+		&errdetails.BadRequest{FieldViolations: v},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// lineField names field of the line i of a batch request.
+func lineField(i int, field string) string {
+	return fmt.Sprintf("lines[%d].%s", i, field)
+}
@@ -1,49 +1,117 @@
 package handler
 
 import (
+	"LamodaTest/internal/handler/apierror"
 	"LamodaTest/internal/handler/goods"
+	"LamodaTest/internal/handler/openapi"
+	"LamodaTest/internal/handler/rpc"
 	"LamodaTest/internal/handler/storages"
 	"LamodaTest/internal/registry"
-	"database/sql"
+	"expvar"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
-func Router(log *logrus.Logger, debug bool, db *sql.DB) *gin.Engine {
+// MetricsRoute serves expvar metrics, transaction retries of the registry among them.
+// It is served by Metrics on the internal listener, not by the public router.
+const MetricsRoute = "/debug/vars"
+
+// APIPrefix is the prefix of the resource routes, the routes of goods and storages without it are deprecated aliases.
+const APIPrefix = "/api/v1"
+
+func Router(log *logrus.Logger, debug bool, reg registry.Db) *gin.Engine {
 	if !debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
-	router.Use(gin.LoggerWithWriter(log.Writer()))
+	router.HandleMethodNotAllowed = true
+	router.Use(gin.LoggerWithWriter(log.Writer()), correlationID)
 
-	reg := registry.New(db)
 	goodH := goods.NewHandler(reg, log)
 	storageH := storages.NewHandler(reg, log)
+	rpcH := rpc.NewHandler(reg, log)
 	router.NoRoute(notFound)
 	router.NoMethod(notAllowed)
 
-	router.PUT(goods.AddRoute, goodH.Add)
-	router.DELETE(goods.DeleteRoute, goodH.Delete)
-	router.POST(goods.ReserveRoute, goodH.Reserve)
-	router.POST(goods.ReleaseRoute, goodH.Release)
-	router.GET(goods.RemainsRoute, goodH.Remains)
-	router.GET(goods.AllRoute, goodH.All)
+	v1 := router.Group(APIPrefix)
+	v1.POST(goods.GoodsRoute, goodH.Add)
+	v1.GET(goods.GoodsRoute, goodH.All)
+	v1.GET(goods.RemainsRoute, goodH.Remains)
+	v1.GET(goods.GoodRoute, goodH.Get)
+	v1.PATCH(goods.GoodRoute, goodH.Update)
+	v1.DELETE(goods.GoodRoute, goodH.DeleteByCode)
+	v1.POST(goods.RestoreRoute, goodH.Restore)
+	v1.GET(goods.MovementsRoute, goodH.Movements)
+	v1.POST(goods.ReservationsRoute, goodH.Reserve)
+	v1.POST(goods.ReleasesRoute, goodH.Release)
+	v1.POST(goods.ShipmentsRoute, goodH.Ship)
+	v1.POST(goods.ReceiptsRoute, goodH.Receive)
+
+	v1.POST(storages.StoragesRoute, storageH.Add)
+	v1.GET(storages.StoragesRoute, storageH.All)
+	v1.PATCH(storages.StorageRoute, storageH.Update)
+	v1.DELETE(storages.StorageRoute, storageH.DeleteById)
+	v1.PUT(storages.AvailabilityRoute, storageH.SetAvailability)
+	v1.POST(storages.TransfersRoute, storageH.Transfer)
+
+	router.PUT(goods.AddRoute, deprecated(goods.GoodsRoute), goodH.Add)
+	router.DELETE(goods.DeleteRoute, deprecated(goods.GoodsRoute), goodH.Delete)
+	router.POST(goods.ReserveRoute, deprecated(goods.ReservationsRoute), goodH.Reserve)
+	router.POST(goods.ReleaseRoute, deprecated(goods.ReleasesRoute), goodH.Release)
+	router.POST(goods.ShipRoute, deprecated(goods.ShipmentsRoute), goodH.Ship)
+	router.POST(goods.ReceiveRoute, deprecated(goods.ReceiptsRoute), goodH.Receive)
+	router.GET(goods.RemainsRoute, deprecated(goods.RemainsRoute), goodH.Remains)
+	router.GET(goods.AllRoute, deprecated(goods.GoodsRoute), goodH.All)
+	router.GET(goods.MovementsRoute, deprecated(goods.MovementsRoute), goodH.Movements)
+	router.GET(goods.GoodRoute, deprecated(goods.GoodRoute), goodH.Get)
+	router.PATCH(goods.GoodRoute, deprecated(goods.GoodRoute), goodH.Update)
+	router.POST(goods.RestoreRoute, deprecated(goods.RestoreRoute), goodH.Restore)
+
+	router.PUT(storages.AddRoute, deprecated(storages.StoragesRoute), storageH.Add)
+	router.DELETE(storages.DeleteRoute, deprecated(storages.StoragesRoute), storageH.Delete)
+	router.GET(storages.AvailableRoute, deprecated(storages.StoragesRoute+"?available=true"), storageH.Available)
+	router.GET(storages.AllRoute, deprecated(storages.StoragesRoute), storageH.All)
+	router.POST(storages.AccessStatus, deprecated(storages.StoragesRoute), storageH.ChangeAccess)
+	router.POST(storages.TransferRoute, deprecated(storages.TransfersRoute), storageH.Transfer)
+	router.PATCH(storages.UpdateRoute, deprecated(storages.StorageRoute), storageH.Update)
+
+	router.POST(rpc.Route, rpcH.Serve)
 
-	router.PUT(storages.AddRoute, storageH.Add)
-	router.DELETE(storages.DeleteRoute, storageH.Delete)
-	router.GET(storages.AvailableRoute, storageH.Available)
-	router.GET(storages.AllRoute, storageH.All)
-	router.POST(storages.AccessStatus, storageH.ChangeAccess)
+	router.GET(openapi.Route, openapi.Spec)
+	router.GET(openapi.DocsRoute, openapi.Docs)
 
 	return router
 }
 
+// Metrics is the handler of the internal listener, it serves MetricsRoute only.
+func Metrics() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsRoute, expvar.Handler())
+	return mux
+}
+
+// deprecated marks the responses of a legacy route with the `Deprecation` header and links the route replacing it.
+// Parameters of successor are filled from the legacy route, routes taking them in the body link the collection.
+func deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link := APIPrefix + successor
+		for _, p := range c.Params {
+			link = strings.ReplaceAll(link, ":"+p.Key, url.PathEscape(p.Value))
+		}
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, link))
+		c.Next()
+	}
+}
+
 func notFound(c *gin.Context) {
-	c.JSON(http.StatusNotFound, gin.H{"code": http.StatusNotFound, "message": "page not found"})
+	apierror.Write(c, apierror.Error{Status: http.StatusNotFound, Code: apierror.CodeRouteNotFound, Message: "page not found"}, nil)
 }
 
 func notAllowed(c *gin.Context) {
-	c.JSON(http.StatusMethodNotAllowed, gin.H{"code": http.StatusMethodNotAllowed, "message": "page not found"})
+	apierror.Write(c, apierror.Error{Status: http.StatusMethodNotAllowed, Code: apierror.CodeMethodNotAllowed, Message: "method not allowed"}, nil)
 }
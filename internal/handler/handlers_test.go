@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/handler/apierror"
+	"LamodaTest/internal/handler/openapi"
+	"LamodaTest/internal/logger"
+	"LamodaTest/internal/registry"
+	mock_registry "LamodaTest/internal/registry/mocks"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter_errors(t *testing.T) {
+	type args struct {
+		method string
+		path   string
+		accept string
+		body   string
+	}
+	tests := []struct {
+		name            string
+		args            args
+		wantCode        int
+		wantContentType string
+		wantRes         any
+	}{
+		{
+			name:            "unknown route",
+			args:            args{method: "GET", path: "/goods/unknown/route"},
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json; charset=utf-8",
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeRouteNotFound,
+				"message":    "page not found",
+			},
+		}, {
+			name:            "method not allowed",
+			args:            args{method: "DELETE", path: "/storages/all"},
+			wantCode:        http.StatusMethodNotAllowed,
+			wantContentType: "application/json; charset=utf-8",
+			wantRes: map[string]interface{}{
+				"code":       http.StatusMethodNotAllowed,
+				"error_code": apierror.CodeMethodNotAllowed,
+				"message":    "method not allowed",
+			},
+		}, {
+			name:            "metrics are not public",
+			args:            args{method: "GET", path: MetricsRoute},
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json; charset=utf-8",
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeRouteNotFound,
+				"message":    "page not found",
+			},
+		}, {
+			name:            "unknown route as problem",
+			args:            args{method: "GET", path: "/goods/unknown/route", accept: "application/problem+json"},
+			wantCode:        http.StatusNotFound,
+			wantContentType: apierror.ProblemContentType,
+			wantRes: apierror.Problem{
+				Type:     "/problems/route_not_found",
+				Title:    "Page not found",
+				Status:   http.StatusNotFound,
+				Detail:   "page not found",
+				Instance: "/goods/unknown/route",
+				Code:     apierror.CodeRouteNotFound,
+			},
+		}, {
+			name:            "good not found as problem",
+			args:            args{method: "GET", path: "/goods/5?x=1", accept: "application/json, application/problem+json"},
+			wantCode:        http.StatusNotFound,
+			wantContentType: apierror.ProblemContentType,
+			wantRes: apierror.Problem{
+				Type:     "/problems/good_not_found",
+				Title:    "Good not found",
+				Status:   http.StatusNotFound,
+				Detail:   "Good not found",
+				Instance: "/goods/5?x=1",
+				Code:     apierror.CodeGoodNotFound,
+			},
+		}, {
+			name:            "problem refused with zero quality",
+			args:            args{method: "GET", path: "/goods/5", accept: "application/problem+json;q=0, application/json"},
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json; charset=utf-8",
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeGoodNotFound,
+				"message":    "Good not found",
+			},
+		}, {
+			name:            "validation errors as problem",
+			args:            args{method: "PUT", path: "/goods/add", accept: "application/problem+json", body: `{"name": "test"}`},
+			wantCode:        http.StatusUnprocessableEntity,
+			wantContentType: apierror.ProblemContentType,
+			wantRes: apierror.Problem{
+				Type:     "/problems/validation_failed",
+				Title:    "Validation failed",
+				Status:   http.StatusUnprocessableEntity,
+				Detail:   "Validation failed",
+				Instance: "/goods/add",
+				Code:     apierror.CodeValidationFailed,
+				Errors: []apierror.FieldError{
+					{Field: "size", Reason: "required"},
+					{Field: "uniq_code", Reason: "required"},
+				},
+			},
+		}, {
+			name:            "wrong type as problem",
+			args:            args{method: "POST", path: "/storages/transfer", accept: "application/problem+json", body: `{"uniq_code": "one"}`},
+			wantCode:        http.StatusUnprocessableEntity,
+			wantContentType: apierror.ProblemContentType,
+			wantRes: apierror.Problem{
+				Type:     "/problems/validation_failed",
+				Title:    "Validation failed",
+				Status:   http.StatusUnprocessableEntity,
+				Detail:   "Validation failed",
+				Instance: "/storages/transfer",
+				Code:     apierror.CodeValidationFailed,
+				Errors:   []apierror.FieldError{{Field: "uniq_code", Reason: "must be int"}},
+			},
+		}, {
+			name:            "broken json as problem",
+			args:            args{method: "POST", path: "/storages/transfer", accept: "application/problem+json", body: `{"uniq_code": `},
+			wantCode:        http.StatusBadRequest,
+			wantContentType: apierror.ProblemContentType,
+			wantRes: apierror.Problem{
+				Type:     "/problems/invalid_json",
+				Title:    "Invalid JSON",
+				Status:   http.StatusBadRequest,
+				Detail:   "Invalid JSON",
+				Instance: "/storages/transfer",
+				Code:     apierror.CodeInvalidJSON,
+			},
+		}, {
+			name:            "invalid path parameter as problem",
+			args:            args{method: "GET", path: "/goods/abc", accept: "application/problem+json"},
+			wantCode:        http.StatusBadRequest,
+			wantContentType: apierror.ProblemContentType,
+			wantRes: apierror.Problem{
+				Type:     "/problems/invalid_param",
+				Title:    "Invalid path parameter",
+				Status:   http.StatusBadRequest,
+				Detail:   "Invalid uniq_code",
+				Instance: "/goods/abc",
+				Code:     apierror.CodeInvalidParam,
+				Errors:   []apierror.FieldError{{Field: "uniq_code", Reason: "must be an integer"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			reg := mock_registry.NewMockDb(ctrl)
+			reg.EXPECT().GoodStock(gomock.Any(), 5).
+				Return(goods.Stock{}, fmt.Errorf("can't get stock of good 5: %w", registry.ErrGoodNotFound)).AnyTimes()
+			router := Router(logger.New(false), false, reg)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequestWithContext(context.Background(), tt.args.method, tt.args.path, strings.NewReader(tt.args.body))
+			if tt.args.accept != "" {
+				req.Header.Set("Accept", tt.args.accept)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", MetricsRoute, nil)
+	Metrics().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"registry_tx_retries"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", openapi.Route, nil)
+	Metrics().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestRouter_openapi fails when a route is registered without an entry in the OpenAPI document.
+func TestRouter_openapi(t *testing.T) {
+	router := Router(logger.New(false), false, mock_registry.NewMockDb(gomock.NewController(t)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", openapi.Route, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	for _, route := range router.Routes() {
+		key := route.Method + " " + openapiPath(route.Path)
+		assert.True(t, documented[key], "route %s is not described in %s", key, openapi.Route)
+		delete(documented, key)
+	}
+	assert.Empty(t, documented, "described routes are not registered")
+}
+
+// openapiPath turns the gin parameters of path into OpenAPI templates, `/goods/:uniq_code` into `/goods/{uniq_code}`.
+func openapiPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestRouter_docs(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		wantCode        int
+		wantContentType string
+	}{
+		{name: "redirect to the page", path: "/docs", wantCode: http.StatusMovedPermanently},
+		{name: "page", path: "/docs/", wantCode: http.StatusOK, wantContentType: "text/html; charset=utf-8"},
+		{name: "asset", path: "/docs/swagger-ui-bundle.js", wantCode: http.StatusOK, wantContentType: "text/javascript; charset=utf-8"},
+		{name: "unknown asset", path: "/docs/unknown.js", wantCode: http.StatusNotFound, wantContentType: "application/json; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := Router(logger.New(false), false, mock_registry.NewMockDb(gomock.NewController(t)))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequestWithContext(context.Background(), "GET", tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestRouter_deprecated(t *testing.T) {
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		wantDeprecation string
+		wantLink        string
+	}{
+		{
+			name:            "legacy route with parameters",
+			method:          "GET",
+			path:            "/goods/5",
+			wantDeprecation: "true",
+			wantLink:        `</api/v1/goods/5>; rel="successor-version"`,
+		}, {
+			name:            "legacy route with the parameters in the body",
+			method:          "DELETE",
+			path:            "/goods/delete",
+			wantDeprecation: "true",
+			wantLink:        `</api/v1/goods>; rel="successor-version"`,
+		}, {
+			name:            "legacy route with a filter",
+			method:          "GET",
+			path:            "/storages/available?limit=x",
+			wantDeprecation: "true",
+			wantLink:        `</api/v1/storages?available=true>; rel="successor-version"`,
+		}, {
+			name:   "resource route",
+			method: "GET",
+			path:   "/api/v1/goods/5",
+		}, {
+			name:   "unknown route",
+			method: "GET",
+			path:   "/goods/5/unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			reg := mock_registry.NewMockDb(ctrl)
+			reg.EXPECT().GoodStock(gomock.Any(), 5).
+				Return(goods.Stock{}, fmt.Errorf("can't get stock of good 5: %w", registry.ErrGoodNotFound)).AnyTimes()
+			router := Router(logger.New(false), false, reg)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequestWithContext(context.Background(), tt.method, tt.path, strings.NewReader(`{}`))
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantDeprecation, w.Header().Get("Deprecation"))
+			assert.Equal(t, tt.wantLink, w.Header().Get("Link"))
+		})
+	}
+}
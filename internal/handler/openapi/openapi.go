@@ -0,0 +1,44 @@
+package openapi
+
+import (
+	"LamodaTest/internal/handler/apierror"
+	_ "embed"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	"net/http"
+)
+
+const (
+	Route     = "/openapi.json"
+	DocsRoute = "/docs/*file"
+)
+
+// spec describes every route of handler.Router, a route registered without an entry fails the router tests.
+//
+//go:embed openapi.json
+var spec []byte
+
+// index is the Swagger UI page for spec, its assets are served from swaggerFiles.
+//
+//go:embed index.html
+var index []byte
+
+func Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// Docs serves Swagger UI, `/docs` is redirected to `/docs/` by the router.
+func Docs(c *gin.Context) {
+	file := c.Param("file")
+	if file == "/" || file == "/index.html" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", index)
+		return
+	}
+	f, err := swaggerFiles.HTTP.Open(file)
+	if err != nil {
+		apierror.Write(c, apierror.Error{Status: http.StatusNotFound, Code: apierror.CodeRouteNotFound, Message: "page not found"}, nil)
+		return
+	}
+	_ = f.Close()
+	c.FileFromFS(file, swaggerFiles.HTTP)
+}
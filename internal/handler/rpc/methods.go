@@ -0,0 +1,193 @@
+package rpc
+
+import (
+	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
+	"LamodaTest/internal/handler/apierror"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin/binding"
+	"strings"
+	"time"
+)
+
+// method runs the call name with its raw params and returns the result to encode.
+type method func(ctx context.Context, h *Handler, name string, params json.RawMessage) (any, *Error)
+
+// methods take the same fields as the bodies of the REST routes, by name only.
+var methods = map[string]method{
+	"goods.add":          handle(goodAdd),
+	"goods.get":          handle(goodGet),
+	"goods.remains":      handle(goodRemains),
+	"goods.reserve":      handle(goodReserve),
+	"goods.reserveAll":   handle(goodReserveAll),
+	"goods.release":      handle(goodRelease),
+	"goods.ship":         handle(goodShip),
+	"goods.receive":      handle(goodReceive),
+	"storages.setAccess": handle(storageSetAccess),
+	"storages.transfer":  handle(storageTransfer),
+}
+
+// handle binds and validates params before fn is called, errors of fn are mapped by registryError.
+func handle[T any](fn func(ctx context.Context, h *Handler, params T) (any, error)) method {
+	return func(ctx context.Context, h *Handler, name string, raw json.RawMessage) (any, *Error) {
+		var params T
+		if err := bind(raw, &params); err != nil {
+			h.log.WithField("method", name).Warnf("invalid params: %s", err.Error())
+			return nil, invalidParams(err)
+		}
+		result, err := fn(ctx, h, params)
+		if err != nil {
+			return nil, h.registryError(name, err)
+		}
+		return result, nil
+	}
+}
+
+// bind decodes params given by name, absent params are bound as an empty object so required fields fail.
+func bind(raw json.RawMessage, params any) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, null) {
+		raw = json.RawMessage("{}")
+	}
+	if raw[0] != '{' {
+		return apierror.Invalid("", "object")
+	}
+	if err := json.Unmarshal(raw, params); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(params)
+}
+
+func invalidParams(err error) *Error {
+	return &Error{Code: CodeInvalidParams, Message: "Invalid params",
+		Data: ErrorData{ErrorCode: apierror.CodeValidationFailed, Errors: apierror.Fields(err)}}
+}
+
+type goodAddParams struct {
+	Name     string `json:"name" binding:"required,notblank,max=45"`
+	Size     string `json:"size" binding:"required,size"`
+	UniqCode int    `json:"uniq_code" binding:"required,gt=0"`
+}
+
+// goodAdd returns the id of the added good.
+func goodAdd(ctx context.Context, h *Handler, p goodAddParams) (any, error) {
+	return h.registry.GoodAdd(ctx, strings.TrimSpace(p.Name), goods.NormalizeSize(p.Size), p.UniqCode)
+}
+
+type uniqCodeParams struct {
+	UniqCode int `json:"uniq_code" binding:"required,gt=0"`
+}
+
+func goodGet(ctx context.Context, h *Handler, p uniqCodeParams) (any, error) {
+	return h.registry.GoodStock(ctx, p.UniqCode)
+}
+
+type remainsParams struct {
+	pages.Query
+	Name         string `json:"name"`
+	Size         string `json:"size"`
+	StorageId    int    `json:"storage_id" binding:"min=0"`
+	MinAvailable int    `json:"min_available" binding:"min=0"`
+}
+
+type remainsPage struct {
+	Data       map[int]goods.RemainsDTO `json:"data"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+func goodRemains(ctx context.Context, h *Handler, p remainsParams) (any, error) {
+	filter := goods.Filter{Name: p.Name, Size: goods.NormalizeSize(p.Size), StorageId: p.StorageId, MinAvailable: p.MinAvailable}
+	list, next, err := h.registry.AvailableGoods(ctx, filter, p.Query)
+	if err != nil {
+		return nil, err
+	}
+	return remainsPage{Data: list, NextCursor: next}, nil
+}
+
+type reserveParams struct {
+	UniqCode int   `json:"uniq_code" binding:"required,gt=0"`
+	Count    int   `json:"count" binding:"required,gt=0"`
+	TTL      int   `json:"ttl" binding:"min=0"` // seconds, zero means the reservation never expires
+	Storages []int `json:"storages" binding:"dive,gt=0"`
+}
+
+func (p reserveParams) request() reservations.Request {
+	return reservations.Request{
+		UniqCode: p.UniqCode,
+		Count:    p.Count,
+		TTL:      time.Duration(p.TTL) * time.Second,
+		Storages: p.Storages,
+	}
+}
+
+func goodReserve(ctx context.Context, h *Handler, p reserveParams) (any, error) {
+	return h.registry.ReserveGood(ctx, p.request())
+}
+
+type reserveAllParams struct {
+	Goods []reserveParams `json:"goods" binding:"required,min=1,max=100,dive"` // max is maxBatch
+}
+
+// goodReserveAll reserves every good or nothing.
+func goodReserveAll(ctx context.Context, h *Handler, p reserveAllParams) (any, error) {
+	reqs := make([]reservations.Request, 0, len(p.Goods))
+	for _, g := range p.Goods {
+		reqs = append(reqs, g.request())
+	}
+	return h.registry.ReserveGoods(ctx, reqs)
+}
+
+type reservationParams struct {
+	ReservationId int64 `json:"reservation_id" binding:"required,gt=0"`
+	Count         int   `json:"count" binding:"min=0"` // shipping only, zero ships everything that is still reserved
+}
+
+func goodRelease(ctx context.Context, h *Handler, p reservationParams) (any, error) {
+	return h.registry.ReleaseGood(ctx, p.ReservationId)
+}
+
+func goodShip(ctx context.Context, h *Handler, p reservationParams) (any, error) {
+	return h.registry.ShipGood(ctx, p.ReservationId, p.Count)
+}
+
+type receiveParams struct {
+	UniqCode  int `json:"uniq_code" binding:"required,gt=0"`
+	StorageId int `json:"storage_id" binding:"required,gt=0"`
+	Count     int `json:"count" binding:"required,gt=0"`
+}
+
+// goodReceive echoes the received line, as the REST route does.
+func goodReceive(ctx context.Context, h *Handler, p receiveParams) (any, error) {
+	if err := h.registry.ReceiveGood(ctx, p.UniqCode, p.StorageId, p.Count); err != nil {
+		return nil, err
+	}
+	return goods.ReceivedDTO{UniqCode: p.UniqCode, StorageId: p.StorageId, Count: p.Count, AdditionalInfo: "OK"}, nil
+}
+
+type accessParams struct {
+	Id        int   `json:"id" binding:"required,gt=0"`
+	Available *bool `json:"available" binding:"required"`
+}
+
+// storageSetAccess returns the number of changed storages.
+func storageSetAccess(ctx context.Context, h *Handler, p accessParams) (any, error) {
+	return h.registry.StoragesChangeAccess(ctx, p.Id, *p.Available)
+}
+
+type transferParams struct {
+	UniqCode      int `json:"uniq_code" binding:"required,gt=0"`
+	FromStorageId int `json:"from_storage_id" binding:"required,gt=0"`
+	ToStorageId   int `json:"to_storage_id" binding:"required,gt=0,nefield=FromStorageId"`
+	Count         int `json:"count" binding:"required,gt=0"`
+}
+
+// storageTransfer echoes the transferred goods.
+func storageTransfer(ctx context.Context, h *Handler, p transferParams) (any, error) {
+	if err := h.registry.TransferStock(ctx, p.UniqCode, p.FromStorageId, p.ToStorageId, p.Count); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,217 @@
+package rpc
+
+import (
+	"LamodaTest/internal/handler/apierror"
+	"LamodaTest/internal/registry"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+)
+
+const Route = "/rpc"
+
+const version = "2.0"
+
+// maxBatch bounds the requests of a batch, the same as the lines of REST batch requests.
+const maxBatch = 100
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Registry errors fall into the server error range, the HTTP status the REST API returns is mirrored in the code.
+const (
+	CodeNotFound      = -32004
+	CodeConflict      = -32009
+	CodeUnprocessable = -32022
+)
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"` // absent for notifications, JSON null is still an id
+}
+
+func (r request) notification() bool {
+	return r.ID == nil
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"` // a successful null result is kept as `null`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// ErrorData tells which registry error or which params caused the error.
+type ErrorData struct {
+	ErrorCode string                `json:"error_code,omitempty"` // the `error_code` of the REST API
+	Errors    []apierror.FieldError `json:"errors,omitempty"`
+	Lines     []ErrorData           `json:"lines,omitempty"` // aligned with the lines of a batch, empty for lines without errors
+}
+
+var null = json.RawMessage("null")
+
+type Handler struct {
+	registry registry.Db
+	log      logrus.FieldLogger
+}
+
+func NewHandler(registry registry.Db, log logrus.FieldLogger) *Handler {
+	return &Handler{registry: registry, log: log}
+}
+
+// Serve answers a JSON-RPC 2.0 request or a batch of them. Requests of a batch are run one by one in their order,
+// notifications are run too but get no response, so a request of notifications only gets 204 No Content.
+func (h *Handler) Serve(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.log.Errorf("can't read `%s` request: %s", Route, err.Error())
+		c.JSON(http.StatusOK, failure(null, &Error{Code: CodeParseError, Message: "Parse error"}))
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		if res, ok := h.call(c.Request.Context(), body); ok {
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err = json.Unmarshal(body, &batch); err != nil {
+		c.JSON(http.StatusOK, failure(null, &Error{Code: CodeParseError, Message: "Parse error"}))
+		return
+	}
+	if len(batch) == 0 || len(batch) > maxBatch {
+		c.JSON(http.StatusOK, failure(null, &Error{Code: CodeInvalidRequest, Message: "Invalid Request",
+			Data: ErrorData{Errors: []apierror.FieldError{{Reason: batchRule(len(batch))}}}}))
+		return
+	}
+	result := make([]response, 0, len(batch))
+	for _, raw := range batch {
+		if res, ok := h.call(c.Request.Context(), raw); ok {
+			result = append(result, res)
+		}
+	}
+	if len(result) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func batchRule(n int) string {
+	if n == 0 {
+		return "min=1"
+	}
+	return "max=100"
+}
+
+// call runs a single request, ok is false for notifications that must not be answered.
+func (h *Handler) call(ctx context.Context, raw json.RawMessage) (res response, ok bool) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if len(raw) == 0 || errors.As(err, &syntaxErr) {
+			return failure(null, &Error{Code: CodeParseError, Message: "Parse error"}), true
+		}
+		return failure(null, &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}), true
+	}
+	if req.Version != version || req.Method == "" || !validID(req.ID) {
+		id := req.ID
+		if id == nil || !validID(id) {
+			id = null
+		}
+		return failure(id, &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}), true
+	}
+	m, found := methods[req.Method]
+	if !found {
+		return failure(req.ID, &Error{Code: CodeMethodNotFound, Message: "Method not found"}), !req.notification()
+	}
+	result, rpcErr := m(ctx, h, req.Method, req.Params)
+	if rpcErr != nil {
+		return failure(req.ID, rpcErr), !req.notification()
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		h.log.WithField("method", req.Method).Errorf("can't encode result: %s", err.Error())
+		return failure(req.ID, &Error{Code: CodeInternalError, Message: "Internal error"}), !req.notification()
+	}
+	return response{Version: version, Result: encoded, ID: req.ID}, !req.notification()
+}
+
+// validID accepts what the spec allows as an id: a string, a number or null, or no id at all.
+func validID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+func failure(id json.RawMessage, err *Error) response {
+	return response{Version: version, Error: err, ID: id}
+}
+
+// registryError maps err the way the REST API does and logs it.
+// A batch is answered by its first failed line, the error of every line is put into data.
+func (h *Handler) registryError(method string, err error) *Error {
+	e := apierror.From(err, "Internal error")
+	var lines []ErrorData
+	var batchErr *registry.BatchError
+	if errors.As(err, &batchErr) {
+		lines = make([]ErrorData, len(batchErr.Errors))
+		first := true
+		for i, lineErr := range batchErr.Errors {
+			if lineErr == nil {
+				continue
+			}
+			lineE := apierror.From(lineErr, "Internal error")
+			if first {
+				e, first = lineE, false
+			}
+			lines[i].ErrorCode = lineE.Code
+		}
+	}
+	log := h.log.WithField("method", method)
+	if e.Internal() {
+		log.Error(err)
+	} else {
+		log.Warn(err)
+	}
+	code := CodeInternalError
+	switch {
+	case e.Code == apierror.CodeInvalidArgument || e.Status == http.StatusBadRequest:
+		code = CodeInvalidParams
+	case e.Status == http.StatusNotFound:
+		code = CodeNotFound
+	case e.Status == http.StatusConflict:
+		code = CodeConflict
+	case e.Status == http.StatusUnprocessableEntity:
+		code = CodeUnprocessable
+	}
+	return &Error{Code: code, Message: e.Message, Data: ErrorData{ErrorCode: e.Code, Lines: lines}}
+}
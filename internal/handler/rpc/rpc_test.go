@@ -0,0 +1,227 @@
+package rpc
+
+import (
+	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
+	"LamodaTest/internal/logger"
+	"LamodaTest/internal/registry"
+	mock_registry "LamodaTest/internal/registry/mocks"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_Serve(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		mock     func(m *mock_registry.MockDb)
+		body     string
+		wantCode int
+		wantRes  string
+	}{
+		{
+			name: "single call",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().GoodStock(gomock.Any(), 7).Return(goods.Stock{
+					Good:  goods.Good{Id: 1, Name: "test", Size: "L", UniqCode: 7},
+					Count: 3, Reserved: 1, Available: 2, Storages: []goods.StorageStock{},
+				}, nil)
+			},
+			body:     `{"jsonrpc": "2.0", "method": "goods.get", "params": {"uniq_code": 7}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "result": {"id": 1, "name": "test", "size": "L", "uniq_code": 7,
+				"count": 3, "reserved": 1, "available": 2, "storages": []}}`,
+		}, {
+			name: "remains by size in any case",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().AvailableGoods(gomock.Any(), goods.Filter{Size: "XL"}, pages.Query{}).
+					Return(map[int]goods.RemainsDTO{2: {Name: "test", Size: "XL", StorageAvailable: map[int]int{1: 3}}}, "", nil)
+			},
+			body:     `{"jsonrpc": "2.0", "method": "goods.remains", "params": {"size": "xl"}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "result": {"data": {"2": {"name": "test", "size": "XL",
+				"storage_available": {"1": 3}}}}}`,
+		}, {
+			name: "null id is answered",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().StoragesChangeAccess(gomock.Any(), 2, false).Return(int64(1), nil)
+			},
+			body:     `{"jsonrpc": "2.0", "method": "storages.setAccess", "params": {"id": 2, "available": false}, "id": null}`,
+			wantCode: http.StatusOK,
+			wantRes:  `{"jsonrpc": "2.0", "id": null, "result": 1}`,
+		}, {
+			name: "notification",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReleaseGood(gomock.Any(), int64(3)).Return(reservations.Reservation{}, nil)
+			},
+			body:     `{"jsonrpc": "2.0", "method": "goods.release", "params": {"reservation_id": 3}}`,
+			wantCode: http.StatusNoContent,
+		}, {
+			name: "batch",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReserveGood(gomock.Any(), reservations.Request{UniqCode: 7, Count: 2}).Return(reservations.Reservation{
+					ID: 10, UniqCode: 7, Lines: []reservations.Line{{StorageId: 1, Count: 2}}, CreatedAt: created, Status: reservations.StatusActive,
+				}, nil)
+				m.EXPECT().ReceiveGood(gomock.Any(), 7, 1, 5).Return(nil)
+			},
+			body: `[
+				{"jsonrpc": "2.0", "method": "goods.reserve", "params": {"uniq_code": 7, "count": 2}, "id": "a"},
+				{"jsonrpc": "2.0", "method": "goods.receive", "params": {"uniq_code": 7, "storage_id": 1, "count": 5}},
+				{"jsonrpc": "2.0", "method": "goods.unknown", "id": "b"},
+				{"jsonrpc": "1.0", "method": "goods.get", "id": "c"},
+				1
+			]`,
+			wantCode: http.StatusOK,
+			wantRes: `[
+				{"jsonrpc": "2.0", "id": "a", "result": {"id": 10, "uniq_code": 7, "lines": [{"storage": 1, "count": 2}],
+					"created_at": "2024-01-02T03:04:05Z", "status": "active"}},
+				{"jsonrpc": "2.0", "id": "b", "error": {"code": -32601, "message": "Method not found"}},
+				{"jsonrpc": "2.0", "id": "c", "error": {"code": -32600, "message": "Invalid Request"}},
+				{"jsonrpc": "2.0", "id": null, "error": {"code": -32600, "message": "Invalid Request"}}
+			]`,
+		}, {
+			name: "batch of notifications",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReceiveGood(gomock.Any(), 7, 1, 5).Return(nil)
+				m.EXPECT().ReceiveGood(gomock.Any(), 8, 1, 5).Return(errors.New("connection refused"))
+			},
+			body: `[
+				{"jsonrpc": "2.0", "method": "goods.receive", "params": {"uniq_code": 7, "storage_id": 1, "count": 5}},
+				{"jsonrpc": "2.0", "method": "goods.receive", "params": {"uniq_code": 8, "storage_id": 1, "count": 5}}
+			]`,
+			wantCode: http.StatusNoContent,
+		}, {
+			name:     "parse error",
+			body:     `{"jsonrpc": "2.0", "method"`,
+			wantCode: http.StatusOK,
+			wantRes:  `{"jsonrpc": "2.0", "id": null, "error": {"code": -32700, "message": "Parse error"}}`,
+		}, {
+			name:     "broken batch",
+			body:     `[{"jsonrpc": "2.0", "method": "goods.get", "id": 1},`,
+			wantCode: http.StatusOK,
+			wantRes:  `{"jsonrpc": "2.0", "id": null, "error": {"code": -32700, "message": "Parse error"}}`,
+		}, {
+			name:     "empty batch",
+			body:     `[]`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": null, "error": {"code": -32600, "message": "Invalid Request",
+				"data": {"errors": [{"field": "", "reason": "min=1"}]}}}`,
+		}, {
+			name:     "invalid params",
+			body:     `{"jsonrpc": "2.0", "method": "goods.add", "params": {"name": " ", "size": "XXXXL", "uniq_code": "one"}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32602, "message": "Invalid params",
+				"data": {"error_code": "validation_failed", "errors": [{"field": "uniq_code", "reason": "must be int"}]}}}`,
+		}, {
+			name:     "failed rules",
+			body:     `{"jsonrpc": "2.0", "method": "goods.add", "params": {"name": " ", "size": "XXXXL"}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32602, "message": "Invalid params",
+				"data": {"error_code": "validation_failed", "errors": [{"field": "name", "reason": "notblank"},
+					{"field": "size", "reason": "size"}, {"field": "uniq_code", "reason": "required"}]}}}`,
+		}, {
+			name:     "params by position",
+			body:     `{"jsonrpc": "2.0", "method": "goods.get", "params": [7], "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32602, "message": "Invalid params",
+				"data": {"error_code": "validation_failed", "errors": [{"field": "", "reason": "object"}]}}}`,
+		}, {
+			name: "not found",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ShipGood(gomock.Any(), int64(3), 0).
+					Return(reservations.Shipment{}, fmt.Errorf("can't ship reservation 3: %w", registry.ErrReservationNotFound))
+			},
+			body:     `{"jsonrpc": "2.0", "method": "goods.ship", "params": {"reservation_id": 3}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32004, "message": "Reservation not found",
+				"data": {"error_code": "reservation_not_found"}}}`,
+		}, {
+			name: "conflict",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().GoodAdd(gomock.Any(), "test", "L", 7).
+					Return(int64(0), &registry.DuplicateUniqCodeError{UniqCode: 7, GoodId: 1})
+			},
+			body:     `{"jsonrpc": "2.0", "method": "goods.add", "params": {"name": " test ", "size": "l", "uniq_code": 7}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32009, "message": "Good with this uniq_code already exists",
+				"data": {"error_code": "duplicate_uniq_code"}}}`,
+		}, {
+			name: "unprocessable",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().TransferStock(gomock.Any(), 7, 1, 2, 5).
+					Return(fmt.Errorf("can't transfer: %w", registry.ErrInsufficientStock))
+			},
+			body: `{"jsonrpc": "2.0", "method": "storages.transfer",
+				"params": {"uniq_code": 7, "from_storage_id": 1, "to_storage_id": 2, "count": 5}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32022, "message": "Not enough goods on available storages",
+				"data": {"error_code": "insufficient_stock"}}}`,
+		}, {
+			name: "invalid argument",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReserveGoods(gomock.Any(), []reservations.Request{{UniqCode: 7, Count: 1, Storages: []int{3}}}).
+					Return(nil, fmt.Errorf("%w: storage 3 is pinned twice", registry.ErrInvalidArgument))
+			},
+			body:     `{"jsonrpc": "2.0", "method": "goods.reserveAll", "params": {"goods": [{"uniq_code": 7, "count": 1, "storages": [3]}]}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32602, "message": "Invalid argument",
+				"data": {"error_code": "invalid_argument"}}}`,
+		}, {
+			name: "lines can't be reserved",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().ReserveGoods(gomock.Any(), []reservations.Request{{UniqCode: 7, Count: 1}, {UniqCode: 8, Count: 1}, {UniqCode: 9, Count: 1}}).
+					Return(nil, &registry.BatchError{Errors: []error{
+						nil,
+						fmt.Errorf("can't reserve 8 good: %w", registry.ErrInsufficientStock),
+						fmt.Errorf("can't find good with uniq_code 9: %w", registry.ErrGoodNotFound),
+					}})
+			},
+			body: `{"jsonrpc": "2.0", "method": "goods.reserveAll", "params": {"goods": [{"uniq_code": 7, "count": 1},
+				{"uniq_code": 8, "count": 1}, {"uniq_code": 9, "count": 1}]}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32022, "message": "Not enough goods on available storages",
+				"data": {"error_code": "insufficient_stock", "lines": [{}, {"error_code": "insufficient_stock"}, {"error_code": "good_not_found"}]}}}`,
+		}, {
+			name: "internal error",
+			mock: func(m *mock_registry.MockDb) {
+				m.EXPECT().GoodStock(gomock.Any(), 7).Return(goods.Stock{}, errors.New("connection refused"))
+			},
+			body:     `{"jsonrpc": "2.0", "method": "goods.get", "params": {"uniq_code": 7}, "id": 1}`,
+			wantCode: http.StatusOK,
+			wantRes: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32603, "message": "Internal error",
+				"data": {"error_code": "internal_error"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mock_registry.NewMockDb(ctrl)
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			gin.SetMode(gin.ReleaseMode)
+			router := gin.New()
+			router.POST(Route, NewHandler(m, logger.New(false)).Serve)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", Route, strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantRes == "" {
+				assert.Empty(t, w.Body.String())
+				return
+			}
+			assert.JSONEq(t, tt.wantRes, w.Body.String())
+		})
+	}
+}
@@ -1,11 +1,17 @@
 package storages
 
 import (
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/storages"
+	"LamodaTest/internal/handler/apierror"
 	"LamodaTest/internal/registry"
-	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -14,6 +20,16 @@ const (
 	AvailableRoute = "/storages/available"
 	AllRoute       = "/storages/all"
 	AccessStatus   = "/storages/access"
+	TransferRoute  = "/storages/transfer"
+	UpdateRoute    = "/storages/:id"
+)
+
+// Resource routes of the versioned API.
+const (
+	StoragesRoute     = "/storages"
+	StorageRoute      = "/storages/:id"
+	AvailabilityRoute = "/storages/:id/availability"
+	TransfersRoute    = "/transfers"
 )
 
 type Handler struct {
@@ -30,18 +46,30 @@ func NewHandler(registry registry.Db, log logrus.FieldLogger) *Handler {
 
 func (h *Handler) Add(c *gin.Context) {
 	var input struct {
-		Name      string `json:"name" binding:"required"`
-		Available *bool  `json:"available" binding:"required"`
+		Name      string            `json:"name" binding:"required,notblank,max=45"`
+		Available *bool             `json:"available" binding:"required"`
+		Address   string            `json:"address" binding:"max=255"`
+		Region    string            `json:"region" binding:"max=64"`
+		Priority  int               `json:"priority"`
+		Capacity  int               `json:"capacity" binding:"min=0"`
+		Tags      map[string]string `json:"tags"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.log.Errorf("can't parse body from `/storage/add` request: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid JSON"})
+		apierror.InvalidBody(c, err)
 		return
 	}
-	addedId, err := h.registry.StoragesAdd(context.Background(), input.Name, *input.Available)
+	addedId, err := h.registry.StoragesAdd(c.Request.Context(), storages.Storage{
+		Name:      strings.TrimSpace(input.Name),
+		Available: *input.Available,
+		Address:   input.Address,
+		Region:    input.Region,
+		Priority:  input.Priority,
+		Capacity:  input.Capacity,
+		Tags:      input.Tags,
+	})
 	if err != nil {
-		h.log.Errorf("can't add storage: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Not added"})
+		apierror.Respond(c, h.log, err, "Not added")
 		return
 	}
 	c.JSON(200, gin.H{
@@ -50,19 +78,64 @@ func (h *Handler) Add(c *gin.Context) {
 	})
 }
 
+// Delete refuses to delete a storage holding goods unless `migrate_to` names the storage to move them to.
 func (h *Handler) Delete(c *gin.Context) {
 	var input struct {
-		Id int `json:"id" binding:"required"`
+		Id        int `json:"id" binding:"required,gt=0"`
+		MigrateTo int `json:"migrate_to" binding:"min=0,nefield=Id"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.log.Errorf("can't parse body from `/storage/delete` request: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid JSON"})
+		apierror.InvalidBody(c, err)
+		return
+	}
+	h.delete(c, input.Id, input.MigrateTo)
+}
+
+// DeleteById is Delete of the storage named by the path, `migrate_to` is passed in the query.
+func (h *Handler) DeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.log.Errorf("can't parse id from `/storages/:id` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("id", err), apierror.CodeInvalidParam, "Invalid id")
+		return
+	}
+	var query struct {
+		MigrateTo int `form:"migrate_to" binding:"min=0"`
+	}
+	if err = c.ShouldBindQuery(&query); err != nil {
+		h.log.Errorf("can't parse query from `/storages/:id` request: %s", err.Error())
+		apierror.BadRequest(c, err, apierror.CodeInvalidQuery, "Invalid query")
+		return
+	}
+	if query.MigrateTo == id {
+		apierror.BadRequest(c, apierror.Invalid("migrate_to", "nefield=id"), apierror.CodeInvalidQuery, "Invalid query")
+		return
+	}
+	h.delete(c, id, query.MigrateTo)
+}
+
+func (h *Handler) delete(c *gin.Context, id int, migrateTo int) {
+	deleted, err := h.registry.StoragesDelete(c.Request.Context(), id, migrateTo)
+	var notEmpty *registry.StorageNotEmptyError
+	if errors.As(err, &notEmpty) {
+		e := apierror.From(err, "")
+		if !slices.ContainsFunc(notEmpty.Remains, func(r storages.Remains) bool { return !r.History }) {
+			e.Message = "Storage keeps reservation or shipment history, pass migrate_to to move it"
+		}
+		apierror.RespondWith(c, h.log, err, e, notEmpty.Remains)
 		return
 	}
-	deleted, err := h.registry.StoragesDelete(context.Background(), input.Id)
 	if err != nil {
-		h.log.Errorf("can't delete storage: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Can't delete this storage"})
+		e := apierror.From(err, "Can't delete this storage")
+		// the storage itself is checked by the delete, only the one to migrate to can be missing
+		switch e.Code {
+		case apierror.CodeStorageNotFound:
+			e.Message = "Storage to migrate to not found"
+		case apierror.CodeStorageUnavailable:
+			e.Message = "Storage to migrate to is not available"
+		}
+		apierror.RespondWith(c, h.log, err, e, nil)
 		return
 	}
 	if deleted == 0 {
@@ -78,46 +151,125 @@ func (h *Handler) Delete(c *gin.Context) {
 	})
 }
 
-func (h *Handler) Available(c *gin.Context) {
-	storages, err := h.registry.Storages(context.Background(), false)
+// Update changes only the fields present in the body, `tags` replaces all tags of the storage.
+func (h *Handler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		h.log.Errorf("can't get available storages: %s", err.Error())
-		c.JSON(500, gin.H{"code": http.StatusInternalServerError, "message": "Internal server error"})
+		h.log.Errorf("can't parse id from `/storages/:id` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("id", err), apierror.CodeInvalidParam, "Invalid id")
+		return
+	}
+	var input struct {
+		Name      *string           `json:"name" binding:"omitempty,notblank,max=45"`
+		Available *bool             `json:"available"`
+		Address   *string           `json:"address" binding:"omitempty,max=255"`
+		Region    *string           `json:"region" binding:"omitempty,max=64"`
+		Priority  *int              `json:"priority"`
+		Capacity  *int              `json:"capacity" binding:"omitempty,min=0"`
+		Tags      map[string]string `json:"tags"`
+	}
+	if err = c.ShouldBindJSON(&input); err != nil {
+		h.log.Errorf("can't parse body from `/storages/:id` request: %s", err.Error())
+		apierror.InvalidBody(c, err)
+		return
+	}
+	update := storages.Update(input)
+	if update.Name != nil {
+		*update.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Empty() {
+		apierror.BadRequest(c, nil, apierror.CodeNothingToUpdate, "Nothing to update")
+		return
+	}
+	storage, err := h.registry.StoragesUpdate(c.Request.Context(), id, update)
+	if err != nil {
+		apierror.Respond(c, h.log, err, "Can't update this storage")
 		return
 	}
 	c.JSON(200, gin.H{
 		"code": http.StatusOK,
-		"data": storages,
+		"data": storage,
 	})
 }
 
+// Available is All limited to available storages.
+func (h *Handler) Available(c *gin.Context) {
+	available := true
+	h.list(c, &available)
+}
+
+// All returns a page of storages. `sort` is one of id, name, priority with an optional "-" prefix
+// for descending order, `next_cursor` is returned while there are more storages and is passed back as `cursor`.
 func (h *Handler) All(c *gin.Context) {
-	storages, err := h.registry.Storages(context.Background(), true)
+	h.list(c, nil)
+}
+
+func (h *Handler) list(c *gin.Context, available *bool) {
+	var query struct {
+		pages.Query
+		Name      string `form:"name"`
+		Available *bool  `form:"available"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.log.Errorf("can't parse query from `%s` request: %s", c.FullPath(), err.Error())
+		apierror.BadRequest(c, err, apierror.CodeInvalidQuery, "Invalid query")
+		return
+	}
+	if available != nil {
+		query.Available = available
+	}
+	filter := storages.Filter{Name: query.Name, Available: query.Available}
+	list, next, err := h.registry.Storages(c.Request.Context(), filter, query.Query)
 	if err != nil {
-		h.log.Errorf("can't get all storages: %s", err.Error())
-		c.JSON(500, gin.H{"code": http.StatusInternalServerError, "message": "Internal server error"})
+		apierror.Respond(c, h.log, err, "Internal server error")
 		return
 	}
-	c.JSON(200, gin.H{
+	result := gin.H{
 		"code": http.StatusOK,
-		"data": storages,
-	})
+		"data": list,
+	}
+	if next != "" {
+		result["next_cursor"] = next
+	}
+	c.JSON(200, result)
 }
 
 func (h *Handler) ChangeAccess(c *gin.Context) {
 	var input struct {
-		Id        int   `json:"id" binding:"required"`
+		Id        int   `json:"id" binding:"required,gt=0"`
 		Available *bool `json:"available" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.log.Errorf("can't parse body from `/storage/add` request: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid JSON"})
+		apierror.InvalidBody(c, err)
+		return
+	}
+	h.changeAccess(c, input.Id, *input.Available)
+}
+
+// SetAvailability is ChangeAccess of the storage named by the path.
+func (h *Handler) SetAvailability(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.log.Errorf("can't parse id from `/storages/:id/availability` request: %s", err.Error())
+		apierror.BadRequest(c, apierror.Param("id", err), apierror.CodeInvalidParam, "Invalid id")
+		return
+	}
+	var input struct {
+		Available *bool `json:"available" binding:"required"`
+	}
+	if err = c.ShouldBindJSON(&input); err != nil {
+		h.log.Errorf("can't parse body from `/storages/:id/availability` request: %s", err.Error())
+		apierror.InvalidBody(c, err)
 		return
 	}
-	changed, err := h.registry.StoragesChangeAccess(context.Background(), input.Id, *input.Available)
+	h.changeAccess(c, id, *input.Available)
+}
+
+func (h *Handler) changeAccess(c *gin.Context, id int, available bool) {
+	changed, err := h.registry.StoragesChangeAccess(c.Request.Context(), id, available)
 	if err != nil {
-		h.log.Errorf("can't change storage: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Can't change this storage"})
+		apierror.Respond(c, h.log, err, "Can't change this storage")
 		return
 	}
 	if changed == 0 {
@@ -132,3 +284,33 @@ func (h *Handler) ChangeAccess(c *gin.Context) {
 		"message": "OK",
 	})
 }
+
+func (h *Handler) Transfer(c *gin.Context) {
+	var input struct {
+		UniqCode      int `json:"uniq_code" binding:"required,gt=0"`
+		FromStorageId int `json:"from_storage_id" binding:"required,gt=0"`
+		ToStorageId   int `json:"to_storage_id" binding:"required,gt=0,nefield=FromStorageId"`
+		Count         int `json:"count" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.log.Errorf("can't parse body from `/storage/transfer` request: %s", err.Error())
+		apierror.InvalidBody(c, err)
+		return
+	}
+	err := h.registry.TransferStock(c.Request.Context(), input.UniqCode, input.FromStorageId, input.ToStorageId, input.Count)
+	if err != nil {
+		e := apierror.From(err, "Can't transfer goods")
+		switch e.Code {
+		case apierror.CodeInsufficientStock:
+			e.Message = "Not enough free goods on the source storage"
+		case apierror.CodeStorageUnavailable:
+			e.Message = "Destination storage is not available"
+		}
+		apierror.RespondWith(c, h.log, err, e, nil)
+		return
+	}
+	c.JSON(200, gin.H{
+		"code":    http.StatusOK,
+		"message": "OK",
+	})
+}
@@ -1,13 +1,16 @@
 package storages
 
 import (
+	"LamodaTest/internal/entity/pages"
 	"LamodaTest/internal/entity/storages"
+	"LamodaTest/internal/handler/apierror"
 	"LamodaTest/internal/logger"
 	"LamodaTest/internal/registry"
 	mock_registry "LamodaTest/internal/registry/mocks"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -42,7 +45,7 @@ func TestHandler_Add(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().StoragesAdd(context.Background(), "test", true).Return(int64(1), nil).Times(1).AnyTimes()
+					m.EXPECT().StoragesAdd(context.Background(), storages.Storage{Name: "test", Available: true}).Return(int64(1), nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -62,6 +65,46 @@ func TestHandler_Add(t *testing.T) {
 				"code": 200,
 				"data": 1,
 			},
+		}, {
+			name: "normal with metadata",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesAdd(context.Background(), storages.Storage{
+						Name:      "test",
+						Available: true,
+						Address:   "Moscow",
+						Region:    "msk",
+						Priority:  10,
+						Capacity:  500,
+						Tags:      map[string]string{"type": "cold"},
+					}).Return(int64(2), nil).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "POST",
+				body: func() string {
+					marshal, _ := json.Marshal(map[string]interface{}{
+						"name":      "test",
+						"available": true,
+						"address":   "Moscow",
+						"region":    "msk",
+						"priority":  10,
+						"capacity":  500,
+						"tags":      map[string]string{"type": "cold"},
+					})
+					return string(marshal)
+				}(),
+			},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": 2,
+			},
 		}, {
 			name: "err from db",
 			fields: fields{
@@ -69,7 +112,7 @@ func TestHandler_Add(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().StoragesAdd(context.Background(), "test", true).Return(int64(0), errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().StoragesAdd(context.Background(), storages.Storage{Name: "test", Available: true}).Return(int64(0), errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -86,8 +129,9 @@ func TestHandler_Add(t *testing.T) {
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Not added",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Not added",
 			},
 		}, {
 			name: "invalid json",
@@ -96,7 +140,7 @@ func TestHandler_Add(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().StoragesAdd(context.Background(), "test", true).Return(int64(0), nil).Times(1).AnyTimes()
+					m.EXPECT().StoragesAdd(context.Background(), storages.Storage{Name: "test", Available: true}).Return(int64(0), nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -110,10 +154,12 @@ func TestHandler_Add(t *testing.T) {
 					return string(marshal)
 				}(),
 			},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 			wantRes: map[string]interface{}{
-				"code":    http.StatusBadRequest,
-				"message": "Invalid JSON",
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "name", Reason: "required"}},
+				"message":    "Validation failed",
 			},
 		},
 	}
@@ -145,9 +191,11 @@ func TestHandler_All(t *testing.T) {
 		log      logrus.FieldLogger
 	}
 	l := logger.New(false)
+	unavailable := false
 	tests := []struct {
 		name     string
 		fields   fields
+		query    string
 		wantRes  map[string]interface{}
 		wantCode int
 	}{
@@ -159,7 +207,7 @@ func TestHandler_All(t *testing.T) {
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
 					m.EXPECT().
-						Storages(context.Background(), true).
+						Storages(context.Background(), storages.Filter{}, pages.Query{}).
 						Return([]storages.Storage{
 							{
 								ID:           1,
@@ -167,7 +215,7 @@ func TestHandler_All(t *testing.T) {
 								RawAvailable: "1",
 								Available:    true,
 							},
-						}, nil).
+						}, "", nil).
 						Times(1).
 						AnyTimes()
 					return m
@@ -185,6 +233,67 @@ func TestHandler_All(t *testing.T) {
 					},
 				},
 			},
+		}, {
+			name: "page with filters",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().
+						Storages(context.Background(), storages.Filter{Name: "te", Available: &unavailable},
+							pages.Query{Cursor: "abc", Limit: 1, Sort: "-priority"}).
+						Return([]storages.Storage{{ID: 2, Name: "test"}}, "next", nil).
+						Times(1).
+						AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?name=te&available=false&cursor=abc&limit=1&sort=-priority",
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code":        200,
+				"data":        []storages.Storage{{ID: 2, Name: "test"}},
+				"next_cursor": "next",
+			},
+		}, {
+			name: "invalid sort",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().Storages(context.Background(), storages.Filter{}, pages.Query{Sort: "capacity"}).
+						Return(nil, "", registry.ErrInvalidQuery).Times(1).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?sort=capacity",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"message":    "Invalid query",
+			},
+		}, {
+			name: "invalid available",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					return mock_registry.NewMockDb(ctrl)
+				}(),
+				log: l,
+			},
+			query:    "?available=maybe",
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"message":    "Invalid query",
+			},
 		}, {
 			name: "err from db",
 			fields: fields{
@@ -192,15 +301,16 @@ func TestHandler_All(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().Storages(context.Background(), true).Return(nil, errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().Storages(context.Background(), storages.Filter{}, pages.Query{}).Return(nil, "", errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Internal server error",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Internal server error",
 			},
 		},
 	}
@@ -215,7 +325,7 @@ func TestHandler_All(t *testing.T) {
 			router.GET(AllRoute, h.All)
 
 			w := httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", AllRoute, nil)
+			req, _ := http.NewRequest("GET", AllRoute+tt.query, nil)
 
 			router.ServeHTTP(w, req)
 
@@ -232,9 +342,11 @@ func TestHandler_Available(t *testing.T) {
 		log      logrus.FieldLogger
 	}
 	l := logger.New(false)
+	available := true
 	tests := []struct {
 		name     string
 		fields   fields
+		query    string
 		wantRes  map[string]interface{}
 		wantCode int
 	}{
@@ -246,7 +358,7 @@ func TestHandler_Available(t *testing.T) {
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
 					m.EXPECT().
-						Storages(context.Background(), false).
+						Storages(context.Background(), storages.Filter{Available: &available}, pages.Query{}).
 						Return([]storages.Storage{
 							{
 								ID:           1,
@@ -254,7 +366,7 @@ func TestHandler_Available(t *testing.T) {
 								RawAvailable: "1",
 								Available:    true,
 							},
-						}, nil).
+						}, "", nil).
 						Times(1).
 						AnyTimes()
 					return m
@@ -272,6 +384,28 @@ func TestHandler_Available(t *testing.T) {
 					},
 				},
 			},
+		}, {
+			name: "available can't be overridden",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().
+						Storages(context.Background(), storages.Filter{Name: "te", Available: &available}, pages.Query{Limit: 5}).
+						Return([]storages.Storage{}, "", nil).
+						Times(1).
+						AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			query:    "?name=te&available=false&limit=5",
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": []storages.Storage{},
+			},
 		}, {
 			name: "err from db",
 			fields: fields{
@@ -279,15 +413,16 @@ func TestHandler_Available(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().Storages(context.Background(), false).Return(nil, errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().Storages(context.Background(), storages.Filter{Available: &available}, pages.Query{}).Return(nil, "", errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Internal server error",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Internal server error",
 			},
 		},
 	}
@@ -302,7 +437,7 @@ func TestHandler_Available(t *testing.T) {
 			router.GET(AvailableRoute, h.Available)
 
 			w := httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", AvailableRoute, nil)
+			req, _ := http.NewRequest("GET", AvailableRoute+tt.query, nil)
 
 			router.ServeHTTP(w, req)
 
@@ -408,8 +543,9 @@ func TestHandler_ChangeAccess(t *testing.T) {
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Can't change this storage",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Can't change this storage",
 			},
 		}, {
 			name: "invalid json",
@@ -432,10 +568,12 @@ func TestHandler_ChangeAccess(t *testing.T) {
 					return string(marshal)
 				}(),
 			},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 			wantRes: map[string]interface{}{
-				"code":    http.StatusBadRequest,
-				"message": "Invalid JSON",
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "id", Reason: "required"}},
+				"message":    "Validation failed",
 			},
 		},
 	}
@@ -485,7 +623,7 @@ func TestHandler_Delete(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().StoragesDelete(context.Background(), 1).Return(int64(1), nil).Times(1).AnyTimes()
+					m.EXPECT().StoragesDelete(context.Background(), 1, 0).Return(int64(1), nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -511,7 +649,7 @@ func TestHandler_Delete(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().StoragesDelete(context.Background(), 1).Return(int64(0), nil).Times(1).AnyTimes()
+					m.EXPECT().StoragesDelete(context.Background(), 1, 0).Return(int64(0), nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -537,7 +675,7 @@ func TestHandler_Delete(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().StoragesDelete(context.Background(), 1).Return(int64(0), errors.New("test")).Times(1).AnyTimes()
+					m.EXPECT().StoragesDelete(context.Background(), 1, 0).Return(int64(0), errors.New("test")).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -553,8 +691,143 @@ func TestHandler_Delete(t *testing.T) {
 			},
 			wantCode: 500,
 			wantRes: map[string]interface{}{
-				"code":    500,
-				"message": "Can't delete this storage",
+				"code":       500,
+				"error_code": apierror.CodeInternal,
+				"message":    "Can't delete this storage",
+			},
+		}, {
+			name: "storage holds goods",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesDelete(context.Background(), 1, 0).Return(int64(-1), fmt.Errorf("test: %w",
+						&registry.StorageNotEmptyError{StorageId: 1, Remains: []storages.Remains{{UniqCode: 5, Count: 10, Reserved: 2}}})).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "DELETE",
+				body:   `{"id": 1}`,
+			},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"data":       []storages.Remains{{UniqCode: 5, Count: 10, Reserved: 2}},
+				"error_code": apierror.CodeStorageNotEmpty,
+				"message":    "Storage still holds goods, pass migrate_to to move them",
+			},
+		}, {
+			name: "storage keeps history",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesDelete(context.Background(), 1, 0).Return(int64(-1), fmt.Errorf("test: %w",
+						&registry.StorageNotEmptyError{StorageId: 1, Remains: []storages.Remains{{UniqCode: 5, History: true}}})).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "DELETE",
+				body:   `{"id": 1}`,
+			},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"data":       []storages.Remains{{UniqCode: 5, History: true}},
+				"error_code": apierror.CodeStorageNotEmpty,
+				"message":    "Storage keeps reservation or shipment history, pass migrate_to to move it",
+			},
+		}, {
+			name: "migrate goods",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesDelete(context.Background(), 1, 2).Return(int64(1), nil).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "DELETE",
+				body:   `{"id": 1, "migrate_to": 2}`,
+			},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code":    200,
+				"message": "OK",
+			},
+		}, {
+			name: "migrate to unknown storage",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesDelete(context.Background(), 1, 9).Return(int64(-1), registry.ErrStorageNotFound).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "DELETE",
+				body:   `{"id": 1, "migrate_to": 9}`,
+			},
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeStorageNotFound,
+				"message":    "Storage to migrate to not found",
+			},
+		}, {
+			name: "migrate to unavailable storage",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesDelete(context.Background(), 1, 2).Return(int64(-1), registry.ErrStorageUnavailable).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "DELETE",
+				body:   `{"id": 1, "migrate_to": 2}`,
+			},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"error_code": apierror.CodeStorageUnavailable,
+				"message":    "Storage to migrate to is not available",
+			},
+		}, {
+			name: "migrate to itself",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					defer ctrl.Finish()
+					return mock_registry.NewMockDb(ctrl)
+				}(),
+				log: l,
+			},
+			args: args{
+				method: "DELETE",
+				body:   `{"id": 1, "migrate_to": 1}`,
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "migrate_to", Reason: "nefield=id"}},
+				"message":    "Validation failed",
 			},
 		}, {
 			name: "invalid json",
@@ -563,7 +836,7 @@ func TestHandler_Delete(t *testing.T) {
 					ctrl := gomock.NewController(t)
 					defer ctrl.Finish()
 					m := mock_registry.NewMockDb(ctrl)
-					m.EXPECT().StoragesDelete(context.Background(), 1).Return(int64(0), nil).Times(1).AnyTimes()
+					m.EXPECT().StoragesDelete(context.Background(), 1, 0).Return(int64(0), nil).Times(1).AnyTimes()
 					return m
 				}(),
 				log: l,
@@ -577,10 +850,12 @@ func TestHandler_Delete(t *testing.T) {
 					return string(marshal)
 				}(),
 			},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 			wantRes: map[string]interface{}{
-				"code":    http.StatusBadRequest,
-				"message": "Invalid JSON",
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "id", Reason: "required"}, {Field: "migrate_to", Reason: "nefield=id"}},
+				"message":    "Validation failed",
 			},
 		},
 	}
@@ -605,3 +880,467 @@ func TestHandler_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_Transfer(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		method string
+		body   string
+	}
+	l := logger.New(false)
+	body := func(from, to, count int) string {
+		marshal, _ := json.Marshal(map[string]interface{}{
+			"uniq_code":       1,
+			"from_storage_id": from,
+			"to_storage_id":   to,
+			"count":           count,
+		})
+		return string(marshal)
+	}
+	withErr := func(err error) *mock_registry.MockDb {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		m := mock_registry.NewMockDb(ctrl)
+		m.EXPECT().TransferStock(context.Background(), 1, 2, 3, 5).Return(err).AnyTimes()
+		return m
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name:     "normal",
+			fields:   fields{registry: withErr(nil), log: l},
+			args:     args{method: "POST", body: body(2, 3, 5)},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code":    200,
+				"message": "OK",
+			},
+		}, {
+			name:     "storage not found",
+			fields:   fields{registry: withErr(registry.ErrStorageNotFound), log: l},
+			args:     args{method: "POST", body: body(2, 3, 5)},
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeStorageNotFound,
+				"message":    "Storage not found",
+			},
+		}, {
+			name:     "good not found",
+			fields:   fields{registry: withErr(registry.ErrGoodNotFound), log: l},
+			args:     args{method: "POST", body: body(2, 3, 5)},
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeGoodNotFound,
+				"message":    "Good not found",
+			},
+		}, {
+			name:     "reserved goods",
+			fields:   fields{registry: withErr(registry.ErrInsufficientStock), log: l},
+			args:     args{method: "POST", body: body(2, 3, 5)},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeInsufficientStock,
+				"message":    "Not enough free goods on the source storage",
+			},
+		}, {
+			name:     "unavailable storage",
+			fields:   fields{registry: withErr(registry.ErrStorageUnavailable), log: l},
+			args:     args{method: "POST", body: body(2, 3, 5)},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"error_code": apierror.CodeStorageUnavailable,
+				"message":    "Destination storage is not available",
+			},
+		}, {
+			name:     "concurrent change",
+			fields:   fields{registry: withErr(fmt.Errorf("%w: deadlock", registry.ErrConflict)), log: l},
+			args:     args{method: "POST", body: body(2, 3, 5)},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"error_code": apierror.CodeConflict,
+				"message":    "Conflicting concurrent change, retry the request",
+			},
+		}, {
+			name:     "err from db",
+			fields:   fields{registry: withErr(errors.New("test")), log: l},
+			args:     args{method: "POST", body: body(2, 3, 5)},
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Can't transfer goods",
+			},
+		}, {
+			name:     "same storage",
+			fields:   fields{registry: withErr(nil), log: l},
+			args:     args{method: "POST", body: body(2, 2, 5)},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "to_storage_id", Reason: "nefield=from_storage_id"}},
+				"message":    "Validation failed",
+			},
+		}, {
+			name:     "invalid json",
+			fields:   fields{registry: withErr(nil), log: l},
+			args:     args{method: "POST", body: body(2, 3, 0)},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "count", Reason: "required"}},
+				"message":    "Validation failed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.POST(TransferRoute, h.Transfer)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.args.method, TransferRoute, strings.NewReader(tt.args.body))
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Update(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		method string
+		path   string
+		body   string
+	}
+	l := logger.New(false)
+	region := "spb"
+	priority := 3
+	update := storages.Update{Region: &region, Priority: &priority}
+	storage := storages.Storage{ID: 6, Name: "test", Available: true, Region: "spb", Priority: 3}
+	body := func(values map[string]interface{}) string {
+		marshal, _ := json.Marshal(values)
+		return string(marshal)
+	}
+	withResult := func(storage storages.Storage, err error) *mock_registry.MockDb {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		m := mock_registry.NewMockDb(ctrl)
+		m.EXPECT().StoragesUpdate(context.Background(), 6, update).Return(storage, err).AnyTimes()
+		return m
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name:     "normal",
+			fields:   fields{registry: withResult(storage, nil), log: l},
+			args:     args{method: "PATCH", path: "/storages/6", body: body(map[string]interface{}{"region": "spb", "priority": 3})},
+			wantCode: 200,
+			wantRes: map[string]interface{}{
+				"code": 200,
+				"data": storage,
+			},
+		}, {
+			name:     "storage not found",
+			fields:   fields{registry: withResult(storages.Storage{}, registry.ErrStorageNotFound), log: l},
+			args:     args{method: "PATCH", path: "/storages/6", body: body(map[string]interface{}{"region": "spb", "priority": 3})},
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeStorageNotFound,
+				"message":    "Storage not found",
+			},
+		}, {
+			name:     "err from db",
+			fields:   fields{registry: withResult(storages.Storage{}, errors.New("test")), log: l},
+			args:     args{method: "PATCH", path: "/storages/6", body: body(map[string]interface{}{"region": "spb", "priority": 3})},
+			wantCode: http.StatusInternalServerError,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusInternalServerError,
+				"error_code": apierror.CodeInternal,
+				"message":    "Can't update this storage",
+			},
+		}, {
+			name:     "nothing to update",
+			fields:   fields{registry: withResult(storage, nil), log: l},
+			args:     args{method: "PATCH", path: "/storages/6", body: body(map[string]interface{}{})},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeNothingToUpdate,
+				"message":    "Nothing to update",
+			},
+		}, {
+			name:     "invalid id",
+			fields:   fields{registry: withResult(storage, nil), log: l},
+			args:     args{method: "PATCH", path: "/storages/abc", body: body(map[string]interface{}{"region": "spb"})},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "id", Reason: "must be an integer"}},
+				"message":    "Invalid id",
+			},
+		}, {
+			name:     "invalid json",
+			fields:   fields{registry: withResult(storage, nil), log: l},
+			args:     args{method: "PATCH", path: "/storages/6", body: body(map[string]interface{}{"capacity": -1})},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "capacity", Reason: "min=0"}},
+				"message":    "Validation failed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.PATCH(UpdateRoute, h.Update)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.args.method, tt.args.path, strings.NewReader(tt.args.body))
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_DeleteById(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		path string
+	}
+	l := logger.New(false)
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "normal",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesDelete(gomock.Any(), 1, 2).Return(int64(1), nil).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{path: "/storages/1?migrate_to=2"},
+			wantCode: http.StatusOK,
+			wantRes: map[string]interface{}{
+				"code":    http.StatusOK,
+				"message": "OK",
+			},
+		}, {
+			name: "storage not empty",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesDelete(gomock.Any(), 1, 0).Return(int64(-1), fmt.Errorf("test: %w",
+						&registry.StorageNotEmptyError{StorageId: 1, Remains: []storages.Remains{{UniqCode: 5, Count: 10}}})).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{path: "/storages/1"},
+			wantCode: http.StatusConflict,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusConflict,
+				"data":       []storages.Remains{{UniqCode: 5, Count: 10}},
+				"error_code": apierror.CodeStorageNotEmpty,
+				"message":    "Storage still holds goods, pass migrate_to to move them",
+			},
+		}, {
+			name:     "migrate to itself",
+			fields:   fields{registry: mock_registry.NewMockDb(gomock.NewController(t)), log: l},
+			args:     args{path: "/storages/1?migrate_to=1"},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidQuery,
+				"errors":     []apierror.FieldError{{Field: "migrate_to", Reason: "nefield=id"}},
+				"message":    "Invalid query",
+			},
+		}, {
+			name:     "invalid id",
+			fields:   fields{registry: mock_registry.NewMockDb(gomock.NewController(t)), log: l},
+			args:     args{path: "/storages/abc"},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "id", Reason: "must be an integer"}},
+				"message":    "Invalid id",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.DELETE(StorageRoute, h.DeleteById)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", tt.args.path, nil)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
+
+func TestHandler_SetAvailability(t *testing.T) {
+	type fields struct {
+		registry registry.Db
+		log      logrus.FieldLogger
+	}
+	type args struct {
+		path string
+		body string
+	}
+	l := logger.New(false)
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantRes  map[string]interface{}
+		wantCode int
+	}{
+		{
+			name: "normal",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesChangeAccess(gomock.Any(), 3, false).Return(int64(1), nil).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{path: "/storages/3/availability", body: `{"available": false}`},
+			wantCode: http.StatusOK,
+			wantRes: map[string]interface{}{
+				"code":    http.StatusOK,
+				"message": "OK",
+			},
+		}, {
+			name: "storage not found",
+			fields: fields{
+				registry: func() *mock_registry.MockDb {
+					ctrl := gomock.NewController(t)
+					m := mock_registry.NewMockDb(ctrl)
+					m.EXPECT().StoragesChangeAccess(gomock.Any(), 3, true).
+						Return(int64(0), fmt.Errorf("test: %w", registry.ErrStorageNotFound)).AnyTimes()
+					return m
+				}(),
+				log: l,
+			},
+			args:     args{path: "/storages/3/availability", body: `{"available": true}`},
+			wantCode: http.StatusNotFound,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusNotFound,
+				"error_code": apierror.CodeStorageNotFound,
+				"message":    "Storage not found",
+			},
+		}, {
+			name:     "without available",
+			fields:   fields{registry: mock_registry.NewMockDb(gomock.NewController(t)), log: l},
+			args:     args{path: "/storages/3/availability", body: `{}`},
+			wantCode: http.StatusUnprocessableEntity,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusUnprocessableEntity,
+				"error_code": apierror.CodeValidationFailed,
+				"errors":     []apierror.FieldError{{Field: "available", Reason: "required"}},
+				"message":    "Validation failed",
+			},
+		}, {
+			name:     "invalid id",
+			fields:   fields{registry: mock_registry.NewMockDb(gomock.NewController(t)), log: l},
+			args:     args{path: "/storages/abc/availability", body: `{"available": true}`},
+			wantCode: http.StatusBadRequest,
+			wantRes: map[string]interface{}{
+				"code":       http.StatusBadRequest,
+				"error_code": apierror.CodeInvalidParam,
+				"errors":     []apierror.FieldError{{Field: "id", Reason: "must be an integer"}},
+				"message":    "Invalid id",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				registry: tt.fields.registry,
+				log:      tt.fields.log,
+			}
+			router := gin.Default()
+			gin.SetMode(gin.ReleaseMode)
+			router.PUT(AvailabilityRoute, h.SetAvailability)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", tt.args.path, strings.NewReader(tt.args.body))
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			bytes, _ := json.Marshal(tt.wantRes)
+			assert.Equal(t, string(bytes), w.Body.String())
+		})
+	}
+}
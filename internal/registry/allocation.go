@@ -0,0 +1,142 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/reservations"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Stock is free quantity of a good on one available storage, a candidate for reservation.
+type Stock struct {
+	RemainsId int
+	StorageId int
+	Available int
+	Priority  int // priority of the storage
+}
+
+// Allocator decides which storages a reservation takes goods from.
+// It returns the stock to drain in order, notes left out of the result are not used by the reservation.
+type Allocator interface {
+	Allocate(req reservations.Request, stock []Stock) []Stock
+}
+
+type AllocatorFunc func(req reservations.Request, stock []Stock) []Stock
+
+func (f AllocatorFunc) Allocate(req reservations.Request, stock []Stock) []Stock {
+	return f(req, stock)
+}
+
+// Names of allocation strategies accepted by AllocatorByName.
+const (
+	AllocationFewestStorages = "fewest"
+	AllocationPriority       = "priority"
+	AllocationLargestStock   = "largest"
+)
+
+// AllocatorByName builds one of the predefined strategies, priority is used only by the priority strategy.
+func AllocatorByName(name string, priority []int) (Allocator, error) {
+	switch name {
+	case AllocationFewestStorages:
+		return FewestStorages(), nil
+	case AllocationPriority:
+		return StoragePriority(priority), nil
+	case AllocationLargestStock:
+		return LargestStock(), nil
+	default:
+		return nil, fmt.Errorf("unknown allocation strategy %q", name)
+	}
+}
+
+// ParseStorageIds parses a comma separated list of storage ids, e.g. "3,1,2".
+func ParseStorageIds(str string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse storage id %q: %w", part, err)
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+// FewestStorages takes the whole count from a single storage when one can satisfy it,
+// choosing the smallest such stock to keep large ones intact. Otherwise the largest stocks are drained first.
+func FewestStorages() Allocator {
+	return AllocatorFunc(func(req reservations.Request, stock []Stock) []Stock {
+		best := -1
+		for i, tmp := range stock {
+			if tmp.Available >= req.Count && (best == -1 || tmp.Available < stock[best].Available) {
+				best = i
+			}
+		}
+		if best != -1 {
+			return []Stock{stock[best]}
+		}
+		return byLargestStock(stock)
+	})
+}
+
+// LargestStock drains storages with the most free goods first.
+func LargestStock() Allocator {
+	return AllocatorFunc(func(req reservations.Request, stock []Stock) []Stock {
+		return byLargestStock(stock)
+	})
+}
+
+// StoragePriority drains storages by their priority, highest first.
+// Storages of equal priority are drained in the given order, storages not listed go after them.
+func StoragePriority(order []int) Allocator {
+	return AllocatorFunc(func(req reservations.Request, stock []Stock) []Stock {
+		result := byStorageOrder(stock, order)
+		slices.SortStableFunc(result, func(a, b Stock) int {
+			return b.Priority - a.Priority
+		})
+		return result
+	})
+}
+
+// Pinned restricts the reservation to the storages pinned in the request, drained in the pinned order.
+// Requests without pinned storages are allocated by next.
+func Pinned(next Allocator) Allocator {
+	return AllocatorFunc(func(req reservations.Request, stock []Stock) []Stock {
+		if len(req.Storages) == 0 {
+			return next.Allocate(req, stock)
+		}
+		var pinned []Stock
+		for _, tmp := range stock {
+			if slices.Contains(req.Storages, tmp.StorageId) {
+				pinned = append(pinned, tmp)
+			}
+		}
+		return byStorageOrder(pinned, req.Storages)
+	})
+}
+
+func byLargestStock(stock []Stock) []Stock {
+	result := slices.Clone(stock)
+	slices.SortStableFunc(result, func(a, b Stock) int {
+		return b.Available - a.Available
+	})
+	return result
+}
+
+func byStorageOrder(stock []Stock, order []int) []Stock {
+	rank := func(storageId int) int {
+		if i := slices.Index(order, storageId); i != -1 {
+			return i
+		}
+		return len(order)
+	}
+	result := slices.Clone(stock)
+	slices.SortStableFunc(result, func(a, b Stock) int {
+		return rank(a.StorageId) - rank(b.StorageId)
+	})
+	return result
+}
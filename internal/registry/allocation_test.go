@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/reservations"
+	"reflect"
+	"testing"
+)
+
+func TestAllocators(t *testing.T) {
+	stock := []Stock{
+		{RemainsId: 1, StorageId: 1, Available: 4},
+		{RemainsId: 2, StorageId: 2, Available: 10},
+		{RemainsId: 3, StorageId: 3, Available: 6},
+		{RemainsId: 4, StorageId: 4, Available: 2, Priority: 5},
+	}
+	tests := []struct {
+		name      string
+		allocator Allocator
+		req       reservations.Request
+		want      []Stock
+	}{
+		{
+			name:      "fewest storages, single smallest storage that can satisfy the count",
+			allocator: FewestStorages(),
+			req:       reservations.Request{UniqCode: 1, Count: 5},
+			want:      []Stock{stock[2]},
+		}, {
+			name:      "fewest storages, no single storage is enough",
+			allocator: FewestStorages(),
+			req:       reservations.Request{UniqCode: 1, Count: 15},
+			want:      []Stock{stock[1], stock[2], stock[0], stock[3]},
+		}, {
+			name:      "largest stock",
+			allocator: LargestStock(),
+			req:       reservations.Request{UniqCode: 1, Count: 5},
+			want:      []Stock{stock[1], stock[2], stock[0], stock[3]},
+		}, {
+			name:      "storage priority, then storage order",
+			allocator: StoragePriority([]int{3, 1}),
+			req:       reservations.Request{UniqCode: 1, Count: 5},
+			want:      []Stock{stock[3], stock[2], stock[0], stock[1]},
+		}, {
+			name:      "storage priority only",
+			allocator: StoragePriority(nil),
+			req:       reservations.Request{UniqCode: 1, Count: 5},
+			want:      []Stock{stock[3], stock[0], stock[1], stock[2]},
+		}, {
+			name:      "pinned storages",
+			allocator: Pinned(LargestStock()),
+			req:       reservations.Request{UniqCode: 1, Count: 5, Storages: []int{1, 3}},
+			want:      []Stock{stock[0], stock[2]},
+		}, {
+			name:      "pinned storages without stock",
+			allocator: Pinned(LargestStock()),
+			req:       reservations.Request{UniqCode: 1, Count: 5, Storages: []int{6}},
+			want:      nil,
+		}, {
+			name:      "nothing pinned",
+			allocator: Pinned(StoragePriority([]int{2})),
+			req:       reservations.Request{UniqCode: 1, Count: 5},
+			want:      []Stock{stock[3], stock[1], stock[0], stock[2]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.allocator.Allocate(tt.req, stock)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Allocate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocatorByName(t *testing.T) {
+	for _, name := range []string{AllocationFewestStorages, AllocationPriority, AllocationLargestStock} {
+		if _, err := AllocatorByName(name, nil); err != nil {
+			t.Errorf("AllocatorByName(%q) error = %v", name, err)
+		}
+	}
+	if _, err := AllocatorByName("random", nil); err == nil {
+		t.Errorf("AllocatorByName() expected error for unknown strategy")
+	}
+}
+
+func TestParseStorageIds(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		want    []int
+		wantErr bool
+	}{
+		{name: "normal", str: "3, 1,2", want: []int{3, 1, 2}},
+		{name: "empty", str: "", want: nil},
+		{name: "invalid", str: "3,a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStorageIds(tt.str)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseStorageIds() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStorageIds() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
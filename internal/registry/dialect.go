@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Names of SQL dialects accepted by DialectByName.
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+)
+
+// Dialect adapts the queries of the registry, which are written for MySQL, to the database they run on.
+type Dialect interface {
+	// DriverName is the database/sql driver to open the database with.
+	DriverName() string
+	// isolation is the level of registry transactions, they must not let reserves of the same stock interleave.
+	isolation() sql.IsolationLevel
+	// rebind rewrites `?` placeholders of the query.
+	rebind(query string) string
+	// upsert starts the clause that updates the row conflicting by the unique columns with an insert.
+	upsert(columns string) string
+	// insert runs the insert and returns the id of the added row.
+	insert(ctx context.Context, q queryer, query string, args ...any) (int64, error)
+	// duplicate reports whether err is an insert violating a unique key.
+	duplicate(err error) bool
+	// retryable reports whether err has rolled the transaction back and it can be run again.
+	retryable(err error) bool
+}
+
+// DialectByName returns one of the supported dialects.
+func DialectByName(name string) (Dialect, error) {
+	switch name {
+	case DialectMySQL:
+		return MySQL(), nil
+	case DialectPostgres:
+		return Postgres(), nil
+	default:
+		return nil, fmt.Errorf("unknown database dialect %q", name)
+	}
+}
+
+// WithDialect sets the SQL dialect of the database, MySQL is used by default.
+func WithDialect(dialect Dialect) Option {
+	return func(d *Database) {
+		d.dialect = dialect
+	}
+}
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// runner runs queries on the connection or in the transaction it wraps, rewritten for the dialect.
+type runner struct {
+	q       queryer
+	dialect Dialect
+}
+
+func (r runner) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.q.ExecContext(ctx, r.dialect.rebind(query), args...)
+}
+
+func (r runner) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.q.QueryContext(ctx, r.dialect.rebind(query), args...)
+}
+
+func (r runner) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.q.QueryRowContext(ctx, r.dialect.rebind(query), args...)
+}
+
+// insert runs the insert into a table with an `id` primary key and returns the id of the added row.
+func (r runner) insert(ctx context.Context, query string, args ...any) (int64, error) {
+	return r.dialect.insert(ctx, r.q, query, args...)
+}
+
+// db runs queries outside of transactions.
+func (d *Database) db() runner {
+	return d.runner(d.conn)
+}
+
+func (d *Database) runner(q queryer) runner {
+	dialect := d.dialect
+	if dialect == nil {
+		dialect = MySQL()
+	}
+	return runner{q: q, dialect: dialect}
+}
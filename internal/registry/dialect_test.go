@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"strings"
+	"testing"
+)
+
+// forEachDialect runs the sqlmock table of the test against every dialect.
+func forEachDialect(t *testing.T, test func(t *testing.T, dialect Dialect)) {
+	for _, name := range []string{DialectMySQL, DialectPostgres} {
+		dialect, err := DialectByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			test(t, dialect)
+		})
+	}
+}
+
+// dialectMock takes expectations written in MySQL and expects them as the registry runs them in its dialect.
+type dialectMock struct {
+	sqlmock.Sqlmock
+	dialect Dialect
+}
+
+func newDialectMock(dialect Dialect) (*sql.DB, *dialectMock, error) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	return db, &dialectMock{Sqlmock: mock, dialect: dialect}, err
+}
+
+// rewrite turns a MySQL query into the dialect, the upserts of the registry all conflict on remains (good_id, storage_id).
+func (m *dialectMock) rewrite(query string) string {
+	query = strings.ReplaceAll(query, MySQL().upsert(""), m.dialect.upsert("good_id, storage_id"))
+	return m.dialect.rebind(query)
+}
+
+func (m *dialectMock) ExpectQuery(query string) *sqlmock.ExpectedQuery {
+	return m.Sqlmock.ExpectQuery(m.rewrite(query))
+}
+
+func (m *dialectMock) ExpectExec(query string) *sqlmock.ExpectedExec {
+	return m.Sqlmock.ExpectExec(m.rewrite(query))
+}
+
+// ExpectInsert expects an insert returning the id of the row: an exec on MySQL, a query with RETURNING on PostgreSQL.
+func (m *dialectMock) ExpectInsert(query string) *expectedInsert {
+	if _, ok := m.dialect.(postgresDialect); ok {
+		return &expectedInsert{query: m.Sqlmock.ExpectQuery(m.rewrite(query + " RETURNING id"))}
+	}
+	return &expectedInsert{exec: m.Sqlmock.ExpectExec(m.rewrite(query))}
+}
+
+// duplicate is the error of an insert violating a unique key.
+func (m *dialectMock) duplicate() error {
+	if _, ok := m.dialect.(postgresDialect); ok {
+		return &pgconn.PgError{Code: errUniqueViolation, Message: "duplicate key value violates unique constraint"}
+	}
+	return &mysql.MySQLError{Number: errDuplicateEntry, Message: "Duplicate entry"}
+}
+
+// deadlock is the error of a transaction rolled back by a deadlock.
+func (m *dialectMock) deadlock() error {
+	if _, ok := m.dialect.(postgresDialect); ok {
+		return &pgconn.PgError{Code: errDeadlockDetected, Message: "deadlock detected"}
+	}
+	return &mysql.MySQLError{Number: errDeadlock, Message: "Deadlock found when trying to get lock"}
+}
+
+type expectedInsert struct {
+	exec  *sqlmock.ExpectedExec
+	query *sqlmock.ExpectedQuery
+}
+
+func (e *expectedInsert) WithArgs(args ...driver.Value) *expectedInsert {
+	if e.query != nil {
+		e.query.WithArgs(args...)
+	} else {
+		e.exec.WithArgs(args...)
+	}
+	return e
+}
+
+// WillReturnId adds the row with the id.
+func (e *expectedInsert) WillReturnId(id int64) {
+	if e.query != nil {
+		e.query.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+	} else {
+		e.exec.WillReturnResult(sqlmock.NewResult(id, 1))
+	}
+}
+
+// WillFailId adds the row, but its id can't be read.
+func (e *expectedInsert) WillFailId(err error) {
+	if e.query != nil {
+		e.query.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(0).RowError(0, err))
+	} else {
+		e.exec.WillReturnResult(sqlmock.NewErrorResult(err))
+	}
+}
+
+func (e *expectedInsert) WillReturnError(err error) {
+	if e.query != nil {
+		e.query.WillReturnError(err)
+	} else {
+		e.exec.WillReturnError(err)
+	}
+}
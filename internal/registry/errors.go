@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/storages"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrGoodNotFound       = errors.New("good not found")
+	ErrStorageNotFound    = errors.New("storage not found")
+	ErrStorageUnavailable = errors.New("storage is not available")
+	ErrInsufficientStock  = errors.New("not enough goods on available storages")
+	ErrExceedsReserved    = errors.New("quantity exceeds reserved")
+	ErrInvalidQuery       = errors.New("invalid list query")
+	ErrStorageNotEmpty    = errors.New("storage still holds goods")
+	ErrGoodReserved       = errors.New("good has active reservations")
+	ErrDuplicateUniqCode  = errors.New("good with this uniq_code already exists")
+	ErrConflict           = errors.New("conflicting concurrent change")
+	// ErrReservationNotFound and ErrReservationClosed are returned by release and ship of a reservation.
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationClosed   = errors.New("reservation is not active")
+	// ErrInvalidArgument is returned for arguments the registry refuses before touching the database.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// BatchError is returned by ReserveGoods when at least one line can't be reserved.
+// Errors is aligned with the requested lines, a nil element means the line itself could be reserved.
+type BatchError struct {
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	failed := 0
+	var first error
+	for _, err := range e.Errors {
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return fmt.Sprintf("can't reserve %d of %d lines: %v", failed, len(e.Errors), first)
+}
+
+func (e *BatchError) Unwrap() []error {
+	return e.Errors
+}
+
+// DuplicateUniqCodeError is returned by GoodAdd when a good with the uniq_code already exists.
+type DuplicateUniqCodeError struct {
+	UniqCode int
+	GoodId   int64 // zero when the existing good can't be found
+}
+
+func (e *DuplicateUniqCodeError) Error() string {
+	return fmt.Sprintf("can't add good %d: %v with id %d", e.UniqCode, ErrDuplicateUniqCode, e.GoodId)
+}
+
+func (e *DuplicateUniqCodeError) Unwrap() error {
+	return ErrDuplicateUniqCode
+}
+
+// StorageNotEmptyError is returned by StoragesDelete when the storage holds goods and there is nowhere to migrate them.
+type StorageNotEmptyError struct {
+	StorageId int
+	Remains   []storages.Remains
+}
+
+func (e *StorageNotEmptyError) Error() string {
+	return fmt.Sprintf("can't delete storage %d: %d goods on it: %v", e.StorageId, len(e.Remains), ErrStorageNotEmpty)
+}
+
+func (e *StorageNotEmptyError) Unwrap() error {
+	return ErrStorageNotEmpty
+}
@@ -11,9 +11,13 @@ package mock_registry
 
 import (
 	goods "LamodaTest/internal/entity/goods"
+	movements "LamodaTest/internal/entity/movements"
+	pages "LamodaTest/internal/entity/pages"
+	reservations "LamodaTest/internal/entity/reservations"
 	storages "LamodaTest/internal/entity/storages"
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -42,18 +46,34 @@ func (m *MockDb) EXPECT() *MockDbMockRecorder {
 }
 
 // AvailableGoods mocks base method.
-func (m *MockDb) AvailableGoods(ctx context.Context) (map[int]goods.RemainsDTO, error) {
+func (m *MockDb) AvailableGoods(ctx context.Context, filter goods.Filter, page pages.Query) (map[int]goods.RemainsDTO, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AvailableGoods", ctx)
+	ret := m.ctrl.Call(m, "AvailableGoods", ctx, filter, page)
 	ret0, _ := ret[0].(map[int]goods.RemainsDTO)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AvailableGoods indicates an expected call of AvailableGoods.
+func (mr *MockDbMockRecorder) AvailableGoods(ctx, filter, page any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AvailableGoods", reflect.TypeOf((*MockDb)(nil).AvailableGoods), ctx, filter, page)
+}
+
+// ExpireReservations mocks base method.
+func (m *MockDb) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireReservations", ctx, now)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AvailableGoods indicates an expected call of AvailableGoods.
-func (mr *MockDbMockRecorder) AvailableGoods(ctx any) *gomock.Call {
+// ExpireReservations indicates an expected call of ExpireReservations.
+func (mr *MockDbMockRecorder) ExpireReservations(ctx, now any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AvailableGoods", reflect.TypeOf((*MockDb)(nil).AvailableGoods), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireReservations", reflect.TypeOf((*MockDb)(nil).ExpireReservations), ctx, now)
 }
 
 // GoodAdd mocks base method.
@@ -86,78 +106,185 @@ func (mr *MockDbMockRecorder) GoodDelete(ctx, uniqCode any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GoodDelete", reflect.TypeOf((*MockDb)(nil).GoodDelete), ctx, uniqCode)
 }
 
+// GoodRestore mocks base method.
+func (m *MockDb) GoodRestore(ctx context.Context, uniqCode int) (goods.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GoodRestore", ctx, uniqCode)
+	ret0, _ := ret[0].(goods.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GoodRestore indicates an expected call of GoodRestore.
+func (mr *MockDbMockRecorder) GoodRestore(ctx, uniqCode any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GoodRestore", reflect.TypeOf((*MockDb)(nil).GoodRestore), ctx, uniqCode)
+}
+
+// GoodStock mocks base method.
+func (m *MockDb) GoodStock(ctx context.Context, uniqCode int) (goods.Stock, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GoodStock", ctx, uniqCode)
+	ret0, _ := ret[0].(goods.Stock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GoodStock indicates an expected call of GoodStock.
+func (mr *MockDbMockRecorder) GoodStock(ctx, uniqCode any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GoodStock", reflect.TypeOf((*MockDb)(nil).GoodStock), ctx, uniqCode)
+}
+
+// GoodUpdate mocks base method.
+func (m *MockDb) GoodUpdate(ctx context.Context, uniqCode int, update goods.Update) (goods.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GoodUpdate", ctx, uniqCode, update)
+	ret0, _ := ret[0].(goods.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GoodUpdate indicates an expected call of GoodUpdate.
+func (mr *MockDbMockRecorder) GoodUpdate(ctx, uniqCode, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GoodUpdate", reflect.TypeOf((*MockDb)(nil).GoodUpdate), ctx, uniqCode, update)
+}
+
 // Goods mocks base method.
-func (m *MockDb) Goods(ctx context.Context) ([]goods.Good, error) {
+func (m *MockDb) Goods(ctx context.Context, filter goods.Filter, page pages.Query) ([]goods.Good, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Goods", ctx)
+	ret := m.ctrl.Call(m, "Goods", ctx, filter, page)
 	ret0, _ := ret[0].([]goods.Good)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Goods indicates an expected call of Goods.
+func (mr *MockDbMockRecorder) Goods(ctx, filter, page any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Goods", reflect.TypeOf((*MockDb)(nil).Goods), ctx, filter, page)
+}
+
+// Movements mocks base method.
+func (m *MockDb) Movements(ctx context.Context, uniqCode int, afterId int64, limit int) ([]movements.Movement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Movements", ctx, uniqCode, afterId, limit)
+	ret0, _ := ret[0].([]movements.Movement)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Goods indicates an expected call of Goods.
-func (mr *MockDbMockRecorder) Goods(ctx any) *gomock.Call {
+// Movements indicates an expected call of Movements.
+func (mr *MockDbMockRecorder) Movements(ctx, uniqCode, afterId, limit any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Goods", reflect.TypeOf((*MockDb)(nil).Goods), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Movements", reflect.TypeOf((*MockDb)(nil).Movements), ctx, uniqCode, afterId, limit)
 }
 
-// ReleaseGood mocks base method.
-func (m *MockDb) ReleaseGood(ctx context.Context, uniqId, count int) error {
+// ReceiveGood mocks base method.
+func (m *MockDb) ReceiveGood(ctx context.Context, uniqCode, storageId, count int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReleaseGood", ctx, uniqId, count)
+	ret := m.ctrl.Call(m, "ReceiveGood", ctx, uniqCode, storageId, count)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// ReceiveGood indicates an expected call of ReceiveGood.
+func (mr *MockDbMockRecorder) ReceiveGood(ctx, uniqCode, storageId, count any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveGood", reflect.TypeOf((*MockDb)(nil).ReceiveGood), ctx, uniqCode, storageId, count)
+}
+
+// ReleaseGood mocks base method.
+func (m *MockDb) ReleaseGood(ctx context.Context, reservationId int64) (reservations.Reservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseGood", ctx, reservationId)
+	ret0, _ := ret[0].(reservations.Reservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // ReleaseGood indicates an expected call of ReleaseGood.
-func (mr *MockDbMockRecorder) ReleaseGood(ctx, uniqId, count any) *gomock.Call {
+func (mr *MockDbMockRecorder) ReleaseGood(ctx, reservationId any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseGood", reflect.TypeOf((*MockDb)(nil).ReleaseGood), ctx, uniqId, count)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseGood", reflect.TypeOf((*MockDb)(nil).ReleaseGood), ctx, reservationId)
 }
 
 // ReserveGood mocks base method.
-func (m *MockDb) ReserveGood(ctx context.Context, uniqId, count int) (map[int]int, error) {
+func (m *MockDb) ReserveGood(ctx context.Context, req reservations.Request) (reservations.Reservation, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReserveGood", ctx, uniqId, count)
-	ret0, _ := ret[0].(map[int]int)
+	ret := m.ctrl.Call(m, "ReserveGood", ctx, req)
+	ret0, _ := ret[0].(reservations.Reservation)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ReserveGood indicates an expected call of ReserveGood.
-func (mr *MockDbMockRecorder) ReserveGood(ctx, uniqId, count any) *gomock.Call {
+func (mr *MockDbMockRecorder) ReserveGood(ctx, req any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveGood", reflect.TypeOf((*MockDb)(nil).ReserveGood), ctx, uniqId, count)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveGood", reflect.TypeOf((*MockDb)(nil).ReserveGood), ctx, req)
 }
 
-// Storages mocks base method.
-func (m *MockDb) Storages(ctx context.Context, all bool) ([]storages.Storage, error) {
+// ReserveGoods mocks base method.
+func (m *MockDb) ReserveGoods(ctx context.Context, reqs []reservations.Request) ([]reservations.Reservation, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Storages", ctx, all)
-	ret0, _ := ret[0].([]storages.Storage)
+	ret := m.ctrl.Call(m, "ReserveGoods", ctx, reqs)
+	ret0, _ := ret[0].([]reservations.Reservation)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// ReserveGoods indicates an expected call of ReserveGoods.
+func (mr *MockDbMockRecorder) ReserveGoods(ctx, reqs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveGoods", reflect.TypeOf((*MockDb)(nil).ReserveGoods), ctx, reqs)
+}
+
+// ShipGood mocks base method.
+func (m *MockDb) ShipGood(ctx context.Context, reservationId int64, count int) (reservations.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShipGood", ctx, reservationId, count)
+	ret0, _ := ret[0].(reservations.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShipGood indicates an expected call of ShipGood.
+func (mr *MockDbMockRecorder) ShipGood(ctx, reservationId, count any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShipGood", reflect.TypeOf((*MockDb)(nil).ShipGood), ctx, reservationId, count)
+}
+
+// Storages mocks base method.
+func (m *MockDb) Storages(ctx context.Context, filter storages.Filter, page pages.Query) ([]storages.Storage, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Storages", ctx, filter, page)
+	ret0, _ := ret[0].([]storages.Storage)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
 // Storages indicates an expected call of Storages.
-func (mr *MockDbMockRecorder) Storages(ctx, all any) *gomock.Call {
+func (mr *MockDbMockRecorder) Storages(ctx, filter, page any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Storages", reflect.TypeOf((*MockDb)(nil).Storages), ctx, all)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Storages", reflect.TypeOf((*MockDb)(nil).Storages), ctx, filter, page)
 }
 
 // StoragesAdd mocks base method.
-func (m *MockDb) StoragesAdd(ctx context.Context, name string, available bool) (int64, error) {
+func (m *MockDb) StoragesAdd(ctx context.Context, storage storages.Storage) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StoragesAdd", ctx, name, available)
+	ret := m.ctrl.Call(m, "StoragesAdd", ctx, storage)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // StoragesAdd indicates an expected call of StoragesAdd.
-func (mr *MockDbMockRecorder) StoragesAdd(ctx, name, available any) *gomock.Call {
+func (mr *MockDbMockRecorder) StoragesAdd(ctx, storage any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoragesAdd", reflect.TypeOf((*MockDb)(nil).StoragesAdd), ctx, name, available)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoragesAdd", reflect.TypeOf((*MockDb)(nil).StoragesAdd), ctx, storage)
 }
 
 // StoragesChangeAccess mocks base method.
@@ -176,16 +303,86 @@ func (mr *MockDbMockRecorder) StoragesChangeAccess(ctx, id, available any) *gomo
 }
 
 // StoragesDelete mocks base method.
-func (m *MockDb) StoragesDelete(ctx context.Context, id int) (int64, error) {
+func (m *MockDb) StoragesDelete(ctx context.Context, id, migrateTo int) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StoragesDelete", ctx, id)
+	ret := m.ctrl.Call(m, "StoragesDelete", ctx, id, migrateTo)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // StoragesDelete indicates an expected call of StoragesDelete.
-func (mr *MockDbMockRecorder) StoragesDelete(ctx, id any) *gomock.Call {
+func (mr *MockDbMockRecorder) StoragesDelete(ctx, id, migrateTo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoragesDelete", reflect.TypeOf((*MockDb)(nil).StoragesDelete), ctx, id, migrateTo)
+}
+
+// StoragesUpdate mocks base method.
+func (m *MockDb) StoragesUpdate(ctx context.Context, id int, update storages.Update) (storages.Storage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoragesUpdate", ctx, id, update)
+	ret0, _ := ret[0].(storages.Storage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StoragesUpdate indicates an expected call of StoragesUpdate.
+func (mr *MockDbMockRecorder) StoragesUpdate(ctx, id, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoragesUpdate", reflect.TypeOf((*MockDb)(nil).StoragesUpdate), ctx, id, update)
+}
+
+// TransferStock mocks base method.
+func (m *MockDb) TransferStock(ctx context.Context, uniqCode, fromStorageId, toStorageId, count int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferStock", ctx, uniqCode, fromStorageId, toStorageId, count)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransferStock indicates an expected call of TransferStock.
+func (mr *MockDbMockRecorder) TransferStock(ctx, uniqCode, fromStorageId, toStorageId, count any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferStock", reflect.TypeOf((*MockDb)(nil).TransferStock), ctx, uniqCode, fromStorageId, toStorageId, count)
+}
+
+// MockrowScanner is a mock of rowScanner interface.
+type MockrowScanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockrowScannerMockRecorder
+}
+
+// MockrowScannerMockRecorder is the mock recorder for MockrowScanner.
+type MockrowScannerMockRecorder struct {
+	mock *MockrowScanner
+}
+
+// NewMockrowScanner creates a new mock instance.
+func NewMockrowScanner(ctrl *gomock.Controller) *MockrowScanner {
+	mock := &MockrowScanner{ctrl: ctrl}
+	mock.recorder = &MockrowScannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrowScanner) EXPECT() *MockrowScannerMockRecorder {
+	return m.recorder
+}
+
+// Scan mocks base method.
+func (m *MockrowScanner) Scan(dest ...any) error {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range dest {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Scan", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockrowScannerMockRecorder) Scan(dest ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoragesDelete", reflect.TypeOf((*MockDb)(nil).StoragesDelete), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockrowScanner)(nil).Scan), dest...)
 }
@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/movements"
+	"context"
+	"fmt"
+	"time"
+)
+
+type correlationKey struct{}
+
+// WithCorrelationID stores the request correlation ID which is written to every stock movement made with ctx.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID stored by WithCorrelationID or an empty string.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// recordMovement appends the movement to the ledger in the same transaction as the change itself.
+func recordMovement(ctx context.Context, tx runner, goodId int, movement movements.Movement) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO stock_movements 
+		(good_id, uniq_code, storage_id, type, count_delta, reserved_delta, correlation_id, created_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		goodId, movement.UniqCode, movement.StorageId, movement.Type, movement.CountDelta, movement.ReservedDelta,
+		CorrelationID(ctx), time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		return fmt.Errorf("can't record %s movement of %d good on storage %d: %w",
+			movement.Type, movement.UniqCode, movement.StorageId, err)
+	}
+	return nil
+}
+
+// Movements returns up to limit movements of the good with ID greater than afterId, oldest first.
+func (d *Database) Movements(ctx context.Context, uniqCode int, afterId int64, limit int) ([]movements.Movement, error) {
+	rows, err := d.db().QueryContext(ctx, `SELECT 
+			id, 
+			uniq_code, 
+			storage_id, 
+			type, 
+			count_delta, 
+			reserved_delta, 
+			correlation_id, 
+			created_at 
+		FROM stock_movements 
+		WHERE uniq_code = ? AND id > ? 
+		ORDER BY id 
+		LIMIT ?`, uniqCode, afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't request movements of %d good: %w", uniqCode, err)
+	}
+	defer rows.Close()
+	result := []movements.Movement{}
+	for rows.Next() {
+		tmp := movements.Movement{}
+		err = rows.Scan(&tmp.ID, &tmp.UniqCode, &tmp.StorageId, &tmp.Type, &tmp.CountDelta, &tmp.ReservedDelta,
+			&tmp.CorrelationId, &tmp.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("can't scan movement of %d good: %w", uniqCode, err)
+		}
+		result = append(result, tmp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when try get movements of %d good: %w", uniqCode, err)
+	}
+	return result, nil
+}
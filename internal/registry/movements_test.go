@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/movements"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const movementStr = "INSERT INTO stock_movements (good_id, uniq_code, storage_id, type, count_delta, reserved_delta, correlation_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+// expectMovement expects a ledger note written without correlation ID.
+func expectMovement(mock sqlmock.Sqlmock, goodId, uniqCode, storageId int, movementType string, countDelta, reservedDelta int) *sqlmock.ExpectedExec {
+	return mock.ExpectExec(movementStr).
+		WithArgs(goodId, uniqCode, storageId, movementType, countDelta, reservedDelta, "", sqlmock.AnyArg())
+}
+
+func TestRecordMovement_CorrelationID(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	mock.ExpectBegin()
+	mock.ExpectExec(movementStr).
+		WithArgs(4, 1, 2, movements.TypeReceive, 5, 0, "req-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithCorrelationID(context.TODO(), "req-1")
+	err = recordMovement(ctx, runner{q: tx, dialect: MySQL()}, 4, movements.Movement{
+		UniqCode:   1,
+		StorageId:  2,
+		Type:       movements.TypeReceive,
+		CountDelta: 5,
+	})
+	if err != nil {
+		t.Errorf("recordMovement() error = %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("recordMovement() unmet expectations: %s", err)
+	}
+}
+
+func TestDatabase_Movements(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+		}
+		type args struct {
+			ctx      context.Context
+			uniqCode int
+			afterId  int64
+			limit    int
+		}
+		sqlStr := "SELECT id, uniq_code, storage_id, type, count_delta, reserved_delta, correlation_id, created_at FROM stock_movements WHERE uniq_code = ? AND id > ? ORDER BY id LIMIT ?"
+		createdAt := time.Date(2024, 2, 14, 21, 56, 3, 0, time.UTC)
+		columns := []string{"id", "uniq_code", "storage_id", "type", "count_delta", "reserved_delta", "correlation_id", "created_at"}
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    []movements.Movement
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WithArgs(1, int64(3), 2).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(4, 1, 2, movements.TypeReceive, 10, 0, "req-1", createdAt).
+						AddRow(5, 1, 2, movements.TypeReserve, 0, 3, "", createdAt))
+					return fields{conn: db}
+				}(),
+				args: args{context.TODO(), 1, 3, 2},
+				want: []movements.Movement{
+					{ID: 4, UniqCode: 1, StorageId: 2, Type: movements.TypeReceive, CountDelta: 10, CorrelationId: "req-1", CreatedAt: createdAt},
+					{ID: 5, UniqCode: 1, StorageId: 2, Type: movements.TypeReserve, ReservedDelta: 3, CreatedAt: createdAt},
+				},
+			}, {
+				name: "empty",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WithArgs(1, int64(0), 100).WillReturnRows(sqlmock.NewRows(columns))
+					return fields{conn: db}
+				}(),
+				args: args{context.TODO(), 1, 0, 100},
+				want: []movements.Movement{},
+			}, {
+				name: "err sql",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WithArgs(1, int64(0), 100).WillReturnError(errors.New("test"))
+					return fields{conn: db}
+				}(),
+				args:    args{context.TODO(), 1, 0, 100},
+				wantErr: true,
+			}, {
+				name: "err scan",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WithArgs(1, int64(0), 100).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("id", 1, 2, movements.TypeReceive, 10, 0, "", createdAt))
+					return fields{conn: db}
+				}(),
+				args:    args{context.TODO(), 1, 0, 100},
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.Movements(tt.args.ctx, tt.args.uniqCode, tt.args.afterId, tt.args.limit)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Movements() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Movements() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
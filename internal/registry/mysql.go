@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL errors after which the server has rolled the transaction back and it can be run again.
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// errDuplicateEntry is the MySQL error of an insert violating a unique key.
+const errDuplicateEntry = 1062
+
+type mysqlDialect struct{}
+
+// MySQL is the dialect of migration/db.sql, the queries of the registry need no rewriting for it.
+func MySQL() Dialect {
+	return mysqlDialect{}
+}
+
+func (mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+func (mysqlDialect) isolation() sql.IsolationLevel {
+	return sql.LevelSerializable
+}
+
+func (mysqlDialect) rebind(query string) string {
+	return query
+}
+
+func (mysqlDialect) upsert(string) string {
+	return "ON DUPLICATE KEY UPDATE"
+}
+
+func (mysqlDialect) insert(ctx context.Context, q queryer, query string, args ...any) (int64, error) {
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return -1, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("can't get last inserted id: %w", err)
+	}
+	return id, nil
+}
+
+func (mysqlDialect) duplicate(err error) bool {
+	return isMysqlError(err, errDuplicateEntry)
+}
+
+func (mysqlDialect) retryable(err error) bool {
+	return isMysqlError(err, errDeadlock) || isMysqlError(err, errLockWaitTimeout)
+}
+
+func isMysqlError(err error, number uint16) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
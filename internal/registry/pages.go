@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/pages"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sortField is a column a list can be sorted by, value takes the column value from a listed item.
+type sortField[T any] struct {
+	column  string
+	numeric bool
+	value   func(T) any
+}
+
+// cursor is the position of the last item of a page, it is sent to clients as base64 of JSON.
+type cursor struct {
+	Value string `json:"v"`
+	Id    int64  `json:"id"`
+}
+
+// keyset pages through a list ordered by the sort column and then by id,
+// so rows with equal sort values are neither skipped nor repeated between pages.
+type keyset[T any] struct {
+	field    sortField[T]
+	idColumn string
+	id       func(T) int64
+	desc     bool
+	limit    int
+	after    *cursor
+	value    any // cursor value converted to the column type
+}
+
+func newKeyset[T any](query pages.Query, fields map[string]sortField[T], idColumn string, id func(T) int64) (keyset[T], error) {
+	name, desc := strings.CutPrefix(query.Sort, "-")
+	if name == "" {
+		name = "id"
+	}
+	field, ok := fields[name]
+	if !ok {
+		return keyset[T]{}, fmt.Errorf("can't sort by %q: %w", query.Sort, ErrInvalidQuery)
+	}
+	k := keyset[T]{field: field, idColumn: idColumn, id: id, desc: desc, limit: query.Limit}
+	if k.limit <= 0 {
+		k.limit = pages.DefaultLimit
+	}
+	k.limit = min(k.limit, pages.MaxLimit)
+	if query.Cursor == "" {
+		return k, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return keyset[T]{}, fmt.Errorf("can't decode cursor %q: %w", query.Cursor, ErrInvalidQuery)
+	}
+	k.after = &cursor{}
+	if err = json.Unmarshal(raw, k.after); err != nil {
+		return keyset[T]{}, fmt.Errorf("can't decode cursor %q: %w", query.Cursor, ErrInvalidQuery)
+	}
+	k.value = k.after.Value
+	if field.numeric {
+		if k.value, err = strconv.ParseInt(k.after.Value, 10, 64); err != nil {
+			return keyset[T]{}, fmt.Errorf("cursor %q doesn't match sort %q: %w", query.Cursor, query.Sort, ErrInvalidQuery)
+		}
+	}
+	return k, nil
+}
+
+// condition returns the WHERE condition selecting rows after the cursor, empty on the first page.
+func (k keyset[T]) condition() (string, []any) {
+	if k.after == nil {
+		return "", nil
+	}
+	op := ">"
+	if k.desc {
+		op = "<"
+	}
+	if k.field.column == k.idColumn {
+		return fmt.Sprintf("%s %s ?", k.idColumn, op), []any{k.after.Id}
+	}
+	return fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", k.field.column, op, k.field.column, k.idColumn, op),
+		[]any{k.value, k.value, k.after.Id}
+}
+
+func (k keyset[T]) orderBy() string {
+	direction := ""
+	if k.desc {
+		direction = " DESC"
+	}
+	if k.field.column == k.idColumn {
+		return k.idColumn + direction
+	}
+	return fmt.Sprintf("%s%s, %s%s", k.field.column, direction, k.idColumn, direction)
+}
+
+// page cuts the list fetched with limit + 1 rows down to the limit
+// and returns the cursor of the next page, which is empty on the last page.
+func (k keyset[T]) page(list []T) ([]T, string) {
+	if len(list) <= k.limit {
+		return list, ""
+	}
+	list = list[:k.limit]
+	last := list[len(list)-1]
+	next := cursor{Value: fmt.Sprint(k.field.value(last)), Id: k.id(last)}
+	raw, _ := json.Marshal(next)
+	return list, base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// where joins the conditions into a WHERE clause, empty conditions are skipped.
+func where(conditions ...string) string {
+	var parts []string
+	for _, condition := range conditions {
+		if condition != "" {
+			parts = append(parts, condition)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(parts, " AND ")
+}
+
+// likePattern matches any string containing s, LIKE wildcards in s are matched literally.
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/pages"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testCursor(value string, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"v":%q,"id":%d}`, value, id)))
+}
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         pages.Query
+		wantCondition string
+		wantArgs      []any
+		wantOrder     string
+		wantLimit     int
+		wantErr       error
+	}{
+		{
+			name:      "first page",
+			query:     pages.Query{},
+			wantOrder: "goods.id",
+			wantLimit: pages.DefaultLimit,
+		}, {
+			name:          "after id desc",
+			query:         pages.Query{Cursor: testCursor("7", 7), Limit: 5000, Sort: "-id"},
+			wantCondition: "goods.id < ?",
+			wantArgs:      []any{int64(7)},
+			wantOrder:     "goods.id DESC",
+			wantLimit:     pages.MaxLimit,
+		}, {
+			name:          "after name",
+			query:         pages.Query{Cursor: testCursor("Test", 2), Limit: 10, Sort: "name"},
+			wantCondition: "(goods.name > ? OR (goods.name = ? AND goods.id > ?))",
+			wantArgs:      []any{"Test", "Test", int64(2)},
+			wantOrder:     "goods.name, goods.id",
+			wantLimit:     10,
+		}, {
+			name:    "unknown sort",
+			query:   pages.Query{Sort: "-price"},
+			wantErr: ErrInvalidQuery,
+		}, {
+			name:    "broken cursor",
+			query:   pages.Query{Cursor: "eyJ2Ijo"},
+			wantErr: ErrInvalidQuery,
+		}, {
+			name:    "cursor value of another type",
+			query:   pages.Query{Cursor: testCursor("Test", 2), Sort: "uniq_code"},
+			wantErr: ErrInvalidQuery,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := goodKeyset(tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("goodKeyset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			condition, args := keys.condition()
+			if condition != tt.wantCondition || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("condition() = %q %v, want %q %v", condition, args, tt.wantCondition, tt.wantArgs)
+			}
+			if order := keys.orderBy(); order != tt.wantOrder {
+				t.Errorf("orderBy() = %q, want %q", order, tt.wantOrder)
+			}
+			if keys.limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", keys.limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	if got := likePattern(`50%_off\`); got != `%50\%\_off\\%` {
+		t.Errorf("likePattern() = %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"strconv"
+	"strings"
+)
+
+// PostgreSQL errors after which the transaction is rolled back and can be run again.
+const (
+	errSerializationFailure = "40001"
+	errDeadlockDetected     = "40P01"
+)
+
+// errUniqueViolation is the PostgreSQL error of an insert violating a unique key.
+const errUniqueViolation = "23505"
+
+type postgresDialect struct{}
+
+// Postgres is the dialect of migration/postgres.sql, used with the pgx driver.
+func Postgres() Dialect {
+	return postgresDialect{}
+}
+
+func (postgresDialect) DriverName() string {
+	return "pgx"
+}
+
+// isolation is read committed: the rows a transaction changes are locked with SELECT ... FOR UPDATE
+// and the rows it relies on with SELECT ... FOR SHARE, the locks of serializable MySQL reads.
+// Both wait for concurrent transactions and then read their result. Under serializable isolation
+// PostgreSQL fails such waiting transactions with a serialization failure instead.
+func (postgresDialect) isolation() sql.IsolationLevel {
+	return sql.LevelReadCommitted
+}
+
+// rebind numbers the placeholders, `?` inside quoted literals is kept.
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) upsert(columns string) string {
+	return "ON CONFLICT (" + columns + ") DO UPDATE SET"
+}
+
+// insert takes the id from RETURNING, the driver doesn't support LastInsertId.
+func (p postgresDialect) insert(ctx context.Context, q queryer, query string, args ...any) (int64, error) {
+	var id int64
+	if err := q.QueryRowContext(ctx, p.rebind(query+" RETURNING id"), args...).Scan(&id); err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (postgresDialect) duplicate(err error) bool {
+	return isPostgresError(err, errUniqueViolation)
+}
+
+func (postgresDialect) retryable(err error) bool {
+	return isPostgresError(err, errSerializationFailure) || isPostgresError(err, errDeadlockDetected)
+}
+
+func isPostgresError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
@@ -0,0 +1,211 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/movements"
+	"LamodaTest/internal/entity/reservations"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"testing"
+	"time"
+)
+
+func TestPostgres_rebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "no placeholders", query: "SELECT id FROM goods", want: "SELECT id FROM goods"},
+		{name: "placeholders", query: "update goods set name = ? where uniq_code = ?", want: "update goods set name = $1 where uniq_code = $2"},
+		{name: "quoted", query: "SELECT '?' FROM goods WHERE id = ? AND name = 'a''?'", want: "SELECT '?' FROM goods WHERE id = $1 AND name = 'a''?'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Postgres().rebind(tt.query); got != tt.want {
+				t.Errorf("rebind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectByName(t *testing.T) {
+	for name, driver := range map[string]string{DialectMySQL: "mysql", DialectPostgres: "pgx"} {
+		dialect, err := DialectByName(name)
+		if err != nil {
+			t.Errorf("DialectByName(%q) error = %v", name, err)
+			continue
+		}
+		if dialect.DriverName() != driver {
+			t.Errorf("DialectByName(%q) driver = %q, want %q", name, dialect.DriverName(), driver)
+		}
+	}
+	if _, err := DialectByName("oracle"); err == nil {
+		t.Errorf("DialectByName() expected error for unknown dialect")
+	}
+}
+
+func TestDatabase_GoodAdd_postgres(t *testing.T) {
+	sqlStr := "insert into goods (name, size, uniq_code) values ($1, $2, $3) RETURNING id"
+	tests := []struct {
+		name     string
+		expect   func(mock sqlmock.Sqlmock)
+		want     int64
+		wantGood int64
+		wantErr  error
+	}{
+		{
+			name: "normal",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlStr).WithArgs("test", "xs", 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			},
+			want: 7,
+		}, {
+			name: "duplicate uniq_code",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlStr).WithArgs("test", "xs", 1).
+					WillReturnError(&pgconn.PgError{Code: errUniqueViolation, Message: "duplicate key value violates unique constraint"})
+				mock.ExpectQuery("SELECT id FROM goods WHERE uniq_code = $1").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+			},
+			want:     -1,
+			wantGood: 6,
+			wantErr:  ErrDuplicateUniqCode,
+		}, {
+			name: "err sql",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlStr).WithArgs("test", "xs", 1).WillReturnError(errors.New("test"))
+			},
+			want:    -1,
+			wantErr: errors.New("test"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			tt.expect(mock)
+			d := New(db, WithDialect(Postgres()))
+			got, err := d.GoodAdd(context.TODO(), "test", "xs", 1)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("GoodAdd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var duplicate *DuplicateUniqCodeError
+			if errors.Is(tt.wantErr, ErrDuplicateUniqCode) && (!errors.As(err, &duplicate) || duplicate.GoodId != tt.wantGood) {
+				t.Errorf("GoodAdd() error = %v, want duplicate of good %d", err, tt.wantGood)
+			}
+			if got != tt.want {
+				t.Errorf("GoodAdd() got = %v, want %v", got, tt.want)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("GoodAdd() unmet expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestDatabase_ReceiveGood_postgres(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id from goods where uniq_code = $1 AND deleted_at IS NULL FOR SHARE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery("SELECT available FROM storages WHERE id = $1 FOR SHARE").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(true))
+	mock.ExpectExec("INSERT INTO remains (good_id, storage_id, count, reserved) VALUES ($1, $2, $3, 0) "+
+		"ON CONFLICT (good_id, storage_id) DO UPDATE SET count = remains.count + $4").
+		WithArgs(4, 3, 10, 10).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO stock_movements (good_id, uniq_code, storage_id, type, count_delta, reserved_delta, correlation_id, created_at) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8)").
+		WithArgs(4, 1, 3, movements.TypeReceive, 10, 0, "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d := New(db, WithDialect(Postgres()))
+	if err := d.ReceiveGood(context.TODO(), 1, 3, 10); err != nil {
+		t.Errorf("ReceiveGood() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("ReceiveGood() unmet expectations: %s", err)
+	}
+}
+
+func TestDatabase_ReserveGood_postgres(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	serialization := &pgconn.PgError{Code: errSerializationFailure, Message: "could not serialize access due to concurrent update"}
+	stockStr := "SELECT remains.id, remains.storage_id, remains.count - remains.reserved AS avail, storages.priority from remains " +
+		"JOIN storages ON storages.id = remains.storage_id where good_id = $1 AND storages.available = TRUE ORDER BY remains.id FOR UPDATE OF remains"
+	goodStr := "SELECT id from goods where uniq_code = $1 AND deleted_at IS NULL FOR SHARE"
+	mock.ExpectBegin()
+	mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(stockStr).WithArgs(1).WillReturnError(serialization)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(stockStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "storage_id", "avail", "priority"}).AddRow(2, 3, 5, 0))
+	mock.ExpectExec("UPDATE remains SET reserved = reserved + $1 WHERE id = $2").WithArgs(5, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO stock_movements (good_id, uniq_code, storage_id, type, count_delta, reserved_delta, correlation_id, created_at) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8)").
+		WithArgs(1, 1, 3, movements.TypeReserve, 0, 5, "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO reservations (uniq_code, status, created_at, expires_at) VALUES ($1, $2, $3, $4) RETURNING id").
+		WithArgs(1, reservations.StatusActive, sqlmock.AnyArg(), nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO reservation_lines (reservation_id, remains_id, count) VALUES ($1, $2, $3)").
+		WithArgs(9, 2, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d := New(db, WithDialect(Postgres()), WithRetryPolicy(RetryPolicy{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	got, err := d.ReserveGood(context.TODO(), reservations.Request{UniqCode: 1, Count: 5})
+	if err != nil {
+		t.Fatalf("ReserveGood() error = %v", err)
+	}
+	if got.ID != 9 || len(got.Lines) != 1 || got.Lines[0] != (reservations.Line{StorageId: 3, Count: 5}) {
+		t.Errorf("ReserveGood() got = %+v, want reservation 9 of 5 goods on storage 3", got)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("ReserveGood() unmet expectations: %s", err)
+	}
+}
+
+func TestDatabase_ReserveGoods_postgres(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id from goods where uniq_code = $1 AND deleted_at IS NULL FOR SHARE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT remains.id, remains.storage_id, remains.count - remains.reserved AS avail, storages.priority from remains " +
+		"JOIN storages ON storages.id = remains.storage_id where good_id = $1 AND storages.available = TRUE ORDER BY remains.id FOR UPDATE OF remains").
+		WithArgs(1).WillReturnError(&pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"})
+	mock.ExpectRollback()
+
+	d := New(db, WithDialect(Postgres()))
+	_, err := d.ReserveGoods(context.TODO(), []reservations.Request{{UniqCode: 1, Count: 5}, {UniqCode: 2, Count: 3}})
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "57014" {
+		t.Errorf("ReserveGoods() error = %v, want the statement timeout", err)
+	}
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		t.Errorf("ReserveGoods() error = %v, the lines after the failed statement must not run", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("ReserveGoods() unmet expectations: %s", err)
+	}
+}
+
+func TestPostgres_retryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: errSerializationFailure}, want: true},
+		{name: "deadlock", err: &pgconn.PgError{Code: errDeadlockDetected}, want: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: errUniqueViolation}},
+		{name: "no rows", err: sql.ErrNoRows},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Postgres().retryable(tt.err); got != tt.want {
+				t.Errorf("retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
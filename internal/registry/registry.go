@@ -2,84 +2,259 @@ package registry
 
 import (
 	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/movements"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
 	"LamodaTest/internal/entity/storages"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
+	"io"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Db interface {
-	Storages(ctx context.Context, all bool) ([]storages.Storage, error)
-	StoragesAdd(ctx context.Context, name string, available bool) (int64, error)
-	StoragesDelete(ctx context.Context, id int) (int64, error)
+	Storages(ctx context.Context, filter storages.Filter, page pages.Query) ([]storages.Storage, string, error)
+	StoragesAdd(ctx context.Context, storage storages.Storage) (int64, error)
+	StoragesDelete(ctx context.Context, id int, migrateTo int) (int64, error)
 	StoragesChangeAccess(ctx context.Context, id int, available bool) (int64, error)
-	Goods(ctx context.Context) ([]goods.Good, error)
-	AvailableGoods(ctx context.Context) (map[int]goods.RemainsDTO, error)
-	ReserveGood(ctx context.Context, uniqId int, count int) (map[int]int, error)
-	ReleaseGood(ctx context.Context, uniqId int, count int) error
+	StoragesUpdate(ctx context.Context, id int, update storages.Update) (storages.Storage, error)
+	Goods(ctx context.Context, filter goods.Filter, page pages.Query) ([]goods.Good, string, error)
+	AvailableGoods(ctx context.Context, filter goods.Filter, page pages.Query) (map[int]goods.RemainsDTO, string, error)
+	GoodStock(ctx context.Context, uniqCode int) (goods.Stock, error)
+	ReserveGood(ctx context.Context, req reservations.Request) (reservations.Reservation, error)
+	ReserveGoods(ctx context.Context, reqs []reservations.Request) ([]reservations.Reservation, error)
+	ReleaseGood(ctx context.Context, reservationId int64) (reservations.Reservation, error)
+	ExpireReservations(ctx context.Context, now time.Time) (int, error)
+	ShipGood(ctx context.Context, reservationId int64, count int) (reservations.Shipment, error)
+	ReceiveGood(ctx context.Context, uniqCode int, storageId int, count int) error
+	TransferStock(ctx context.Context, uniqCode int, fromStorageId int, toStorageId int, count int) error
+	Movements(ctx context.Context, uniqCode int, afterId int64, limit int) ([]movements.Movement, error)
 	GoodAdd(ctx context.Context, name string, size string, uniqCode int) (int64, error)
+	GoodUpdate(ctx context.Context, uniqCode int, update goods.Update) (goods.Good, error)
 	GoodDelete(ctx context.Context, uniqCode int) (int64, error)
+	GoodRestore(ctx context.Context, uniqCode int) (goods.Good, error)
 }
 
 type Database struct {
-	conn *sql.DB
+	conn      *sql.DB
+	allocator Allocator
+	retry     RetryPolicy
+	log       logrus.FieldLogger
+	dialect   Dialect
 }
 
-func New(connect *sql.DB) *Database {
-	return &Database{conn: connect}
+type Option func(*Database)
+
+// WithAllocator sets the strategy choosing storages for reservations, FewestStorages is used by default.
+// Storages pinned in a request always take precedence over the strategy.
+func WithAllocator(allocator Allocator) Option {
+	return func(d *Database) {
+		d.allocator = allocator
+	}
 }
 
-func (d *Database) Storages(ctx context.Context, all bool) ([]storages.Storage, error) {
-	query := "select * from storages"
-	if !all {
-		query = fmt.Sprintf("%s where available = 1", query)
+// WithRetryPolicy sets how transactions failed by a deadlock or a lock wait timeout are retried,
+// DefaultRetryPolicy is used by default.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(d *Database) {
+		d.retry = policy
 	}
-	cmd, err := d.conn.Prepare(query)
-	if err != nil {
-		return nil, fmt.Errorf("can't prepare sql: %w", err)
+}
+
+// WithLogger sets the logger for transaction retries, nothing is logged by default.
+func WithLogger(log logrus.FieldLogger) Option {
+	return func(d *Database) {
+		d.log = log
 	}
-	rows, err := cmd.QueryContext(ctx)
+}
+
+func New(connect *sql.DB, opts ...Option) *Database {
+	discard := logrus.New()
+	discard.SetOutput(io.Discard)
+	d := &Database{conn: connect, retry: DefaultRetryPolicy, log: discard}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+const storageColumns = "id, name, available, address, region, priority, capacity, tags"
+
+var storageSort = map[string]sortField[storages.Storage]{
+	"id":       {column: "id", numeric: true, value: func(s storages.Storage) any { return s.ID }},
+	"name":     {column: "name", value: func(s storages.Storage) any { return s.Name }},
+	"priority": {column: "priority", numeric: true, value: func(s storages.Storage) any { return s.Priority }},
+}
+
+// Storages returns a page of storages matching the filter and the cursor of the next page.
+func (d *Database) Storages(ctx context.Context, filter storages.Filter, page pages.Query) ([]storages.Storage, string, error) {
+	keys, err := newKeyset(page, storageSort, "id", func(s storages.Storage) int64 { return int64(s.ID) })
 	if err != nil {
-		return nil, fmt.Errorf("can't scan from storage list: %w", err)
+		return nil, "", err
+	}
+	var conditions []string
+	var args []any
+	if filter.Available != nil {
+		conditions = append(conditions, "available = ?")
+		args = append(args, *filter.Available)
+	}
+	if filter.Name != "" {
+		conditions = append(conditions, "name LIKE ?")
+		args = append(args, likePattern(filter.Name))
+	}
+	after, afterArgs := keys.condition()
+	query := fmt.Sprintf("select %s from storages%s order by %s limit ?",
+		storageColumns, where(append(conditions, after)...), keys.orderBy())
+	args = append(append(args, afterArgs...), keys.limit+1)
+	rows, err := d.db().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't scan from storage list: %w", err)
 	}
 	defer rows.Close()
-	var result []storages.Storage
+	result := []storages.Storage{}
 	for rows.Next() {
-		values := storages.Storage{}
-		err = rows.Scan(&values.ID, &values.Name, &values.RawAvailable)
-		if err != nil {
-			return nil, fmt.Errorf("can't scan from storage list: %s", err.Error())
-		}
-		values.Available, err = strconv.ParseBool(values.RawAvailable)
+		values, err := scanStorage(rows)
 		if err != nil {
-			return nil, fmt.Errorf("can't parse bool from %s str: %w", values.RawAvailable, err)
+			return nil, "", fmt.Errorf("can't scan from storage list: %w", err)
 		}
 		result = append(result, values)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error when try get all storages: %v", err)
+		return nil, "", fmt.Errorf("error when try get all storages: %v", err)
 	}
-	return result, nil
+	result, next := keys.page(result)
+	return result, next, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
-func (d *Database) StoragesAdd(ctx context.Context, name string, available bool) (int64, error) {
-	result, err := d.conn.ExecContext(ctx, "insert into storages (name, available) values (?, ?)",
-		name, available)
+// scanStorage reads a storage selected with storageColumns.
+func scanStorage(row rowScanner) (storages.Storage, error) {
+	values := storages.Storage{}
+	var tags []byte
+	err := row.Scan(&values.ID, &values.Name, &values.RawAvailable, &values.Address, &values.Region,
+		&values.Priority, &values.Capacity, &tags)
 	if err != nil {
-		return -1, fmt.Errorf("can't add storage [%s, %t]: %w", name, available, err)
+		return values, err
 	}
-	id, err := result.LastInsertId()
+	values.Available, err = strconv.ParseBool(values.RawAvailable)
 	if err != nil {
-		return -1, fmt.Errorf("can't get last added storage id from database: %w", err)
+		return values, fmt.Errorf("can't parse bool from %s str: %w", values.RawAvailable, err)
+	}
+	if len(tags) > 0 {
+		if err = json.Unmarshal(tags, &values.Tags); err != nil {
+			return values, fmt.Errorf("can't parse tags of storage %d: %w", values.ID, err)
+		}
+	}
+	return values, nil
+}
+
+// storageTags converts tags to the json column value, no tags are stored as NULL.
+func storageTags(tags map[string]string) (any, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal storage tags: %w", err)
+	}
+	return string(raw), nil
+}
+
+func (d *Database) StoragesAdd(ctx context.Context, storage storages.Storage) (int64, error) {
+	tags, err := storageTags(storage.Tags)
+	if err != nil {
+		return -1, err
+	}
+	id, err := d.db().insert(ctx, `insert into storages 
+		(name, available, address, region, priority, capacity, tags) values (?, ?, ?, ?, ?, ?, ?)`,
+		storage.Name, storage.Available, storage.Address, storage.Region, storage.Priority, storage.Capacity, tags)
+	if err != nil {
+		return -1, fmt.Errorf("can't add storage [%s, %t]: %w", storage.Name, storage.Available, err)
 	}
 	return id, nil
 }
 
-func (d *Database) StoragesDelete(ctx context.Context, id int) (int64, error) {
-	result, err := d.conn.ExecContext(ctx, "delete from storages where id = ?", id)
+// StoragesDelete deletes the storage. A storage still holding goods is deleted only when migrateTo is set,
+// then its stock and reservations are moved to the migrateTo storage in the same transaction.
+// Otherwise a *StorageNotEmptyError with the blocking remains is returned, empty remains still referenced
+// by reservation or shipment history block the delete as well.
+func (d *Database) StoragesDelete(ctx context.Context, id int, migrateTo int) (int64, error) {
+	if migrateTo == id {
+		return -1, fmt.Errorf("can't migrate goods of storage %d to itself: %w", id, ErrInvalidArgument)
+	}
+	var affected int64
+	err := d.inTx(ctx, "storage delete", func(tx runner) error {
+		var err error
+		affected, err = deleteStorage(ctx, tx, id, migrateTo)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return affected, nil
+}
+
+func deleteStorage(ctx context.Context, tx runner, id int, migrateTo int) (int64, error) {
+	notes, err := remainsBy(ctx, tx, "remains.storage_id", id)
+	if err != nil {
+		return -1, err
+	}
+	if migrateTo != 0 {
+		available, err := storageAvailable(ctx, tx, migrateTo)
+		if err != nil {
+			return -1, err
+		}
+		if !available {
+			return -1, fmt.Errorf("can't migrate goods of storage %d to storage %d: %w", id, migrateTo, ErrStorageUnavailable)
+		}
+		for _, note := range notes {
+			if err = migrateRemains(ctx, tx, note, migrateTo); err != nil {
+				return -1, err
+			}
+		}
+	} else {
+		blocking := &StorageNotEmptyError{StorageId: id}
+		empty := false
+		for _, note := range notes {
+			if note.count != 0 || note.reserved != 0 {
+				blocking.Remains = append(blocking.Remains, storages.Remains{
+					UniqCode: note.uniqCode,
+					Count:    note.count,
+					Reserved: note.reserved,
+				})
+			} else {
+				empty = true
+			}
+		}
+		if empty {
+			// empty notes still referenced by reservation or shipment lines can't be deleted, migrateTo moves the lines
+			referenced, err := referencedRemains(ctx, tx, id)
+			if err != nil {
+				return -1, err
+			}
+			for _, note := range notes {
+				if note.count == 0 && note.reserved == 0 && referenced[note.id] {
+					blocking.Remains = append(blocking.Remains, storages.Remains{UniqCode: note.uniqCode, History: true})
+				}
+			}
+		}
+		if len(blocking.Remains) > 0 {
+			return -1, blocking
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM remains WHERE storage_id = ?", id); err != nil {
+			return -1, fmt.Errorf("can't delete empty remains of storage %d: %w", id, err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, "delete from storages where id = ?", id)
 	if err != nil {
 		return -1, fmt.Errorf("can't delete storage with id %d: %w", id, err)
 	}
@@ -91,7 +266,7 @@ func (d *Database) StoragesDelete(ctx context.Context, id int) (int64, error) {
 }
 
 func (d *Database) StoragesChangeAccess(ctx context.Context, id int, available bool) (int64, error) {
-	result, err := d.conn.ExecContext(ctx, "update storages set available = ? where id = ?", available, id)
+	result, err := d.db().ExecContext(ctx, "update storages set available = ? where id = ?", available, id)
 	if err != nil {
 		return -1, fmt.Errorf("can't change storage with id %d: %w", id, err)
 	}
@@ -102,230 +277,703 @@ func (d *Database) StoragesChangeAccess(ctx context.Context, id int, available b
 	return affected, nil
 }
 
-func (d *Database) AvailableGoods(ctx context.Context) (map[int]goods.RemainsDTO, error) {
-	cmd, err := d.conn.Prepare(`select 
-			goods.name, 
-			goods.size, 
-			goods.uniq_code, 
-			storages.id AS storage_id, 
-			remains.count - remains.reserved AS avail 
-		FROM 
-		    goods 
-		JOIN remains ON goods.id = remains.good_id 
-		JOIN storages ON remains.storage_id = storages.id 
-		WHERE remains.count > reserved AND available = 1`)
+// StoragesUpdate changes only the fields set in the update and returns the storage as it is after the change.
+func (d *Database) StoragesUpdate(ctx context.Context, id int, update storages.Update) (storages.Storage, error) {
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		sets = append(sets, column+" = ?")
+		args = append(args, value)
+	}
+	if update.Name != nil {
+		set("name", *update.Name)
+	}
+	if update.Available != nil {
+		set("available", *update.Available)
+	}
+	if update.Address != nil {
+		set("address", *update.Address)
+	}
+	if update.Region != nil {
+		set("region", *update.Region)
+	}
+	if update.Priority != nil {
+		set("priority", *update.Priority)
+	}
+	if update.Capacity != nil {
+		set("capacity", *update.Capacity)
+	}
+	if update.Tags != nil {
+		tags, err := storageTags(update.Tags)
+		if err != nil {
+			return storages.Storage{}, err
+		}
+		set("tags", tags)
+	}
+	if len(sets) == 0 {
+		return storages.Storage{}, fmt.Errorf("nothing to update in storage %d: %w", id, ErrInvalidArgument)
+	}
+	var storage storages.Storage
+	err := d.inTx(ctx, "storage update", func(tx runner) error {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("update storages set %s where id = ?", strings.Join(sets, ", ")),
+			append(args, id)...)
+		if err != nil {
+			return fmt.Errorf("can't update storage with id %d: %w", id, err)
+		}
+		storage, err = scanStorage(tx.QueryRowContext(ctx,
+			fmt.Sprintf("select %s from storages where id = ?", storageColumns), id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("can't update storage with id %d: %w", id, ErrStorageNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("can't get updated storage with id %d: %w", id, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("can't prepare sql: %w", err)
+		return storages.Storage{}, err
 	}
-	rows, err := cmd.QueryContext(ctx)
+	return storage, nil
+}
+
+var goodSort = map[string]sortField[goods.Good]{
+	"id":        {column: "goods.id", numeric: true, value: func(g goods.Good) any { return g.Id }},
+	"name":      {column: "goods.name", value: func(g goods.Good) any { return g.Name }},
+	"size":      {column: "goods.size", value: func(g goods.Good) any { return g.Size }},
+	"uniq_code": {column: "goods.uniq_code", numeric: true, value: func(g goods.Good) any { return g.UniqCode }},
+}
+
+const goodColumns = "goods.id, goods.name, goods.size, goods.uniq_code, goods.deleted_at"
+
+// scanGood reads a good selected with goodColumns, extra receives the columns selected after them.
+func scanGood(row rowScanner, extra ...any) (goods.Good, error) {
+	good := goods.Good{}
+	var deletedAt sql.NullTime
+	err := row.Scan(append([]any{&good.Id, &good.Name, &good.Size, &good.UniqCode, &deletedAt}, extra...)...)
 	if err != nil {
-		return nil, fmt.Errorf("can't query avail goods: %s", err.Error())
+		return good, err
+	}
+	if deletedAt.Valid {
+		good.DeletedAt = &deletedAt.Time
+	}
+	return good, nil
+}
+
+func goodKeyset(page pages.Query) (keyset[goods.Good], error) {
+	return newKeyset(page, goodSort, "goods.id", func(g goods.Good) int64 { return int64(g.Id) })
+}
+
+// goodConditions turns the name and size of the filter into WHERE conditions on the goods table,
+// soft deleted goods are skipped unless the filter includes them.
+func goodConditions(filter goods.Filter) ([]string, []any) {
+	var conditions []string
+	var args []any
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "goods.deleted_at IS NULL")
+	}
+	if filter.Name != "" {
+		conditions = append(conditions, "goods.name LIKE ?")
+		args = append(args, likePattern(filter.Name))
+	}
+	if filter.Size != "" {
+		conditions = append(conditions, "goods.size = ?")
+		args = append(args, filter.Size)
+	}
+	return conditions, args
+}
+
+// AvailableGoods returns free goods on available storages keyed by uniq_code and the cursor of the next page.
+// A page holds whole goods, the limit is the number of goods rather than storages.
+func (d *Database) AvailableGoods(ctx context.Context, filter goods.Filter, page pages.Query) (map[int]goods.RemainsDTO, string, error) {
+	keys, err := goodKeyset(page)
+	if err != nil {
+		return nil, "", err
+	}
+	conditions, args := goodConditions(filter)
+	conditions = append(conditions, "remains.count > remains.reserved", "storages.available = TRUE")
+	if filter.StorageId != 0 {
+		conditions = append(conditions, "remains.storage_id = ?")
+		args = append(args, filter.StorageId)
+	}
+	after, afterArgs := keys.condition()
+	having := ""
+	pageArgs := append(append([]any{}, args...), afterArgs...)
+	if filter.MinAvailable > 0 {
+		having = " HAVING SUM(remains.count - remains.reserved) >= ?"
+		pageArgs = append(pageArgs, filter.MinAvailable)
+	}
+	pageArgs = append(pageArgs, keys.limit+1)
+	const join = ` JOIN remains ON goods.id = remains.good_id
+		JOIN storages ON remains.storage_id = storages.id`
+	query := fmt.Sprintf(`SELECT
+			goods.id,
+			goods.name,
+			goods.size,
+			goods.uniq_code,
+			remains.storage_id,
+			remains.count - remains.reserved AS avail
+		FROM (
+			SELECT goods.id FROM goods%s%s
+			GROUP BY goods.id%s
+			ORDER BY %s
+			LIMIT ?
+		) AS page
+		JOIN goods ON goods.id = page.id%s%s
+		ORDER BY %s, remains.storage_id`,
+		join, where(append(conditions, after)...), having, keys.orderBy(), join, where(conditions...), keys.orderBy())
+	rows, err := d.db().QueryContext(ctx, query, append(pageArgs, args...)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't query avail goods: %s", err.Error())
 	}
-	result := map[int]goods.RemainsDTO{}
-	ctx.Done()
 	defer rows.Close()
+	result := map[int]goods.RemainsDTO{}
+	var list []goods.Good
 	for rows.Next() {
-		var tmp struct {
-			Name    string
-			Size    string
-			UniqId  int
-			Storage int
-			Avail   int
-		}
-		err = rows.Scan(&tmp.Name, &tmp.Size, &tmp.UniqId, &tmp.Storage, &tmp.Avail)
+		var good goods.Good
+		var storage, avail int
+		err = rows.Scan(&good.Id, &good.Name, &good.Size, &good.UniqCode, &storage, &avail)
 		if err != nil {
-			return nil, fmt.Errorf("can't scan from rows: %s", err.Error())
+			return nil, "", fmt.Errorf("can't scan from rows: %s", err.Error())
 		}
-
-		if note, ok := result[tmp.UniqId]; !ok {
-			result[tmp.UniqId] = goods.RemainsDTO{
-				Name: tmp.Name,
-				Size: tmp.Size,
-				StorageAvailable: map[int]int{
-					tmp.Storage: tmp.Avail,
-				},
+		note, ok := result[good.UniqCode]
+		if !ok {
+			note = goods.RemainsDTO{
+				Name:             good.Name,
+				Size:             good.Size,
+				StorageAvailable: map[int]int{},
 			}
-		} else {
-			note.StorageAvailable[tmp.Storage] = tmp.Avail
+			result[good.UniqCode] = note
+			list = append(list, good)
 		}
+		note.StorageAvailable[storage] = avail
 	}
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error when try get available goods: %v", err)
+		return nil, "", fmt.Errorf("error when try get available goods: %v", err)
 	}
-	return result, nil
+	kept, next := keys.page(list)
+	for _, good := range list[len(kept):] {
+		delete(result, good.UniqCode)
+	}
+	return result, next, nil
 }
 
-func (d *Database) ReserveGood(ctx context.Context, uniqId int, count int) (map[int]int, error) {
-	tx, err := d.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}) //
+func (d *Database) ReserveGood(ctx context.Context, req reservations.Request) (reservations.Reservation, error) {
+	var reservation reservations.Reservation
+	err := d.inTx(ctx, "reserve", func(tx runner) error {
+		var err error
+		reservation, err = d.reserve(ctx, tx, req)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("can't init transaction: %w", err)
+		return reservations.Reservation{}, err
 	}
-	defer tx.Rollback()
-	var id int
-	if err = tx.QueryRowContext(ctx, "SELECT id from goods where uniq_code = ?",
-		uniqId).Scan(&id); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("not found goods")
+	return reservation, nil
+}
+
+// ReserveGoods reserves all requested lines in a single transaction: either every line is reserved or nothing is.
+// When some lines can't be reserved the returned error is *BatchError describing each line,
+// a database error stops the batch and is returned as is.
+func (d *Database) ReserveGoods(ctx context.Context, reqs []reservations.Request) ([]reservations.Reservation, error) {
+	var result []reservations.Reservation
+	err := d.inTx(ctx, "reserve batch", func(tx runner) error {
+		result = make([]reservations.Reservation, 0, len(reqs))
+		batchErr := &BatchError{Errors: make([]error, len(reqs))}
+		failed := false
+		for i, req := range reqs {
+			reservation, err := d.reserve(ctx, tx, req)
+			if err != nil && !lineError(err) {
+				// the transaction is rolled back or aborted by the failed statement, the rest of the lines can't run in it
+				return err
+			}
+			if err != nil {
+				batchErr.Errors[i] = err
+				failed = true
+				continue
+			}
+			result = append(result, reservation)
+		}
+		if failed {
+			return batchErr
 		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	reserved := map[int]int{}
-	rows, err := d.conn.QueryContext(ctx, `SELECT 
+	return result, nil
+}
+
+// lineError reports whether the reserve of a line failed by itself, leaving the transaction usable for the next lines.
+func lineError(err error) bool {
+	return errors.Is(err, ErrGoodNotFound) || errors.Is(err, ErrInsufficientStock)
+}
+
+func (d *Database) reserve(ctx context.Context, tx runner, req reservations.Request) (reservations.Reservation, error) {
+	reservation := reservations.Reservation{}
+	id, err := goodIdByUniqCode(ctx, tx, req.UniqCode)
+	if err != nil {
+		return reservation, err
+	}
+	stock, err := availableStock(ctx, tx, id)
+	if err != nil {
+		return reservation, err
+	}
+	stock = d.allocate(req, stock)
+	total := 0
+	for _, tmp := range stock {
+		total += tmp.Available
+	}
+	if req.Count <= 0 || total < req.Count {
+		return reservation, fmt.Errorf("can't reserve %d good: %w", req.UniqCode, ErrInsufficientStock)
+	}
+	count := req.Count
+	var reserved []reservedLine
+	for _, tmp := range stock {
+		if count == 0 {
+			break
+		}
+		take := min(tmp.Available, count)
+		_, err = tx.ExecContext(ctx, "UPDATE remains SET reserved = reserved + ? WHERE id = ?",
+			take, tmp.RemainsId)
+		if err != nil {
+			return reservation, fmt.Errorf("can't reserve good by %d id: %w", tmp.RemainsId, err)
+		}
+		err = recordMovement(ctx, tx, id, movements.Movement{
+			UniqCode:      req.UniqCode,
+			StorageId:     tmp.StorageId,
+			Type:          movements.TypeReserve,
+			ReservedDelta: take,
+		})
+		if err != nil {
+			return reservation, err
+		}
+		reserved = append(reserved, reservedLine{remainsId: tmp.RemainsId, storageId: tmp.StorageId, count: take})
+		count -= take
+	}
+	reservation.UniqCode = req.UniqCode
+	reservation.Status = reservations.StatusActive
+	reservation.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	if req.TTL > 0 {
+		expiresAt := reservation.CreatedAt.Add(req.TTL)
+		reservation.ExpiresAt = &expiresAt
+	}
+	reservation.ID, err = tx.insert(ctx, "INSERT INTO reservations (uniq_code, status, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		reservation.UniqCode, reservation.Status, reservation.CreatedAt, reservation.ExpiresAt)
+	if err != nil {
+		return reservation, fmt.Errorf("can't create reservation for %d good: %w", req.UniqCode, err)
+	}
+	for _, line := range reserved {
+		_, err = tx.ExecContext(ctx, "INSERT INTO reservation_lines (reservation_id, remains_id, count) VALUES (?, ?, ?)",
+			reservation.ID, line.remainsId, line.count)
+		if err != nil {
+			return reservation, fmt.Errorf("can't add line to reservation %d: %w", reservation.ID, err)
+		}
+		reservation.Lines = append(reservation.Lines, reservations.Line{StorageId: line.storageId, Count: line.count})
+	}
+	return reservation, nil
+}
+
+// allocate orders the stock by the configured strategy, storages pinned in the request take precedence.
+func (d *Database) allocate(req reservations.Request, stock []Stock) []Stock {
+	allocator := d.allocator
+	if allocator == nil {
+		allocator = FewestStorages()
+	}
+	return Pinned(allocator).Allocate(req, stock)
+}
+
+// availableStock reads free quantity of the good on available storages inside the transaction,
+// so lines reserved earlier in the same transaction are already taken into account.
+// The remains rows stay locked until the transaction ends, so concurrent reserves of the good
+// wait for each other instead of selling the same quantity twice. Rows are locked in id order
+// to keep reserves of several goods from deadlocking.
+func availableStock(ctx context.Context, tx runner, goodId int) ([]Stock, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT 
 			remains.id, 
 			remains.storage_id, 
-			remains.count - remains.reserved AS avail 
+			remains.count - remains.reserved AS avail, 
+			storages.priority 
 		from remains 
 		JOIN storages ON storages.id = remains.storage_id 
-		where good_id = ? AND storages.available = 1`, id)
+		where good_id = ? AND storages.available = TRUE 
+		ORDER BY remains.id 
+		FOR UPDATE OF remains`, goodId)
 	if err != nil {
-		return nil, fmt.Errorf("can't request avail goods for release: %w", err)
+		return nil, fmt.Errorf("can't request avail goods for reserve: %w", err)
 	}
+	defer rows.Close()
+	var result []Stock
 	for rows.Next() {
-		var tmp struct {
-			Id        int
-			storageId int
-			Avail     int
+		tmp := Stock{}
+		if err = rows.Scan(&tmp.RemainsId, &tmp.StorageId, &tmp.Available, &tmp.Priority); err != nil {
+			return nil, fmt.Errorf("can't get remains by %d good: %w", goodId, err)
 		}
-		err = rows.Scan(&tmp.Id, &tmp.storageId, &tmp.Avail)
-		if err != nil {
-			return nil, fmt.Errorf("can't get remains by %d good: %w", id, err)
-		}
-		if tmp.Avail > 0 {
-			if tmp.Avail < count {
-				_, err = tx.ExecContext(ctx, "UPDATE remains SET reserved = reserved + ? WHERE id = ?",
-					tmp.Avail, tmp.Id)
-				if err != nil {
-					return nil, fmt.Errorf("can't reserve good by %d id: %w", tmp.Id, err)
-				}
-				count = count - tmp.Avail
-				reserved[tmp.storageId] = tmp.Avail
-			} else {
-				_, err = tx.ExecContext(ctx, "UPDATE remains SET reserved = reserved + ? WHERE id = ?",
-					count, tmp.Id)
-				if err != nil {
-					return nil, fmt.Errorf("can't reserve good by %d id: %w", tmp.Id, err)
-				}
-				reserved[tmp.storageId] = count
-				count = 0
-			}
+		if tmp.Available > 0 {
+			result = append(result, tmp)
 		}
 	}
-	defer rows.Close()
-	if len(reserved) == 0 || count != 0 {
-		return nil, fmt.Errorf("can't reserve %d good: not enough goods on available storages", uniqId)
-	}
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("can't commit reserve transaction: %w", err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when try get remains by %d good: %w", goodId, err)
 	}
-	return reserved, nil
+	return result, nil
+}
+
+func (d *Database) ReleaseGood(ctx context.Context, reservationId int64) (reservations.Reservation, error) {
+	return d.release(ctx, reservationId, reservations.StatusReleased)
 }
 
-func (d *Database) ReleaseGood(ctx context.Context, uniqId int, count int) error {
-	tx, err := d.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}) //
+// ExpireReservations releases every active reservation whose expiry time is not after now.
+// Each reservation is released in its own transaction, so one broken reservation doesn't block the others.
+func (d *Database) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	rows, err := d.db().QueryContext(ctx, "SELECT id FROM reservations WHERE status = ? AND expires_at <= ?",
+		reservations.StatusActive, now)
 	if err != nil {
-		return fmt.Errorf("can't init transaction: %w", err)
+		return 0, fmt.Errorf("can't request expired reservations: %w", err)
 	}
-	defer tx.Rollback()
-	var id int
-	if err = tx.QueryRowContext(ctx, "SELECT id from goods where uniq_code = ?",
-		uniqId).Scan(&id); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("can't found good with uniq_code %d", uniqId)
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("can't scan expired reservation: %w", err)
 		}
-		return err
+		ids = append(ids, id)
 	}
-	rows, err := d.conn.QueryContext(ctx, `SELECT 
-			remains.id, 
-			remains.storage_id, 
-			remains.reserved
-		from remains 
-		JOIN storages ON storages.id = remains.storage_id 
-		where good_id = ? AND storages.available = 1`, id)
-	if err != nil {
-		return fmt.Errorf("can't request avail goods for release: %w", err)
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("error when try get expired reservations: %w", err)
 	}
-	for rows.Next() {
-		var tmp struct {
-			Id        int
-			storageId int
-			Reserved  int
+	expired := 0
+	for _, id := range ids {
+		_, err = d.release(ctx, id, reservations.StatusExpired)
+		if errors.Is(err, ErrReservationClosed) {
+			continue
 		}
-		err = rows.Scan(&tmp.Id, &tmp.storageId, &tmp.Reserved)
 		if err != nil {
-			return fmt.Errorf("can't get release good with id %d: %w", id, err)
+			return expired, fmt.Errorf("can't expire reservation %d: %w", id, err)
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// release returns reserved quantity of every line back to remains and closes the reservation with the given status.
+func (d *Database) release(ctx context.Context, reservationId int64, status string) (reservations.Reservation, error) {
+	movementType := movements.TypeRelease
+	if status == reservations.StatusExpired {
+		movementType = movements.TypeExpire
+	}
+	reservation := reservations.Reservation{ID: reservationId}
+	err := d.inTx(ctx, movementType, func(tx runner) error {
+		reservation = reservations.Reservation{ID: reservationId}
+		if err := loadReservation(ctx, tx, &reservation); err != nil {
+			return err
+		}
+		if reservation.Status != reservations.StatusActive {
+			return fmt.Errorf("can't close reservation %d as %s, it is %s: %w",
+				reservationId, status, reservation.Status, ErrReservationClosed)
 		}
-		if tmp.Reserved < count {
+		lines, err := reservationLines(ctx, tx, reservationId)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
 			_, err = tx.ExecContext(ctx, "UPDATE remains SET reserved = reserved - ? WHERE id = ?",
-				tmp.Reserved, tmp.Id)
+				line.count, line.remainsId)
 			if err != nil {
-				return fmt.Errorf("can't update remains note with id %d: %w", tmp.Id, err)
+				return fmt.Errorf("can't update remains note with id %d: %w", line.remainsId, err)
 			}
-			count = count - tmp.Reserved
-		} else {
-			_, err = tx.ExecContext(ctx, "UPDATE remains SET reserved = reserved - ? WHERE id = ?",
-				count, tmp.Id)
+			err = recordMovement(ctx, tx, line.goodId, movements.Movement{
+				UniqCode:      reservation.UniqCode,
+				StorageId:     line.storageId,
+				Type:          movementType,
+				ReservedDelta: -line.count,
+			})
 			if err != nil {
-				return fmt.Errorf("can't update remains note with id %d: %w", tmp.Id, err)
+				return err
 			}
-			count = 0
+			reservation.Lines = append(reservation.Lines, reservations.Line{StorageId: line.storageId, Count: line.count})
 		}
+		_, err = tx.ExecContext(ctx, "UPDATE reservations SET status = ? WHERE id = ?",
+			status, reservationId)
+		if err != nil {
+			return fmt.Errorf("can't change status of reservation %d: %w", reservationId, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return reservation, err
 	}
-	defer rows.Close()
-	if count != 0 {
-		return fmt.Errorf("can't release good with id %d: not enough reserved goods on available storages", uniqId)
+	reservation.Status = status
+	return reservation, nil
+}
+
+// loadReservation fills reservation header (without lines) by its ID.
+// The reservation stays locked until the transaction ends, so it can't be released or shipped twice concurrently.
+func loadReservation(ctx context.Context, tx runner, reservation *reservations.Reservation) error {
+	var expiresAt sql.NullTime
+	if err := tx.QueryRowContext(ctx, "SELECT uniq_code, status, created_at, expires_at FROM reservations WHERE id = ? FOR UPDATE",
+		reservation.ID).Scan(&reservation.UniqCode, &reservation.Status, &reservation.CreatedAt, &expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("can't find reservation with id %d: %w", reservation.ID, ErrReservationNotFound)
+		}
+		return err
 	}
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("can't commit release transaction: %w", err)
+	if expiresAt.Valid {
+		reservation.ExpiresAt = &expiresAt.Time
 	}
 	return nil
 }
 
-func (d *Database) Goods(ctx context.Context) ([]goods.Good, error) {
-	cmd, err := d.conn.Prepare("select * from goods;")
+type reservedLine struct {
+	id        int
+	remainsId int
+	goodId    int
+	storageId int
+	count     int
+}
+
+// reservationLines reads and locks all not yet shipped lines of the reservation with their remains
+// before any update is issued, because the connection can't execute statements while rows are still open.
+func reservationLines(ctx context.Context, tx runner, reservationId int64) ([]reservedLine, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT 
+			reservation_lines.id, 
+			reservation_lines.remains_id, 
+			remains.good_id, 
+			remains.storage_id, 
+			reservation_lines.count 
+		FROM reservation_lines 
+		JOIN remains ON remains.id = reservation_lines.remains_id 
+		WHERE reservation_lines.reservation_id = ? 
+		ORDER BY remains.id 
+		FOR UPDATE`, reservationId)
 	if err != nil {
-		return nil, fmt.Errorf("can't prepare sql: %w", err)
+		return nil, fmt.Errorf("can't request lines of reservation %d: %w", reservationId, err)
 	}
-	rows, err := cmd.QueryContext(ctx)
+	defer rows.Close()
+	var result []reservedLine
+	for rows.Next() {
+		line := reservedLine{}
+		if err = rows.Scan(&line.id, &line.remainsId, &line.goodId, &line.storageId, &line.count); err != nil {
+			return nil, fmt.Errorf("can't scan line of reservation %d: %w", reservationId, err)
+		}
+		if line.count > 0 {
+			result = append(result, line)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when try get lines of reservation %d: %w", reservationId, err)
+	}
+	return result, nil
+}
+
+// Goods returns a page of the catalog matching the name and size of the filter and the cursor of the next page.
+func (d *Database) Goods(ctx context.Context, filter goods.Filter, page pages.Query) ([]goods.Good, string, error) {
+	keys, err := goodKeyset(page)
 	if err != nil {
-		return nil, fmt.Errorf("can't scan from goods list: %w", err)
+		return nil, "", err
+	}
+	conditions, args := goodConditions(filter)
+	after, afterArgs := keys.condition()
+	query := fmt.Sprintf("select %s from goods%s order by %s limit ?",
+		goodColumns, where(append(conditions, after)...), keys.orderBy())
+	rows, err := d.db().QueryContext(ctx, query, append(append(args, afterArgs...), keys.limit+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't scan from goods list: %w", err)
 	}
 	result := []goods.Good{}
 	defer rows.Close()
 	for rows.Next() {
-		values := goods.Good{}
-		err = rows.Scan(&values.Id, &values.Name, &values.Size, &values.UniqCode)
+		values, err := scanGood(rows)
 		if err != nil {
-			return nil, fmt.Errorf("can't scan from goods list: %w", err)
+			return nil, "", fmt.Errorf("can't scan from goods list: %w", err)
 		}
 		result = append(result, values)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error when try get all goods: %w", err)
+		return nil, "", fmt.Errorf("error when try get all goods: %w", err)
 	}
-	return result, nil
+	result, next := keys.page(result)
+	return result, next, nil
 }
 
+// GoodStock returns the good with its remains on every storage, unavailable storages included.
+func (d *Database) GoodStock(ctx context.Context, uniqCode int) (goods.Stock, error) {
+	rows, err := d.db().QueryContext(ctx, `SELECT
+			goods.id,
+			goods.name,
+			goods.size,
+			goods.uniq_code,
+			goods.deleted_at,
+			remains.storage_id,
+			storages.available,
+			remains.count,
+			remains.reserved
+		FROM goods
+		LEFT JOIN remains ON goods.id = remains.good_id
+		LEFT JOIN storages ON remains.storage_id = storages.id
+		WHERE goods.uniq_code = ?
+		ORDER BY remains.storage_id`, uniqCode)
+	if err != nil {
+		return goods.Stock{}, fmt.Errorf("can't query stock of good %d: %w", uniqCode, err)
+	}
+	defer rows.Close()
+	stock := goods.Stock{Storages: []goods.StorageStock{}}
+	found := false
+	for rows.Next() {
+		var storageId, count, reserved sql.NullInt64
+		var available sql.NullBool
+		stock.Good, err = scanGood(rows, &storageId, &available, &count, &reserved)
+		if err != nil {
+			return goods.Stock{}, fmt.Errorf("can't scan stock of good %d: %w", uniqCode, err)
+		}
+		found = true
+		if !storageId.Valid {
+			continue
+		}
+		line := goods.StorageStock{
+			StorageId:        int(storageId.Int64),
+			StorageAvailable: available.Bool,
+			Count:            int(count.Int64),
+			Reserved:         int(reserved.Int64),
+		}
+		if line.StorageAvailable {
+			line.Available = line.Count - line.Reserved
+		}
+		stock.Count += line.Count
+		stock.Reserved += line.Reserved
+		stock.Available += line.Available
+		stock.Storages = append(stock.Storages, line)
+	}
+	if err = rows.Err(); err != nil {
+		return goods.Stock{}, fmt.Errorf("error when try get stock of good %d: %w", uniqCode, err)
+	}
+	if !found {
+		return goods.Stock{}, fmt.Errorf("can't get stock of good %d: %w", uniqCode, ErrGoodNotFound)
+	}
+	return stock, nil
+}
+
+// GoodAdd returns *DuplicateUniqCodeError with the id of the good already holding uniqCode,
+// deleted goods keep their uniq_code until they are restored.
 func (d *Database) GoodAdd(ctx context.Context, name string, size string, uniqCode int) (int64, error) {
-	result, err := d.conn.ExecContext(ctx, "insert into goods (name, size, uniq_code) values (?, ?, ?)",
+	db := d.db()
+	id, err := db.insert(ctx, "insert into goods (name, size, uniq_code) values (?, ?, ?)",
 		name, size, uniqCode)
+	if db.dialect.duplicate(err) {
+		dupErr := &DuplicateUniqCodeError{UniqCode: uniqCode}
+		err = db.QueryRowContext(ctx, "SELECT id FROM goods WHERE uniq_code = ?", uniqCode).Scan(&dupErr.GoodId)
+		if err != nil {
+			return -1, fmt.Errorf("%w, can't get its id: %w", dupErr, err)
+		}
+		return -1, dupErr
+	}
 	if err != nil {
 		return -1, fmt.Errorf("can't add good [%s, %s, %d]: %w", name, size, uniqCode, err)
 	}
-	id, err := result.LastInsertId()
+	return id, nil
+}
+
+// GoodUpdate changes only the fields set in the update and returns the good as it is after the change.
+// Soft deleted goods are not found, they are restored first.
+func (d *Database) GoodUpdate(ctx context.Context, uniqCode int, update goods.Update) (goods.Good, error) {
+	var sets []string
+	var args []any
+	if update.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *update.Name)
+	}
+	if update.Size != nil {
+		sets = append(sets, "size = ?")
+		args = append(args, *update.Size)
+	}
+	if len(sets) == 0 {
+		return goods.Good{}, fmt.Errorf("nothing to update in good %d: %w", uniqCode, ErrInvalidArgument)
+	}
+	var good goods.Good
+	err := d.inTx(ctx, "good update", func(tx runner) error {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("update goods set %s where uniq_code = ? and deleted_at is null", strings.Join(sets, ", ")),
+			append(args, uniqCode)...)
+		if err != nil {
+			return fmt.Errorf("can't update good with uniq_code %d: %w", uniqCode, err)
+		}
+		good, err = scanGood(tx.QueryRowContext(ctx,
+			fmt.Sprintf("select %s from goods where uniq_code = ? and deleted_at is null", goodColumns), uniqCode))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("can't update good with uniq_code %d: %w", uniqCode, ErrGoodNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("can't get updated good with uniq_code %d: %w", uniqCode, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return -1, fmt.Errorf("can't get last added good id from database: %w", err)
+		return goods.Good{}, err
 	}
-	return id, nil
+	return good, nil
 }
 
+// GoodDelete soft deletes the good: it disappears from the catalog, remains and reservations,
+// while its remains and history are kept. A good with active reservations is not deleted.
 func (d *Database) GoodDelete(ctx context.Context, uniqCode int) (int64, error) {
-	result, err := d.conn.ExecContext(ctx, "delete from goods where uniq_code = ?", uniqCode)
+	var affected int64
+	err := d.inTx(ctx, "good delete", func(tx runner) error {
+		// the good row is locked first, reserves of the good hold a share lock on it until they commit
+		var id int
+		err := tx.QueryRowContext(ctx, "SELECT id FROM goods WHERE uniq_code = ? AND deleted_at IS NULL FOR UPDATE", uniqCode).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			affected = 0
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("can't lock good with uniq_code %d: %w", uniqCode, err)
+		}
+		var active int
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM reservations WHERE uniq_code = ? AND status = ?",
+			uniqCode, reservations.StatusActive).Scan(&active)
+		if err != nil {
+			return fmt.Errorf("can't count reservations of good %d: %w", uniqCode, err)
+		}
+		if active > 0 {
+			return fmt.Errorf("can't delete good %d with %d active reservations: %w", uniqCode, active, ErrGoodReserved)
+		}
+		result, err := tx.ExecContext(ctx, "update goods set deleted_at = ? where uniq_code = ? and deleted_at is null",
+			time.Now().UTC().Truncate(time.Second), uniqCode)
+		if err != nil {
+			return fmt.Errorf("can't delete good with uniq_code %d: %w", uniqCode, err)
+		}
+		affected, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("can't get row affected after delete good: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return -1, fmt.Errorf("can't delete good with uniq_code %d: %w", uniqCode, err)
+		return -1, err
 	}
-	affected, err := result.RowsAffected()
+	return affected, nil
+}
+
+// GoodRestore brings back a soft deleted good, a good that isn't deleted is returned as it is.
+func (d *Database) GoodRestore(ctx context.Context, uniqCode int) (goods.Good, error) {
+	var good goods.Good
+	err := d.inTx(ctx, "good restore", func(tx runner) error {
+		_, err := tx.ExecContext(ctx, "update goods set deleted_at = null where uniq_code = ?", uniqCode)
+		if err != nil {
+			return fmt.Errorf("can't restore good with uniq_code %d: %w", uniqCode, err)
+		}
+		good, err = scanGood(tx.QueryRowContext(ctx,
+			fmt.Sprintf("select %s from goods where uniq_code = ?", goodColumns), uniqCode))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("can't restore good with uniq_code %d: %w", uniqCode, ErrGoodNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("can't get restored good with uniq_code %d: %w", uniqCode, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return -1, fmt.Errorf("can't get row affected after delete good: %w", err)
+		return goods.Good{}, err
 	}
-	return affected, nil
+	return good, nil
 }
@@ -2,1280 +2,2717 @@ package registry
 
 import (
 	"LamodaTest/internal/entity/goods"
+	"LamodaTest/internal/entity/movements"
+	"LamodaTest/internal/entity/pages"
+	"LamodaTest/internal/entity/reservations"
 	"LamodaTest/internal/entity/storages"
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDatabase_AvailableGoods(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx context.Context
-	}
-	columns := []string{"name", "size", "uniq_code", "storage_id", "avail"}
-	sqlStr := "select goods.name, goods.size, goods.uniq_code, storages.id AS storage_id, remains.count - remains.reserved AS avail FROM goods JOIN remains ON goods.id = remains.good_id JOIN storages ON remains.storage_id = storages.id WHERE remains.count > reserved AND available = 1"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    map[int]goods.RemainsDTO
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New()
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("Test,xs,1,1,500\nTest2,l,2,4,200\nTest,xs,1,3,500\nTest2,l,2,3,200"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args: args{ctx: context.TODO()},
-			want: map[int]goods.RemainsDTO{
-				1: {
-					Name: "Test",
-					Size: "xs",
-					StorageAvailable: map[int]int{
-						1: 500,
-						3: 500,
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx    context.Context
+			filter goods.Filter
+			page   pages.Query
+		}
+		columns := []string{"id", "name", "size", "uniq_code", "storage_id", "avail"}
+		const join = "JOIN remains ON goods.id = remains.good_id JOIN storages ON remains.storage_id = storages.id"
+		sqlStr := "SELECT goods.id, goods.name, goods.size, goods.uniq_code, remains.storage_id, remains.count - remains.reserved AS avail " +
+			"FROM ( SELECT goods.id FROM goods " + join + " WHERE goods.deleted_at IS NULL AND remains.count > remains.reserved AND storages.available = TRUE " +
+			"GROUP BY goods.id ORDER BY goods.id LIMIT ? ) AS page JOIN goods ON goods.id = page.id " + join +
+			" WHERE goods.deleted_at IS NULL AND remains.count > remains.reserved AND storages.available = TRUE ORDER BY goods.id, remains.storage_id"
+		filteredStr := "SELECT goods.id, goods.name, goods.size, goods.uniq_code, remains.storage_id, remains.count - remains.reserved AS avail " +
+			"FROM ( SELECT goods.id FROM goods " + join + " WHERE goods.deleted_at IS NULL AND goods.name LIKE ? AND remains.count > remains.reserved " +
+			"AND storages.available = TRUE AND remains.storage_id = ? AND (goods.uniq_code > ? OR (goods.uniq_code = ? AND goods.id > ?)) " +
+			"GROUP BY goods.id HAVING SUM(remains.count - remains.reserved) >= ? ORDER BY goods.uniq_code, goods.id LIMIT ? ) AS page " +
+			"JOIN goods ON goods.id = page.id " + join + " WHERE goods.deleted_at IS NULL AND goods.name LIKE ? AND remains.count > remains.reserved " +
+			"AND storages.available = TRUE AND remains.storage_id = ? ORDER BY goods.uniq_code, goods.id, remains.storage_id"
+		tests := []struct {
+			name     string
+			fields   fields
+			args     args
+			want     map[int]goods.RemainsDTO
+			wantNext string
+			wantErr  bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WithArgs(pages.DefaultLimit + 1).
+						WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,Test,xs,1,1,500\n1,Test,xs,1,3,500\n2,Test2,l,2,3,200\n2,Test2,l,2,4,200"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{ctx: context.TODO()},
+				want: map[int]goods.RemainsDTO{
+					1: {
+						Name: "Test",
+						Size: "xs",
+						StorageAvailable: map[int]int{
+							1: 500,
+							3: 500,
+						},
 					},
+					2: {
+						Name: "Test2",
+						Size: "l",
+						StorageAvailable: map[int]int{
+							4: 200,
+							3: 200,
+						},
+					},
+				},
+				wantErr: false,
+			}, {
+				name: "filtered page",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(filteredStr).WithArgs("%te%", 1, 1, 1, 1, 5, 2, "%te%", 1).
+						WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,Test2,l,2,1,10\n3,Test3,l,3,1,7"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{
+					ctx:    context.TODO(),
+					filter: goods.Filter{Name: "te", StorageId: 1, MinAvailable: 5},
+					page:   pages.Query{Cursor: testCursor("1", 1), Limit: 1, Sort: "uniq_code"},
 				},
-				2: {
-					Name: "Test2",
-					Size: "l",
-					StorageAvailable: map[int]int{
-						4: 200,
-						3: 200,
+				want: map[int]goods.RemainsDTO{
+					2: {
+						Name:             "Test2",
+						Size:             "l",
+						StorageAvailable: map[int]int{1: 10},
 					},
 				},
+				wantNext: testCursor("2", 2),
+			}, {
+				name: "invalid sort",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{ctx: context.TODO(), page: pages.Query{Sort: "price"}},
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "empty table",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString(""))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    map[int]goods.RemainsDTO{},
+				wantErr: false,
+			}, {
+				name: "err then doing req",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "err then doing get rows ",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "rows.Err()",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null").RowError(0, errors.New("test")))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
 			},
-			wantErr: false,
-		}, {
-			name: "invalid req",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New()
-				mock.ExpectPrepare("select * from sys").ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("Test,xs,1,1,500\nTest2,l,2,4,200\nTest,xs,1,3,500\nTest2,l,2,3,200"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		}, {
-			name: "empty table",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New()
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString(""))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    map[int]goods.RemainsDTO{},
-			wantErr: false,
-		}, {
-			name: "err then doing req",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New()
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		}, {
-			name: "err then doing get rows ",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New()
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		}, {
-			name: "rows.Err()",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New()
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null").RowError(0, errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.AvailableGoods(tt.args.ctx)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("AvailableGoods() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("AvailableGoods() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, next, err := d.AvailableGoods(tt.args.ctx, tt.args.filter, tt.args.page)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("AvailableGoods() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("AvailableGoods() got = %v, want %v", got, tt.want)
+				}
+				if next != tt.wantNext {
+					t.Errorf("AvailableGoods() next = %v, want %v", next, tt.wantNext)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("AvailableGoods() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_GoodAdd(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx      context.Context
-		name     string
-		size     string
-		uniqCode int
-	}
-	sqlStr := "insert into goods (name, size, uniq_code) values (?, ?, ?)"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    int64
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs("test", "xs", 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), "test", "xs", 1},
-			want:    1,
-			wantErr: false,
-		}, {
-			name: "err sql",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs("test", "xs", 1).WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), "test", "xs", 1},
-			want:    -1,
-			wantErr: true,
-		}, {
-			name: "err last inserted id",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs("test", "xs", 1).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), "test", "xs", 1},
-			want:    -1,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.GoodAdd(tt.args.ctx, tt.args.name, tt.args.size, tt.args.uniqCode)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GoodAdd() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("GoodAdd() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx      context.Context
+			name     string
+			size     string
+			uniqCode int
+		}
+		sqlStr := "insert into goods (name, size, uniq_code) values (?, ?, ?)"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    int64
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", "xs", 1).WillReturnId(1)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), "test", "xs", 1},
+				want:    1,
+				wantErr: false,
+			}, {
+				name: "err sql",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", "xs", 1).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), "test", "xs", 1},
+				want:    -1,
+				wantErr: true,
+			}, {
+				name: "duplicate uniq_code",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", "xs", 1).
+						WillReturnError(mock.duplicate())
+					mock.ExpectQuery("SELECT id FROM goods WHERE uniq_code = ?").WithArgs(1).
+						WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), "test", "xs", 1},
+				want:    -1,
+				wantErr: true,
+			}, {
+				name: "duplicate uniq_code of a vanished good",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", "xs", 1).
+						WillReturnError(mock.duplicate())
+					mock.ExpectQuery("SELECT id FROM goods WHERE uniq_code = ?").WithArgs(1).
+						WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), "test", "xs", 1},
+				want:    -1,
+				wantErr: true,
+			}, {
+				name: "err last inserted id",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", "xs", 1).WillFailId(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), "test", "xs", 1},
+				want:    -1,
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.GoodAdd(tt.args.ctx, tt.args.name, tt.args.size, tt.args.uniqCode)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("GoodAdd() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				var duplicate *DuplicateUniqCodeError
+				if strings.HasPrefix(tt.name, "duplicate") && (!errors.As(err, &duplicate) || !errors.Is(err, ErrDuplicateUniqCode)) {
+					t.Errorf("GoodAdd() error = %v, want %v", err, ErrDuplicateUniqCode)
+				}
+				if tt.name == "duplicate uniq_code" && duplicate.GoodId != 6 {
+					t.Errorf("GoodAdd() existing good id = %d, want 6", duplicate.GoodId)
+				}
+				if got != tt.want {
+					t.Errorf("GoodAdd() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_GoodDelete(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx      context.Context
-		uniqCode int
-	}
-	sqlStr := "delete from goods where uniq_code = ?"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    int64
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1},
-			want:    1,
-			wantErr: false,
-		}, {
-			name: "err sql",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1},
-			want:    -1,
-			wantErr: true,
-		}, {
-			name: "err last inserted id",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(1).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1},
-			want:    -1,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.GoodDelete(tt.args.ctx, tt.args.uniqCode)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GoodDelete() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("GoodDelete() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx      context.Context
+			uniqCode int
+		}
+		sqlStr := "update goods set deleted_at = ? where uniq_code = ? and deleted_at is null"
+		lockStr := "SELECT id FROM goods WHERE uniq_code = ? AND deleted_at IS NULL FOR UPDATE"
+		activeStr := "SELECT COUNT(*) FROM reservations WHERE uniq_code = ? AND status = ?"
+		activeRows := func(count int) *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"count"}).AddRow(count)
+		}
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    int64
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(lockStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+					mock.ExpectQuery(activeStr).WithArgs(1, reservations.StatusActive).WillReturnRows(activeRows(0))
+					mock.ExpectExec(sqlStr).WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 1},
+				want: 1,
+			}, {
+				name: "good not found or deleted",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(lockStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 1},
+				want: 0,
+			}, {
+				name: "good has active reservations",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(lockStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+					mock.ExpectQuery(activeStr).WithArgs(1, reservations.StatusActive).WillReturnRows(activeRows(2))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1},
+				want:    -1,
+				wantErr: ErrGoodReserved,
+			}, {
+				name: "err counting reservations",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(lockStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+					mock.ExpectQuery(activeStr).WithArgs(1, reservations.StatusActive).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1},
+				want:    -1,
+				wantErr: errors.New("test"),
+			}, {
+				name: "err sql",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(lockStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+					mock.ExpectQuery(activeStr).WithArgs(1, reservations.StatusActive).WillReturnRows(activeRows(0))
+					mock.ExpectExec(sqlStr).WithArgs(sqlmock.AnyArg(), 1).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 1},
+				want:    -1,
+				wantErr: sql.ErrNoRows,
+			}, {
+				name: "err last inserted id",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(lockStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+					mock.ExpectQuery(activeStr).WithArgs(1, reservations.StatusActive).WillReturnRows(activeRows(0))
+					mock.ExpectExec(sqlStr).WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
+					mock.ExpectRollback()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 1},
+				want:    -1,
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.GoodDelete(tt.args.ctx, tt.args.uniqCode)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("GoodDelete() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Is(tt.wantErr, ErrGoodReserved) && !errors.Is(err, ErrGoodReserved) {
+					t.Errorf("GoodDelete() error = %v, want %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("GoodDelete() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("GoodDelete() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
 }
 
-func TestDatabase_Goods(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx context.Context
-	}
-	sqlStr := "select * from goods;"
-	columns := []string{"id", "name", "size", "uniq_code"}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    []goods.Good
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,Test,xs,1\n2,Test2,l,2"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args: args{ctx: context.TODO()},
-			want: []goods.Good{{
-				Id:       1,
-				Name:     "Test",
-				Size:     "xs",
-				UniqCode: 1,
+func TestDatabase_GoodRestore(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		restoreStr := "update goods set deleted_at = null where uniq_code = ?"
+		selectStr := "select goods.id, goods.name, goods.size, goods.uniq_code, goods.deleted_at from goods where uniq_code = ?"
+		columns := []string{"id", "name", "size", "uniq_code", "deleted_at"}
+		tests := []struct {
+			name    string
+			fields  fields
+			want    goods.Good
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec(restoreStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(selectStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Test1", "L", 1, nil))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want: goods.Good{Id: 1, Name: "Test1", Size: "L", UniqCode: 1},
+			}, {
+				name: "good not found",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec(restoreStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectQuery(selectStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				wantErr: ErrGoodNotFound,
+			}, {
+				name: "err in update",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec(restoreStr).WithArgs(1).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				wantErr: errors.New("test"),
+			}, {
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec(restoreStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(selectStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Test1", "L", 1, nil))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				wantErr: errors.New("test"),
 			},
-				{
-					Id:       2,
-					Name:     "Test2",
-					Size:     "l",
-					UniqCode: 2,
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.GoodRestore(context.TODO(), 1)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("GoodRestore() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Is(tt.wantErr, ErrGoodNotFound) && !errors.Is(err, ErrGoodNotFound) {
+					t.Errorf("GoodRestore() error = %v, want %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("GoodRestore() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("GoodRestore() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_Goods(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx    context.Context
+			filter goods.Filter
+			page   pages.Query
+		}
+		sqlStr := "select goods.id, goods.name, goods.size, goods.uniq_code, goods.deleted_at from goods WHERE goods.deleted_at IS NULL order by goods.id limit ?"
+		columns := []string{"id", "name", "size", "uniq_code", "deleted_at"}
+		deletedAt := time.Date(2024, 2, 14, 10, 0, 0, 0, time.UTC)
+		tests := []struct {
+			name     string
+			fields   fields
+			args     args
+			want     []goods.Good
+			wantNext string
+			wantErr  bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WithArgs(pages.DefaultLimit + 1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,Test,xs,1,NULL\n2,Test2,l,2,NULL"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{ctx: context.TODO()},
+				want: []goods.Good{{
+					Id:       1,
+					Name:     "Test",
+					Size:     "xs",
+					UniqCode: 1,
+				},
+					{
+						Id:       2,
+						Name:     "Test2",
+						Size:     "l",
+						UniqCode: 2,
+					},
 				},
+				wantErr: false,
+			}, {
+				name: "filtered page sorted by name desc",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery("select goods.id, goods.name, goods.size, goods.uniq_code, goods.deleted_at from goods "+
+						"WHERE goods.deleted_at IS NULL AND goods.name LIKE ? AND goods.size = ? AND (goods.name < ? OR (goods.name = ? AND goods.id < ?)) "+
+						"order by goods.name DESC, goods.id DESC limit ?").
+						WithArgs(`%te\_1%`, "xs", "Test3", "Test3", 3, 3).
+						WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,Test2,xs,2,NULL\n1,Test1,xs,1,NULL\n4,Test0,xs,4,NULL"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{
+					ctx:    context.TODO(),
+					filter: goods.Filter{Name: "te_1", Size: "xs"},
+					page:   pages.Query{Cursor: testCursor("Test3", 3), Limit: 2, Sort: "-name"},
+				},
+				want: []goods.Good{
+					{Id: 2, Name: "Test2", Size: "xs", UniqCode: 2},
+					{Id: 1, Name: "Test1", Size: "xs", UniqCode: 1},
+				},
+				wantNext: testCursor("Test1", 1),
+			}, {
+				name: "include deleted",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery("select goods.id, goods.name, goods.size, goods.uniq_code, goods.deleted_at from goods order by goods.id limit ?").
+						WithArgs(pages.DefaultLimit + 1).
+						WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Test", "xs", 1, nil).AddRow(2, "Test2", "l", 2, deletedAt))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{ctx: context.TODO(), filter: goods.Filter{IncludeDeleted: true}},
+				want: []goods.Good{
+					{Id: 1, Name: "Test", Size: "xs", UniqCode: 1},
+					{Id: 2, Name: "Test2", Size: "l", UniqCode: 2, DeletedAt: &deletedAt},
+				},
+			}, {
+				name: "invalid cursor",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{ctx: context.TODO(), page: pages.Query{Cursor: "!"}},
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "empty table",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString(""))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    []goods.Good{},
+				wantErr: false,
+			}, {
+				name: "err then doing req",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "err then doing get rows ",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "rows.Err()",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null").RowError(0, errors.New("test")))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
 			},
-			wantErr: false,
-		}, {
-			name: "invalid req",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare("select * from sys;").ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,Test,xs,1\n2,Test2,l,2"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		}, {
-			name: "empty table",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString(""))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    []goods.Good{},
-			wantErr: false,
-		}, {
-			name: "err then doing req",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		}, {
-			name: "err then doing get rows ",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		}, {
-			name: "rows.Err()",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null").RowError(0, errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{ctx: context.TODO()},
-			want:    nil,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.Goods(tt.args.ctx)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Goods() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Goods() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, next, err := d.Goods(tt.args.ctx, tt.args.filter, tt.args.page)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Goods() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Goods() got = %v, want %v", got, tt.want)
+				}
+				if next != tt.wantNext {
+					t.Errorf("Goods() next = %v, want %v", next, tt.wantNext)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("Goods() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_ReleaseGood(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx    context.Context
-		uniqId int
-		count  int
-	}
-	columns := []string{"id", "storage_id", "reserved"}
-	sqlStr := "SELECT remains.id, remains.storage_id, remains.reserved from remains JOIN storages ON storages.id = remains.storage_id where good_id = ? AND storages.available = 1"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: false,
-		},
-		{
-			name: "not enough reserved",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 20},
-			wantErr: true,
-		},
-		{
-			name: "err while begin transaction",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin().WillReturnError(errors.New("test"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "empty remains",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString(""))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err while find id from uniq_code",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnError(errors.New("test"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		}, {
-			name: "err [sql.ErrNoRows] while find id from uniq_code",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err rows",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err in rows scan",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnError(errors.New("test"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err in update if reserved < count",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(15, 1).WillReturnError(errors.New("test"))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 20},
-			wantErr: true,
-		},
-		{
-			name: "err in update if reserved >= count",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(15, 1).WillReturnError(errors.New("test"))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "transaction commit error",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit().WillReturnError(errors.New("test"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			if err := d.ReleaseGood(tt.args.ctx, tt.args.uniqId, tt.args.count); (err != nil) != tt.wantErr {
-				t.Errorf("ReleaseGood() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx           context.Context
+			reservationId int64
+		}
+		created := time.Date(2024, 2, 14, 21, 56, 3, 0, time.UTC)
+		expires := created.Add(time.Hour)
+		reservationStr := "SELECT uniq_code, status, created_at, expires_at FROM reservations WHERE id = ? FOR UPDATE"
+		reservationColumns := []string{"uniq_code", "status", "created_at", "expires_at"}
+		columns := []string{"id", "remains_id", "good_id", "storage_id", "count"}
+		sqlStr := "SELECT reservation_lines.id, reservation_lines.remains_id, remains.good_id, remains.storage_id, reservation_lines.count FROM reservation_lines JOIN remains ON remains.id = reservation_lines.remains_id WHERE reservation_lines.reservation_id = ? ORDER BY remains.id FOR UPDATE"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    reservations.Reservation
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, expires))
+					mock.ExpectQuery(sqlStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,4,1,10\n2,3,4,2,5"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeRelease, 0, -10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(5, 3).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 2, movements.TypeRelease, 0, -5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec("UPDATE reservations SET status = ? WHERE id = ?").WithArgs("released", 7).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 7},
+				want: reservations.Reservation{
+					ID:        7,
+					UniqCode:  1,
+					Lines:     []reservations.Line{{StorageId: 1, Count: 10}, {StorageId: 2, Count: 5}},
+					CreatedAt: created,
+					ExpiresAt: &expires,
+					Status:    "released",
+				},
+				wantErr: false,
+			},
+			{
+				name: "already released",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "released", created, nil))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 7},
+				want: reservations.Reservation{
+					ID:        7,
+					UniqCode:  1,
+					CreatedAt: created,
+					Status:    "released",
+				},
+				wantErr: true,
+			},
+			{
+				name: "err while begin transaction",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin().WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 7},
+				want:    reservations.Reservation{ID: 7},
+				wantErr: true,
+			},
+			{
+				name: "err [sql.ErrNoRows] while find reservation",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 7},
+				want:    reservations.Reservation{ID: 7},
+				wantErr: true,
+			},
+			{
+				name: "err while find reservation",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 7},
+				want:    reservations.Reservation{ID: 7},
+				wantErr: true,
+			},
+			{
+				name: "err in lines request",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(sqlStr).WithArgs(7).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 7},
+				want: reservations.Reservation{
+					ID:        7,
+					UniqCode:  1,
+					CreatedAt: created,
+					Status:    "active",
+				},
+				wantErr: true,
+			},
+			{
+				name: "err in lines scan",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(sqlStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 7},
+				want: reservations.Reservation{
+					ID:        7,
+					UniqCode:  1,
+					CreatedAt: created,
+					Status:    "active",
+				},
+				wantErr: true,
+			},
+			{
+				name: "err in update remains",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(sqlStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,4,1,10"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(10, 1).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 7},
+				want: reservations.Reservation{
+					ID:        7,
+					UniqCode:  1,
+					CreatedAt: created,
+					Status:    "active",
+				},
+				wantErr: true,
+			},
+			{
+				name: "err in update status",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(sqlStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,4,1,10"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeRelease, 0, -10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec("UPDATE reservations SET status = ? WHERE id = ?").WithArgs("released", 7).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 7},
+				want: reservations.Reservation{
+					ID:        7,
+					UniqCode:  1,
+					Lines:     []reservations.Line{{StorageId: 1, Count: 10}},
+					CreatedAt: created,
+					Status:    "active",
+				},
+				wantErr: true,
+			},
+			{
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(sqlStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,4,1,10"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeRelease, 0, -10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec("UPDATE reservations SET status = ? WHERE id = ?").WithArgs("released", 7).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), 7},
+				want: reservations.Reservation{
+					ID:        7,
+					UniqCode:  1,
+					Lines:     []reservations.Line{{StorageId: 1, Count: 10}},
+					CreatedAt: created,
+					Status:    "active",
+				},
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.ReleaseGood(tt.args.ctx, tt.args.reservationId)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("ReleaseGood() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ReleaseGood() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_ExpireReservations(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		now := time.Date(2024, 2, 14, 21, 56, 3, 0, time.UTC)
+		created := now.Add(-time.Hour)
+		expiredStr := "SELECT id FROM reservations WHERE status = ? AND expires_at <= ?"
+		reservationStr := "SELECT uniq_code, status, created_at, expires_at FROM reservations WHERE id = ? FOR UPDATE"
+		reservationColumns := []string{"uniq_code", "status", "created_at", "expires_at"}
+		linesStr := "SELECT reservation_lines.id, reservation_lines.remains_id, remains.good_id, remains.storage_id, reservation_lines.count FROM reservation_lines JOIN remains ON remains.id = reservation_lines.remains_id WHERE reservation_lines.reservation_id = ? ORDER BY remains.id FOR UPDATE"
+		linesColumns := []string{"id", "remains_id", "good_id", "storage_id", "count"}
+		tests := []struct {
+			name    string
+			fields  fields
+			want    int
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(expiredStr).WithArgs("active", now).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("7\n8"))
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, now))
+					mock.ExpectQuery(linesStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("1,1,4,1,10"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeExpire, 0, -10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec("UPDATE reservations SET status = ? WHERE id = ?").WithArgs("expired", 7).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(8).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(2, "active", created, now))
+					mock.ExpectQuery(linesStr).WithArgs(8).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("2,2,5,1,3"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved - ? WHERE id = ?").WithArgs(3, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 5, 2, 1, movements.TypeExpire, 0, -3).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectExec("UPDATE reservations SET status = ? WHERE id = ?").WithArgs("expired", 8).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    2,
+				wantErr: false,
+			}, {
+				name: "released before sweep",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(expiredStr).WithArgs("active", now).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("7"))
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "released", created, now))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    0,
+				wantErr: false,
+			}, {
+				name: "nothing expired",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(expiredStr).WithArgs("active", now).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    0,
+				wantErr: false,
+			}, {
+				name: "err in request",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(expiredStr).WithArgs("active", now).WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    0,
+				wantErr: true,
+			}, {
+				name: "err in scan",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(expiredStr).WithArgs("active", now).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("null"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    0,
+				wantErr: true,
+			}, {
+				name: "err in release",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(expiredStr).WithArgs("active", now).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("7"))
+					mock.ExpectBegin().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    0,
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.ExpireReservations(context.TODO(), now)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("ExpireReservations() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if got != tt.want {
+					t.Errorf("ExpireReservations() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("ExpireReservations() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_ReserveGood(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx    context.Context
-		uniqId int
-		count  int
-	}
-	columns := []string{"id", "storage_id", "avail"}
-	sqlStr := "SELECT remains.id, remains.storage_id, remains.count - remains.reserved AS avail from remains JOIN storages ON storages.id = remains.storage_id where good_id = ? AND storages.available = 1"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    map[int]int
-		wantErr bool
-	}{
-		{
-			name: "normal avail >= count",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			want:    map[int]int{1: 15},
-			wantErr: false,
-		},
-		{
-			name: "normal avail < count",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,10\n2,2,10"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(5, 2).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			want:    map[int]int{1: 10, 2: 5},
-			wantErr: false,
-		},
-		{
-			name: "not enough reserved",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 20},
-			wantErr: true,
-		},
-		{
-			name: "err while begin transaction",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin().WillReturnError(errors.New("test"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "empty remains",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString(""))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err while find id from uniq_code",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnError(errors.New("test"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		}, {
-			name: "err [sql.ErrNoRows] while find id from uniq_code",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err rows",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err in rows scan",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnError(errors.New("test"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "err in update if reserved < count",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnError(errors.New("test"))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 20},
-			wantErr: true,
-		},
-		{
-			name: "err in update if reserved >= count",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnError(errors.New("test"))
-				mock.ExpectCommit()
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-		{
-			name: "transaction commit error",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id from goods where uniq_code = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
-				mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15"))
-				mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-				mock.ExpectCommit().WillReturnError(errors.New("test"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, 15},
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.ReserveGood(tt.args.ctx, tt.args.uniqId, tt.args.count)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ReserveGood() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ReserveGood() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx context.Context
+			req reservations.Request
+		}
+		columns := []string{"id", "storage_id", "avail", "priority"}
+		sqlStr := "SELECT remains.id, remains.storage_id, remains.count - remains.reserved AS avail, storages.priority from remains JOIN storages ON storages.id = remains.storage_id where good_id = ? AND storages.available = TRUE ORDER BY remains.id FOR UPDATE OF remains"
+		reservationStr := "INSERT INTO reservations (uniq_code, status, created_at, expires_at) VALUES (?, ?, ?, ?)"
+		lineStr := "INSERT INTO reservation_lines (reservation_id, remains_id, count) VALUES (?, ?, ?)"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    reservations.Reservation
+			wantErr bool
+		}{
+			{
+				name: "normal avail >= count",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0\n2,2,10,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				want: reservations.Reservation{
+					ID:       7,
+					UniqCode: 1,
+					Lines:    []reservations.Line{{StorageId: 1, Count: 15}},
+					Status:   "active",
+				},
+				wantErr: false,
+			},
+			{
+				name: "normal with ttl",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15, TTL: time.Hour}},
+				want: reservations.Reservation{
+					ID:       7,
+					UniqCode: 1,
+					Lines:    []reservations.Line{{StorageId: 1, Count: 15}},
+					Status:   "active",
+				},
+				wantErr: false,
+			},
+			{
+				name: "normal avail < count",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,10,0\n2,2,10,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(5, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 2, movements.TypeReserve, 0, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(lineStr).WithArgs(7, 2, 5).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectCommit()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				want: reservations.Reservation{
+					ID:       7,
+					UniqCode: 1,
+					Lines:    []reservations.Line{{StorageId: 1, Count: 10}, {StorageId: 2, Count: 5}},
+					Status:   "active",
+				},
+				wantErr: false,
+			},
+			{
+				name: "pinned storage",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0\n2,2,10,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(5, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 2, movements.TypeReserve, 0, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 2, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), reservations.Request{UniqCode: 1, Count: 5, Storages: []int{2}}},
+				want: reservations.Reservation{
+					ID:       7,
+					UniqCode: 1,
+					Lines:    []reservations.Line{{StorageId: 2, Count: 5}},
+					Status:   "active",
+				},
+				wantErr: false,
+			},
+			{
+				name: "pinned storage without enough goods",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0\n2,2,10,0"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 11, Storages: []int{2}}},
+				want:    reservations.Reservation{},
+				wantErr: true,
+			},
+			{
+				name: "not enough reserved",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 20}},
+				wantErr: true,
+			},
+			{
+				name: "err while begin transaction",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin().WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "empty remains",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString(""))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "err while find id from uniq_code",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			}, {
+				name: "err [sql.ErrNoRows] while find id from uniq_code",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "err rows",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "err in rows scan",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "err in update if reserved < count",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0\n2,2,10,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 20}},
+				wantErr: true,
+			},
+			{
+				name: "err in update if reserved >= count",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "err while create reservation",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "err while add reservation line",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 15).WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+			{
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery("SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec("UPDATE remains SET reserved = reserved + ? WHERE id = ?").WithArgs(15, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 15).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), reservations.Request{UniqCode: 1, Count: 15}},
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.ReserveGood(tt.args.ctx, tt.args.req)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("ReserveGood() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if tt.wantErr {
+					return
+				}
+				if got.CreatedAt.IsZero() {
+					t.Errorf("ReserveGood() got empty created_at")
+				}
+				if tt.args.req.TTL > 0 {
+					if got.ExpiresAt == nil || !got.ExpiresAt.Equal(got.CreatedAt.Add(tt.args.req.TTL)) {
+						t.Errorf("ReserveGood() got expires_at = %v, want created_at + %s", got.ExpiresAt, tt.args.req.TTL)
+					}
+					got.ExpiresAt = nil
+				}
+				got.CreatedAt = time.Time{}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ReserveGood() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_ReserveGoods(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		columns := []string{"id", "storage_id", "avail", "priority"}
+		goodStr := "SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE"
+		sqlStr := "SELECT remains.id, remains.storage_id, remains.count - remains.reserved AS avail, storages.priority from remains JOIN storages ON storages.id = remains.storage_id where good_id = ? AND storages.available = TRUE ORDER BY remains.id FOR UPDATE OF remains"
+		updateStr := "UPDATE remains SET reserved = reserved + ? WHERE id = ?"
+		reservationStr := "INSERT INTO reservations (uniq_code, status, created_at, expires_at) VALUES (?, ?, ?, ?)"
+		lineStr := "INSERT INTO reservation_lines (reservation_id, remains_id, count) VALUES (?, ?, ?)"
+		reqs := []reservations.Request{{UniqCode: 1, Count: 5}, {UniqCode: 2, Count: 3}}
+		tests := []struct {
+			name        string
+			fields      fields
+			want        []reservations.Reservation
+			wantErr     bool
+			wantLineErr []error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec(updateStr).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectQuery(goodStr).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("2"))
+					mock.ExpectQuery(sqlStr).WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,3,3,0"))
+					mock.ExpectExec(updateStr).WithArgs(3, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 2, 2, 3, movements.TypeReserve, 0, 3).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(2, "active", sqlmock.AnyArg(), nil).WillReturnId(8)
+					mock.ExpectExec(lineStr).WithArgs(8, 2, 3).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want: []reservations.Reservation{
+					{ID: 7, UniqCode: 1, Lines: []reservations.Line{{StorageId: 1, Count: 5}}, Status: "active"},
+					{ID: 8, UniqCode: 2, Lines: []reservations.Line{{StorageId: 3, Count: 3}}, Status: "active"},
+				},
+				wantErr: false,
+			}, {
+				name: "one line can't be reserved",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec(updateStr).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectQuery(goodStr).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("2"))
+					mock.ExpectQuery(sqlStr).WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,3,2,0"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:        nil,
+				wantErr:     true,
+				wantLineErr: []error{nil, ErrInsufficientStock},
+			}, {
+				name: "unknown good",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
+					mock.ExpectQuery(goodStr).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("2"))
+					mock.ExpectQuery(sqlStr).WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,3,2,0"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:        nil,
+				wantErr:     true,
+				wantLineErr: []error{ErrGoodNotFound, ErrInsufficientStock},
+			}, {
+				name: "deadlock retries the whole batch",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnError(mock.deadlock())
+					mock.ExpectRollback()
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec(updateStr).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectQuery(goodStr).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("2"))
+					mock.ExpectQuery(sqlStr).WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,3,3,0"))
+					mock.ExpectExec(updateStr).WithArgs(3, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 2, 2, 3, movements.TypeReserve, 0, 3).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(2, "active", sqlmock.AnyArg(), nil).WillReturnId(8)
+					mock.ExpectExec(lineStr).WithArgs(8, 2, 3).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want: []reservations.Reservation{
+					{ID: 7, UniqCode: 1, Lines: []reservations.Line{{StorageId: 1, Count: 5}}, Status: "active"},
+					{ID: 8, UniqCode: 2, Lines: []reservations.Line{{StorageId: 3, Count: 3}}, Status: "active"},
+				},
+			}, {
+				name: "err while begin transaction",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+					mock.ExpectQuery(sqlStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,1,15,0"))
+					mock.ExpectExec(updateStr).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 1, 1, 1, movements.TypeReserve, 0, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(1, "active", sqlmock.AnyArg(), nil).WillReturnId(7)
+					mock.ExpectExec(lineStr).WithArgs(7, 1, 5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectQuery(goodStr).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("2"))
+					mock.ExpectQuery(sqlStr).WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,3,3,0"))
+					mock.ExpectExec(updateStr).WithArgs(3, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 2, 2, 3, movements.TypeReserve, 0, 3).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectInsert(reservationStr).WithArgs(2, "active", sqlmock.AnyArg(), nil).WillReturnId(8)
+					mock.ExpectExec(lineStr).WithArgs(8, 2, 3).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want:    nil,
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := New(tt.fields.conn, WithDialect(dialect), WithRetryPolicy(RetryPolicy{Attempts: 2}))
+				got, err := d.ReserveGoods(context.TODO(), reqs)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("ReserveGoods() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if tt.wantLineErr != nil {
+					var batchErr *BatchError
+					if !errors.As(err, &batchErr) {
+						t.Fatalf("ReserveGoods() error = %v, want *BatchError", err)
+					}
+					for i, want := range tt.wantLineErr {
+						if !errors.Is(batchErr.Errors[i], want) {
+							t.Errorf("ReserveGoods() line %d error = %v, want %v", i, batchErr.Errors[i], want)
+						}
+					}
+				}
+				for i := range got {
+					got[i].CreatedAt = time.Time{}
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ReserveGoods() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_Storages(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx context.Context
-		all bool
-	}
-	sqlStr := "select * from storages"
-	columns := []string{"id", "name", "available"}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    []storages.Storage
-		wantErr bool
-	}{
-		{
-			name: "normal all",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1\n2,test2,1\n3,test2,0"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args: args{context.TODO(), true},
-			want: []storages.Storage{
-				{1, "test1", "1", true},
-				{2, "test2", "1", true},
-				{3, "test2", "0", false},
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx    context.Context
+			filter storages.Filter
+			page   pages.Query
+		}
+		selectStr := "select id, name, available, address, region, priority, capacity, tags from storages"
+		sqlStr := selectStr + " order by id limit ?"
+		available := true
+		columns := []string{"id", "name", "available", "address", "region", "priority", "capacity", "tags"}
+		tests := []struct {
+			name     string
+			fields   fields
+			args     args
+			want     []storages.Storage
+			wantNext string
+			wantErr  bool
+		}{
+			{
+				name: "normal all",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1,Moscow,msk,10,500,{}\n2,test2,1,,,0,0,NULL\n3,test2,0,,,0,0,NULL"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{ctx: context.TODO()},
+				want: []storages.Storage{
+					{ID: 1, Name: "test1", RawAvailable: "1", Available: true, Address: "Moscow", Region: "msk", Priority: 10, Capacity: 500, Tags: map[string]string{}},
+					{ID: 2, Name: "test2", RawAvailable: "1", Available: true},
+					{ID: 3, Name: "test2", RawAvailable: "0", Available: false},
+				},
+				wantErr: false,
+			}, {
+				name: "normal available",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(selectStr+" WHERE available = ? order by id limit ?").WithArgs(true, pages.DefaultLimit+1).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1,Moscow,msk,10,500,{}\n2,test2,1,,,0,0,NULL\n3,test2,0,,,0,0,NULL"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{ctx: context.TODO(), filter: storages.Filter{Available: &available}},
+				want: []storages.Storage{
+					{ID: 1, Name: "test1", RawAvailable: "1", Available: true, Address: "Moscow", Region: "msk", Priority: 10, Capacity: 500, Tags: map[string]string{}},
+					{ID: 2, Name: "test2", RawAvailable: "1", Available: true},
+					{ID: 3, Name: "test2", RawAvailable: "0", Available: false},
+				},
+				wantErr: false,
+			},
+			{
+				name: "filtered page sorted by priority desc",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(selectStr+" WHERE name LIKE ? AND (priority < ? OR (priority = ? AND id < ?)) order by priority DESC, id DESC limit ?").
+						WithArgs("%test%", 10, 10, 1, 2).
+						WillReturnRows(sqlmock.NewRows(columns).FromCSVString("2,test2,1,,,5,0,NULL\n3,test3,0,,,0,0,NULL"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{
+					ctx:    context.TODO(),
+					filter: storages.Filter{Name: "test"},
+					page:   pages.Query{Cursor: testCursor("10", 1), Limit: 1, Sort: "-priority"},
+				},
+				want: []storages.Storage{
+					{ID: 2, Name: "test2", RawAvailable: "1", Available: true, Priority: 5},
+				},
+				wantNext: testCursor("5", 2),
+			},
+			{
+				name: "cursor of another sort",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{ctx: context.TODO(), page: pages.Query{Cursor: testCursor("test2", 2), Sort: "priority"}},
+				want:    nil,
+				wantErr: true,
+			},
+			{
+				name: "incorrect sql",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1,Moscow,msk,10,500,{}\n2,test2,1,,,0,0,NULL\n3,test2,0,,,0,0,NULL"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO(), filter: storages.Filter{Available: &available}},
+				want:    nil,
+				wantErr: true,
+			},
+			{
+				name: "err doing query",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
+			},
+			{
+				name: "err scan from rows",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
 			},
-			wantErr: false,
-		}, {
-			name: "normal available",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(fmt.Sprintf("%s where available = 1", sqlStr)).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1\n2,test2,1\n3,test2,0"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args: args{context.TODO(), false},
-			want: []storages.Storage{
-				{1, "test1", "1", true},
-				{2, "test2", "1", true},
-				{3, "test2", "0", false},
+			{
+				name: "arr in ParseBool",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1,,,0,0,NULL\n2,test2,2,,,0,0,NULL\n3,test2,0,,,0,0,NULL"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
+			}, {
+				name: "arr in ParseBool",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(sqlStr).WillReturnRows(sqlmock.NewRows(columns).CloseError(errors.New("test")))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO()},
+				want:    nil,
+				wantErr: true,
 			},
-			wantErr: false,
-		},
-		{
-			name: "incorrect sql",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1\n2,test2,1\n3,test2,0"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), false},
-			want:    nil,
-			wantErr: true,
-		},
-		{
-			name: "err doing query",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), true},
-			want:    nil,
-			wantErr: true,
-		},
-		{
-			name: "err scan from rows",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("null"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), true},
-			want:    nil,
-			wantErr: true,
-		},
-		{
-			name: "arr in ParseBool",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).FromCSVString("1,test1,1\n2,test2,2\n3,test2,0"))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), true},
-			want:    nil,
-			wantErr: true,
-		}, {
-			name: "arr in ParseBool",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectPrepare(sqlStr).ExpectQuery().WillReturnRows(sqlmock.NewRows(columns).CloseError(errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), true},
-			want:    nil,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.Storages(tt.args.ctx, tt.args.all)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Storages() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Storages() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, next, err := d.Storages(tt.args.ctx, tt.args.filter, tt.args.page)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Storages() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Storages() got = %v, want %v", got, tt.want)
+				}
+				if next != tt.wantNext {
+					t.Errorf("Storages() next = %v, want %v", next, tt.wantNext)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_StoragesAdd(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx       context.Context
-		name      string
-		available bool
-	}
-	sqlStr := "insert into storages (name, available) values (?, ?)"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    int64
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs("test", true).WillReturnResult(sqlmock.NewResult(1, 1))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), "test", true},
-			want:    1,
-			wantErr: false,
-		},
-		{
-			name: "err sql",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs("test", true).WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), "test", true},
-			want:    -1,
-			wantErr: true,
-		}, {
-			name: "err last inserted id",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs("test", true).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), "test", true},
-			want:    -1,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.StoragesAdd(tt.args.ctx, tt.args.name, tt.args.available)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("StoragesAdd() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("StoragesAdd() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx     context.Context
+			storage storages.Storage
+		}
+		sqlStr := "insert into storages (name, available, address, region, priority, capacity, tags) values (?, ?, ?, ?, ?, ?, ?)"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    int64
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", true, "", "", 0, 0, nil).WillReturnId(1)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), storages.Storage{Name: "test", Available: true}},
+				want:    1,
+				wantErr: false,
+			},
+			{
+				name: "normal with metadata",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", true, "Moscow", "msk", 10, 500, `{"type":"cold"}`).WillReturnId(2)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{context.TODO(), storages.Storage{
+					Name:      "test",
+					Available: true,
+					Address:   "Moscow",
+					Region:    "msk",
+					Priority:  10,
+					Capacity:  500,
+					Tags:      map[string]string{"type": "cold"},
+				}},
+				want:    2,
+				wantErr: false,
+			},
+			{
+				name: "err sql",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", true, "", "", 0, 0, nil).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), storages.Storage{Name: "test", Available: true}},
+				want:    -1,
+				wantErr: true,
+			}, {
+				name: "err last inserted id",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectInsert(sqlStr).WithArgs("test", true, "", "", 0, 0, nil).WillFailId(errors.New("test"))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), storages.Storage{Name: "test", Available: true}},
+				want:    -1,
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.StoragesAdd(tt.args.ctx, tt.args.storage)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("StoragesAdd() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if got != tt.want {
+					t.Errorf("StoragesAdd() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_StoragesChangeAccess(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx       context.Context
-		id        int
-		available bool
-	}
-	sqlStr := "update storages set available = ? where id = ?"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    int64
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(false, 1).WillReturnResult(sqlmock.NewResult(0, 1))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, false},
-			want:    1,
-			wantErr: false,
-		}, {
-			name: "err sql",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(false, 1).WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, false},
-			want:    -1,
-			wantErr: true,
-		}, {
-			name: "err RowsAffected",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(false, 1).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1, false},
-			want:    -1,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.StoragesChangeAccess(tt.args.ctx, tt.args.id, tt.args.available)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("StoragesChangeAccess() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("StoragesChangeAccess() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx       context.Context
+			id        int
+			available bool
+		}
+		sqlStr := "update storages set available = ? where id = ?"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    int64
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectExec(sqlStr).WithArgs(false, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 1, false},
+				want:    1,
+				wantErr: false,
+			}, {
+				name: "err sql",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectExec(sqlStr).WithArgs(false, 1).WillReturnError(sql.ErrNoRows)
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 1, false},
+				want:    -1,
+				wantErr: true,
+			}, {
+				name: "err RowsAffected",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectExec(sqlStr).WithArgs(false, 1).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{context.TODO(), 1, false},
+				want:    -1,
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.StoragesChangeAccess(tt.args.ctx, tt.args.id, tt.args.available)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("StoragesChangeAccess() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if got != tt.want {
+					t.Errorf("StoragesChangeAccess() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
 }
 
 func TestDatabase_StoragesDelete(t *testing.T) {
-	type fields struct {
-		conn *sql.DB
-		mock sqlmock.Sqlmock
-	}
-	type args struct {
-		ctx context.Context
-		id  int
-	}
-	sqlStr := "delete from storages where id = ?"
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    int64
-		wantErr bool
-	}{
-		{
-			name: "normal",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1},
-			want:    1,
-			wantErr: false,
-		}, {
-			name: "err sql",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1},
-			want:    -1,
-			wantErr: true,
-		}, {
-			name: "err RowsAffected",
-			fields: func() fields {
-				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-				mock.ExpectExec(sqlStr).WithArgs(1).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
-				tmp := fields{
-					conn: db,
-					mock: mock,
-				}
-				return tmp
-			}(),
-			args:    args{context.TODO(), 1},
-			want:    -1,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &Database{
-				conn: tt.fields.conn,
-			}
-			got, err := d.StoragesDelete(tt.args.ctx, tt.args.id)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("StoragesDelete() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("StoragesDelete() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx       context.Context
+			id        int
+			migrateTo int
+		}
+		sqlStr := "delete from storages where id = ?"
+		remainsStr := "SELECT remains.id, remains.good_id, goods.uniq_code, remains.storage_id, remains.count, remains.reserved FROM remains JOIN goods ON goods.id = remains.good_id WHERE remains.storage_id = ? FOR UPDATE OF remains"
+		remainsColumns := []string{"id", "good_id", "uniq_code", "storage_id", "count", "reserved"}
+		emptyStr := "DELETE FROM remains WHERE storage_id = ?"
+		referencedStr := "SELECT remains.id FROM remains WHERE remains.storage_id = ? AND ( " +
+			"EXISTS (SELECT 1 FROM reservation_lines WHERE reservation_lines.remains_id = remains.id) " +
+			"OR EXISTS (SELECT 1 FROM shipment_lines WHERE shipment_lines.remains_id = remains.id))"
+		upsertStr := "INSERT INTO remains (good_id, storage_id, count, reserved) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE count = remains.count + ?, reserved = remains.reserved + ?"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    int64
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns).FromCSVString("3,4,1,1,0,0"))
+					mock.ExpectQuery(referencedStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+					mock.ExpectExec(emptyStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(sqlStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args: args{ctx: context.TODO(), id: 1},
+				want: 1,
+			}, {
+				name: "storage holds goods",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns).
+						FromCSVString("3,4,1,1,10,2\n5,6,2,1,0,0\n7,8,3,1,4,0"))
+					mock.ExpectQuery(referencedStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{ctx: context.TODO(), id: 1},
+				want: -1,
+				wantErr: &StorageNotEmptyError{StorageId: 1, Remains: []storages.Remains{
+					{UniqCode: 1, Count: 10, Reserved: 2},
+					{UniqCode: 3, Count: 4},
+				}},
+			}, {
+				name: "storage keeps history",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns).
+						FromCSVString("3,4,1,1,0,0\n5,6,2,1,0,0"))
+					mock.ExpectQuery(referencedStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{ctx: context.TODO(), id: 1},
+				want:    -1,
+				wantErr: &StorageNotEmptyError{StorageId: 1, Remains: []storages.Remains{{UniqCode: 2, History: true}}},
+			}, {
+				name: "migrate goods",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns).
+						FromCSVString("3,4,1,1,10,2\n5,6,2,1,0,0"))
+					mock.ExpectQuery("SELECT available FROM storages WHERE id = ? FOR SHARE").WithArgs(2).
+						WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(true))
+					mock.ExpectExec(upsertStr).WithArgs(4, 2, 10, 2, 10, 2).WillReturnResult(sqlmock.NewResult(9, 1))
+					mock.ExpectQuery("SELECT id FROM remains WHERE good_id = ? AND storage_id = ?").WithArgs(4, 2).
+						WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+					mock.ExpectExec("UPDATE reservation_lines SET remains_id = ? WHERE remains_id = ?").WithArgs(9, 3).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec("UPDATE shipment_lines SET remains_id = ? WHERE remains_id = ?").WithArgs(9, 3).
+						WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("DELETE FROM remains WHERE id = ?").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeTransferOut, -10, -2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 2, movements.TypeTransferIn, 10, 2).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectExec(upsertStr).WithArgs(6, 2, 0, 0, 0, 0).WillReturnResult(sqlmock.NewResult(10, 1))
+					mock.ExpectQuery("SELECT id FROM remains WHERE good_id = ? AND storage_id = ?").WithArgs(6, 2).
+						WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+					mock.ExpectExec("UPDATE reservation_lines SET remains_id = ? WHERE remains_id = ?").WithArgs(10, 5).
+						WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("UPDATE shipment_lines SET remains_id = ? WHERE remains_id = ?").WithArgs(10, 5).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec("DELETE FROM remains WHERE id = ?").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(sqlStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{ctx: context.TODO(), id: 1, migrateTo: 2},
+				want: 1,
+			}, {
+				name: "migrate to unavailable storage",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns).FromCSVString("3,4,1,1,10,2"))
+					mock.ExpectQuery("SELECT available FROM storages WHERE id = ? FOR SHARE").WithArgs(2).
+						WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(false))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{ctx: context.TODO(), id: 1, migrateTo: 2},
+				want:    -1,
+				wantErr: ErrStorageUnavailable,
+			}, {
+				name: "migrate to unknown storage",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns).FromCSVString("3,4,1,1,10,2"))
+					mock.ExpectQuery("SELECT available FROM storages WHERE id = ? FOR SHARE").WithArgs(2).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{ctx: context.TODO(), id: 1, migrateTo: 2},
+				want:    -1,
+				wantErr: ErrStorageNotFound,
+			}, {
+				name: "migrate to itself",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{ctx: context.TODO(), id: 1, migrateTo: 1},
+				want:    -1,
+				wantErr: errors.New("can't migrate goods of storage 1 to itself"),
+			}, {
+				name: "err sql",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns))
+					mock.ExpectExec(emptyStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec(sqlStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO(), id: 1},
+				want:    -1,
+				wantErr: sql.ErrNoRows,
+			}, {
+				name: "err RowsAffected",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(remainsStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(remainsColumns))
+					mock.ExpectExec(emptyStr).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec(sqlStr).WithArgs(1).WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
+					mock.ExpectRollback()
+					tmp := fields{
+						conn: db,
+						mock: mock,
+					}
+					return tmp
+				}(),
+				args:    args{ctx: context.TODO(), id: 1},
+				want:    -1,
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.StoragesDelete(tt.args.ctx, tt.args.id, tt.args.migrateTo)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("StoragesDelete() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				var wantNotEmpty, notEmpty *StorageNotEmptyError
+				if errors.As(tt.wantErr, &wantNotEmpty) && (!errors.As(err, &notEmpty) || !reflect.DeepEqual(notEmpty, wantNotEmpty)) {
+					t.Errorf("StoragesDelete() error = %v, want %v", err, tt.wantErr)
+				}
+				for _, target := range []error{ErrStorageNotFound, ErrStorageUnavailable} {
+					if errors.Is(tt.wantErr, target) && !errors.Is(err, target) {
+						t.Errorf("StoragesDelete() error = %v, want %v", err, tt.wantErr)
+					}
+				}
+				if got != tt.want {
+					t.Errorf("StoragesDelete() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("StoragesDelete() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_StoragesUpdate(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx    context.Context
+			id     int
+			update storages.Update
+		}
+		selectStr := "select id, name, available, address, region, priority, capacity, tags from storages where id = ?"
+		columns := []string{"id", "name", "available", "address", "region", "priority", "capacity", "tags"}
+		name := "renamed"
+		priority := 5
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    storages.Storage
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update storages set name = ?, priority = ?, tags = ? where id = ?").
+						WithArgs("renamed", 5, `{"type":"cold"}`, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(selectStr).WithArgs(3).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(3, "renamed", "1", "Moscow", "msk", 5, 500, `{"type":"cold"}`))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 3, storages.Update{Name: &name, Priority: &priority, Tags: map[string]string{"type": "cold"}}},
+				want: storages.Storage{
+					ID:           3,
+					Name:         "renamed",
+					RawAvailable: "1",
+					Available:    true,
+					Address:      "Moscow",
+					Region:       "msk",
+					Priority:     5,
+					Capacity:     500,
+					Tags:         map[string]string{"type": "cold"},
+				},
+			}, {
+				name: "nothing to update",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 3, storages.Update{}},
+				wantErr: errors.New("nothing to update in storage 3"),
+			}, {
+				name: "storage not found",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update storages set name = ? where id = ?").WithArgs("renamed", 3).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectQuery(selectStr).WithArgs(3).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 3, storages.Update{Name: &name}},
+				wantErr: ErrStorageNotFound,
+			}, {
+				name: "err in update",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update storages set name = ? where id = ?").WithArgs("renamed", 3).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 3, storages.Update{Name: &name}},
+				wantErr: errors.New("test"),
+			}, {
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update storages set name = ? where id = ?").WithArgs("renamed", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(selectStr).WithArgs(3).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(3, "renamed", "1", "", "", 0, 0, nil))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 3, storages.Update{Name: &name}},
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.StoragesUpdate(tt.args.ctx, tt.args.id, tt.args.update)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("StoragesUpdate() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Is(tt.wantErr, ErrStorageNotFound) && !errors.Is(err, ErrStorageNotFound) {
+					t.Errorf("StoragesUpdate() error = %v, want %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("StoragesUpdate() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("StoragesUpdate() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_GoodUpdate(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx      context.Context
+			uniqCode int
+			update   goods.Update
+		}
+		selectStr := "select goods.id, goods.name, goods.size, goods.uniq_code, goods.deleted_at from goods where uniq_code = ? and deleted_at is null"
+		columns := []string{"id", "name", "size", "uniq_code", "deleted_at"}
+		name := "renamed"
+		size := "XL"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    goods.Good
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update goods set name = ?, size = ? where uniq_code = ? and deleted_at is null").
+						WithArgs("renamed", "XL", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(selectStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "renamed", "XL", 1, nil))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 1, goods.Update{Name: &name, Size: &size}},
+				want: goods.Good{Id: 1, Name: "renamed", Size: "XL", UniqCode: 1},
+			}, {
+				name: "only size",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update goods set size = ? where uniq_code = ? and deleted_at is null").
+						WithArgs("XL", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(selectStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "Test1", "XL", 1, nil))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 1, goods.Update{Size: &size}},
+				want: goods.Good{Id: 1, Name: "Test1", Size: "XL", UniqCode: 1},
+			}, {
+				name: "nothing to update",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, goods.Update{}},
+				wantErr: errors.New("nothing to update in good 1"),
+			}, {
+				name: "good not found or deleted",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update goods set name = ? where uniq_code = ? and deleted_at is null").WithArgs("renamed", 1).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectQuery(selectStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, goods.Update{Name: &name}},
+				wantErr: ErrGoodNotFound,
+			}, {
+				name: "err in update",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update goods set name = ? where uniq_code = ? and deleted_at is null").WithArgs("renamed", 1).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, goods.Update{Name: &name}},
+				wantErr: errors.New("test"),
+			}, {
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectExec("update goods set name = ? where uniq_code = ? and deleted_at is null").WithArgs("renamed", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(selectStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "renamed", "L", 1, nil))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, goods.Update{Name: &name}},
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.GoodUpdate(tt.args.ctx, tt.args.uniqCode, tt.args.update)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("GoodUpdate() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Is(tt.wantErr, ErrGoodNotFound) && !errors.Is(err, ErrGoodNotFound) {
+					t.Errorf("GoodUpdate() error = %v, want %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("GoodUpdate() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("GoodUpdate() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_GoodStock(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		queryStr := `SELECT goods.id, goods.name, goods.size, goods.uniq_code, goods.deleted_at, remains.storage_id, storages.available,
+		remains.count, remains.reserved FROM goods
+		LEFT JOIN remains ON goods.id = remains.good_id
+		LEFT JOIN storages ON remains.storage_id = storages.id
+		WHERE goods.uniq_code = ? ORDER BY remains.storage_id`
+		columns := []string{"id", "name", "size", "uniq_code", "deleted_at", "storage_id", "available", "count", "reserved"}
+		tests := []struct {
+			name    string
+			fields  fields
+			want    goods.Stock
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(queryStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "Test1", "L", 1, nil, 1, 1, 15, 5).
+						AddRow(1, "Test1", "L", 1, nil, 2, 0, 10, 0).
+						AddRow(1, "Test1", "L", 1, nil, 3, 1, 10, 10))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want: goods.Stock{
+					Good:      goods.Good{Id: 1, Name: "Test1", Size: "L", UniqCode: 1},
+					Count:     35,
+					Reserved:  15,
+					Available: 10,
+					Storages: []goods.StorageStock{
+						{StorageId: 1, StorageAvailable: true, Count: 15, Reserved: 5, Available: 10},
+						{StorageId: 2, StorageAvailable: false, Count: 10, Reserved: 0, Available: 0},
+						{StorageId: 3, StorageAvailable: true, Count: 10, Reserved: 10, Available: 0},
+					},
+				},
+			}, {
+				name: "good without remains",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(queryStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "Test1", "L", 1, nil, nil, nil, nil, nil))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				want: goods.Stock{
+					Good:     goods.Good{Id: 1, Name: "Test1", Size: "L", UniqCode: 1},
+					Storages: []goods.StorageStock{},
+				},
+			}, {
+				name: "good not found",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(queryStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				wantErr: ErrGoodNotFound,
+			}, {
+				name: "err from db",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(queryStr).WithArgs(1).WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				wantErr: errors.New("test"),
+			}, {
+				name: "rows error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectQuery(queryStr).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "Test1", "L", 1, nil, 1, 1, 15, 5).RowError(0, errors.New("test")))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.GoodStock(context.TODO(), 1)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("GoodStock() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Is(tt.wantErr, ErrGoodNotFound) && !errors.Is(err, ErrGoodNotFound) {
+					t.Errorf("GoodStock() error = %v, want %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("GoodStock() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("GoodStock() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
 }
@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/movements"
+	"LamodaTest/internal/entity/reservations"
+	"context"
+	"fmt"
+	"time"
+)
+
+// ShipGood moves count of reserved goods out of remains, both from `reserved` and `count`,
+// on the storages that hold the reservation. Zero count ships everything that is still reserved.
+// The reservation becomes shipped when nothing is left in it.
+func (d *Database) ShipGood(ctx context.Context, reservationId int64, count int) (reservations.Shipment, error) {
+	shipment := reservations.Shipment{ReservationID: reservationId}
+	err := d.inTx(ctx, "ship", func(tx runner) error {
+		var err error
+		shipment, err = ship(ctx, tx, reservationId, count)
+		return err
+	})
+	if err != nil {
+		// nothing is shipped, only the reservation is described
+		return reservations.Shipment{ReservationID: reservationId, UniqCode: shipment.UniqCode}, err
+	}
+	return shipment, nil
+}
+
+func ship(ctx context.Context, tx runner, reservationId int64, count int) (reservations.Shipment, error) {
+	shipment := reservations.Shipment{ReservationID: reservationId}
+	reservation := reservations.Reservation{ID: reservationId}
+	if err := loadReservation(ctx, tx, &reservation); err != nil {
+		return shipment, err
+	}
+	shipment.UniqCode = reservation.UniqCode
+	if reservation.Status != reservations.StatusActive {
+		return shipment, fmt.Errorf("can't ship reservation %d, it is %s: %w",
+			reservationId, reservation.Status, ErrReservationClosed)
+	}
+	lines, err := reservationLines(ctx, tx, reservationId)
+	if err != nil {
+		return shipment, err
+	}
+	reserved := 0
+	for _, line := range lines {
+		reserved += line.count
+	}
+	if count == 0 {
+		count = reserved
+	}
+	if count < 0 || count > reserved {
+		return shipment, fmt.Errorf("can't ship %d goods of reservation %d, only %d reserved: %w",
+			count, reservationId, reserved, ErrExceedsReserved)
+	}
+	var shipped []reservedLine
+	left := count
+	for _, line := range lines {
+		if left == 0 {
+			break
+		}
+		take := min(line.count, left)
+		_, err = tx.ExecContext(ctx, "UPDATE remains SET count = count - ?, reserved = reserved - ? WHERE id = ?",
+			take, take, line.remainsId)
+		if err != nil {
+			return shipment, fmt.Errorf("can't update remains note with id %d: %w", line.remainsId, err)
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE reservation_lines SET count = count - ? WHERE id = ?", take, line.id)
+		if err != nil {
+			return shipment, fmt.Errorf("can't update line %d of reservation %d: %w", line.id, reservationId, err)
+		}
+		err = recordMovement(ctx, tx, line.goodId, movements.Movement{
+			UniqCode:      reservation.UniqCode,
+			StorageId:     line.storageId,
+			Type:          movements.TypeShip,
+			CountDelta:    -take,
+			ReservedDelta: -take,
+		})
+		if err != nil {
+			return shipment, err
+		}
+		line.count = take
+		shipped = append(shipped, line)
+		left -= take
+	}
+	shipment.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	shipment.ID, err = tx.insert(ctx, "INSERT INTO shipments (reservation_id, created_at) VALUES (?, ?)",
+		reservationId, shipment.CreatedAt)
+	if err != nil {
+		return shipment, fmt.Errorf("can't create shipment for reservation %d: %w", reservationId, err)
+	}
+	for _, line := range shipped {
+		_, err = tx.ExecContext(ctx, "INSERT INTO shipment_lines (shipment_id, remains_id, count) VALUES (?, ?, ?)",
+			shipment.ID, line.remainsId, line.count)
+		if err != nil {
+			return shipment, fmt.Errorf("can't add line to shipment %d: %w", shipment.ID, err)
+		}
+		shipment.Lines = append(shipment.Lines, reservations.Line{StorageId: line.storageId, Count: line.count})
+	}
+	if count == reserved {
+		_, err = tx.ExecContext(ctx, "UPDATE reservations SET status = ? WHERE id = ?",
+			reservations.StatusShipped, reservationId)
+		if err != nil {
+			return shipment, fmt.Errorf("can't change status of reservation %d: %w", reservationId, err)
+		}
+	}
+	return shipment, nil
+}
@@ -0,0 +1,231 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/movements"
+	"LamodaTest/internal/entity/reservations"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDatabase_ShipGood(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx           context.Context
+			reservationId int64
+			count         int
+		}
+		created := time.Date(2024, 2, 14, 21, 56, 3, 0, time.UTC)
+		reservationStr := "SELECT uniq_code, status, created_at, expires_at FROM reservations WHERE id = ? FOR UPDATE"
+		reservationColumns := []string{"uniq_code", "status", "created_at", "expires_at"}
+		linesStr := "SELECT reservation_lines.id, reservation_lines.remains_id, remains.good_id, remains.storage_id, reservation_lines.count FROM reservation_lines JOIN remains ON remains.id = reservation_lines.remains_id WHERE reservation_lines.reservation_id = ? ORDER BY remains.id FOR UPDATE"
+		linesColumns := []string{"id", "remains_id", "good_id", "storage_id", "count"}
+		remainsStr := "UPDATE remains SET count = count - ?, reserved = reserved - ? WHERE id = ?"
+		lineStr := "UPDATE reservation_lines SET count = count - ? WHERE id = ?"
+		shipmentStr := "INSERT INTO shipments (reservation_id, created_at) VALUES (?, ?)"
+		shipmentLineStr := "INSERT INTO shipment_lines (shipment_id, remains_id, count) VALUES (?, ?, ?)"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			want    reservations.Shipment
+			wantErr error
+		}{
+			{
+				name: "ship whole reservation",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(linesStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("1,1,4,1,10\n2,3,4,2,5"))
+					mock.ExpectExec(remainsStr).WithArgs(10, 10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(lineStr).WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeShip, -10, -10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(remainsStr).WithArgs(5, 5, 3).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(lineStr).WithArgs(5, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 2, movements.TypeShip, -5, -5).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(shipmentStr).WithArgs(7, sqlmock.AnyArg()).WillReturnId(3)
+					mock.ExpectExec(shipmentLineStr).WithArgs(3, 1, 10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(shipmentLineStr).WithArgs(3, 3, 5).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectExec("UPDATE reservations SET status = ? WHERE id = ?").WithArgs("shipped", 7).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 7, 0},
+				want: reservations.Shipment{
+					ID:            3,
+					ReservationID: 7,
+					UniqCode:      1,
+					Lines:         []reservations.Line{{StorageId: 1, Count: 10}, {StorageId: 2, Count: 5}},
+				},
+			}, {
+				name: "ship part of reservation",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(linesStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("1,1,4,1,10\n2,3,4,2,5"))
+					mock.ExpectExec(remainsStr).WithArgs(4, 4, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(lineStr).WithArgs(4, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeShip, -4, -4).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(shipmentStr).WithArgs(7, sqlmock.AnyArg()).WillReturnId(3)
+					mock.ExpectExec(shipmentLineStr).WithArgs(3, 1, 4).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 7, 4},
+				want: reservations.Shipment{
+					ID:            3,
+					ReservationID: 7,
+					UniqCode:      1,
+					Lines:         []reservations.Line{{StorageId: 1, Count: 4}},
+				},
+			}, {
+				name: "more than reserved",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(linesStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("1,1,4,1,10\n2,3,4,2,0"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 7, 11},
+				want:    reservations.Shipment{ReservationID: 7, UniqCode: 1},
+				wantErr: ErrExceedsReserved,
+			}, {
+				name: "reservation is not active",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "released", created, nil))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 7, 1},
+				want:    reservations.Shipment{ReservationID: 7, UniqCode: 1},
+				wantErr: ErrReservationClosed,
+			}, {
+				name: "err while begin transaction",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 7, 1},
+				want:    reservations.Shipment{ReservationID: 7},
+				wantErr: errors.New("test"),
+			}, {
+				name: "err in update remains",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(linesStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("1,1,4,1,10"))
+					mock.ExpectExec(remainsStr).WithArgs(10, 10, 1).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 7, 10},
+				want:    reservations.Shipment{ReservationID: 7, UniqCode: 1},
+				wantErr: errors.New("test"),
+			}, {
+				name: "err in create shipment",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(linesStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("1,1,4,1,10"))
+					mock.ExpectExec(remainsStr).WithArgs(10, 10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(lineStr).WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeShip, -10, -10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(shipmentStr).WithArgs(7, sqlmock.AnyArg()).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 7, 10},
+				want:    reservations.Shipment{ReservationID: 7, UniqCode: 1},
+				wantErr: errors.New("test"),
+			}, {
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(reservationStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(reservationColumns).AddRow(1, "active", created, nil))
+					mock.ExpectQuery(linesStr).WithArgs(7).WillReturnRows(sqlmock.NewRows(linesColumns).FromCSVString("1,1,4,1,10"))
+					mock.ExpectExec(remainsStr).WithArgs(10, 10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(lineStr).WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 1, movements.TypeShip, -10, -10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectInsert(shipmentStr).WithArgs(7, sqlmock.AnyArg()).WillReturnId(3)
+					mock.ExpectExec(shipmentLineStr).WithArgs(3, 1, 10).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec("UPDATE reservations SET status = ? WHERE id = ?").WithArgs("shipped", 7).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 7, 10},
+				want:    reservations.Shipment{ReservationID: 7, UniqCode: 1},
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				got, err := d.ShipGood(tt.args.ctx, tt.args.reservationId, tt.args.count)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("ShipGood() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Is(tt.wantErr, ErrExceedsReserved) || errors.Is(tt.wantErr, ErrReservationClosed) {
+					if !errors.Is(err, tt.wantErr) {
+						t.Errorf("ShipGood() error = %v, want %v", err, tt.wantErr)
+					}
+				}
+				if err == nil && got.CreatedAt.IsZero() {
+					t.Errorf("ShipGood() got empty created_at")
+				}
+				got.CreatedAt = time.Time{}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ShipGood() got = %v, want %v", got, tt.want)
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("ShipGood() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
+}
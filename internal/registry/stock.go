@@ -0,0 +1,236 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/movements"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ReceiveGood puts count of goods on the storage, creating the remains note for this good/storage pair if needed.
+func (d *Database) ReceiveGood(ctx context.Context, uniqCode int, storageId int, count int) error {
+	if count <= 0 {
+		return fmt.Errorf("can't receive %d goods, count must be positive: %w", count, ErrInvalidArgument)
+	}
+	return d.inTx(ctx, "receive", func(tx runner) error {
+		goodId, err := goodIdByUniqCode(ctx, tx, uniqCode)
+		if err != nil {
+			return err
+		}
+		if _, err = storageAvailable(ctx, tx, storageId); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO remains (good_id, storage_id, count, reserved) VALUES (?, ?, ?, 0) 
+			%s count = remains.count + ?`, tx.dialect.upsert("good_id, storage_id")), goodId, storageId, count, count)
+		if err != nil {
+			return fmt.Errorf("can't receive %d goods of %d on storage %d: %w", count, uniqCode, storageId, err)
+		}
+		return recordMovement(ctx, tx, goodId, movements.Movement{
+			UniqCode:   uniqCode,
+			StorageId:  storageId,
+			Type:       movements.TypeReceive,
+			CountDelta: count,
+		})
+	})
+}
+
+// TransferStock moves count of free (not reserved) goods from one storage to another.
+// The destination remains note is created if needed, the destination storage must be available.
+func (d *Database) TransferStock(ctx context.Context, uniqCode int, fromStorageId int, toStorageId int, count int) error {
+	if count <= 0 {
+		return fmt.Errorf("can't transfer %d goods, count must be positive: %w", count, ErrInvalidArgument)
+	}
+	if fromStorageId == toStorageId {
+		return fmt.Errorf("can't transfer goods from storage %d to itself: %w", fromStorageId, ErrInvalidArgument)
+	}
+	return d.inTx(ctx, "transfer", func(tx runner) error {
+		return transferStock(ctx, tx, uniqCode, fromStorageId, toStorageId, count)
+	})
+}
+
+func transferStock(ctx context.Context, tx runner, uniqCode int, fromStorageId int, toStorageId int, count int) error {
+	goodId, err := goodIdByUniqCode(ctx, tx, uniqCode)
+	if err != nil {
+		return err
+	}
+	if _, err = storageAvailable(ctx, tx, fromStorageId); err != nil {
+		return err
+	}
+	available, err := storageAvailable(ctx, tx, toStorageId)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return fmt.Errorf("can't transfer goods to storage %d: %w", toStorageId, ErrStorageUnavailable)
+	}
+	var remainsId, free int
+	err = tx.QueryRowContext(ctx, "SELECT id, count - reserved FROM remains WHERE good_id = ? AND storage_id = ? FOR UPDATE",
+		goodId, fromStorageId).Scan(&remainsId, &free)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("can't get remains of %d on storage %d: %w", uniqCode, fromStorageId, err)
+	}
+	if free < count {
+		return fmt.Errorf("can't transfer %d goods of %d from storage %d, only %d are free: %w",
+			count, uniqCode, fromStorageId, free, ErrInsufficientStock)
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE remains SET count = count - ? WHERE id = ?", count, remainsId); err != nil {
+		return fmt.Errorf("can't take %d goods of %d from storage %d: %w", count, uniqCode, fromStorageId, err)
+	}
+	err = recordMovement(ctx, tx, goodId, movements.Movement{
+		UniqCode:   uniqCode,
+		StorageId:  fromStorageId,
+		Type:       movements.TypeTransferOut,
+		CountDelta: -count,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO remains (good_id, storage_id, count, reserved) VALUES (?, ?, ?, 0) 
+		%s count = remains.count + ?`, tx.dialect.upsert("good_id, storage_id")), goodId, toStorageId, count, count)
+	if err != nil {
+		return fmt.Errorf("can't put %d goods of %d on storage %d: %w", count, uniqCode, toStorageId, err)
+	}
+	return recordMovement(ctx, tx, goodId, movements.Movement{
+		UniqCode:   uniqCode,
+		StorageId:  toStorageId,
+		Type:       movements.TypeTransferIn,
+		CountDelta: count,
+	})
+}
+
+type remainsNote struct {
+	id        int
+	goodId    int
+	uniqCode  int
+	storageId int
+	count     int
+	reserved  int
+}
+
+// remainsBy returns remains notes matched by the column, locked until the end of the transaction.
+func remainsBy(ctx context.Context, tx runner, column string, value int) ([]remainsNote, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT 
+			remains.id, 
+			remains.good_id, 
+			goods.uniq_code, 
+			remains.storage_id, 
+			remains.count, 
+			remains.reserved 
+		FROM remains 
+		JOIN goods ON goods.id = remains.good_id 
+		WHERE %s = ? 
+		FOR UPDATE OF remains`, column), value)
+	if err != nil {
+		return nil, fmt.Errorf("can't request remains by %s %d: %w", column, value, err)
+	}
+	defer rows.Close()
+	var notes []remainsNote
+	for rows.Next() {
+		note := remainsNote{}
+		if err = rows.Scan(&note.id, &note.goodId, &note.uniqCode, &note.storageId, &note.count, &note.reserved); err != nil {
+			return nil, fmt.Errorf("can't scan remains by %s %d: %w", column, value, err)
+		}
+		notes = append(notes, note)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when try get remains by %s %d: %w", column, value, err)
+	}
+	return notes, nil
+}
+
+// referencedRemains returns ids of the storage remains notes that reservation or shipment lines point to.
+func referencedRemains(ctx context.Context, tx runner, storageId int) (map[int]bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT remains.id FROM remains 
+		WHERE remains.storage_id = ? AND (
+			EXISTS (SELECT 1 FROM reservation_lines WHERE reservation_lines.remains_id = remains.id) 
+			OR EXISTS (SELECT 1 FROM shipment_lines WHERE shipment_lines.remains_id = remains.id))`, storageId)
+	if err != nil {
+		return nil, fmt.Errorf("can't request referenced remains of storage %d: %w", storageId, err)
+	}
+	defer rows.Close()
+	referenced := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("can't scan referenced remains of storage %d: %w", storageId, err)
+		}
+		referenced[id] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when try get referenced remains of storage %d: %w", storageId, err)
+	}
+	return referenced, nil
+}
+
+// migrateRemains moves the whole remains note to another storage: the stock, the reserved part
+// and the reservation and shipment lines pointing to it. The note itself is deleted.
+func migrateRemains(ctx context.Context, tx runner, note remainsNote, storageId int) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO remains (good_id, storage_id, count, reserved) VALUES (?, ?, ?, ?) 
+		%s count = remains.count + ?, reserved = remains.reserved + ?`, tx.dialect.upsert("good_id, storage_id")),
+		note.goodId, storageId, note.count, note.reserved, note.count, note.reserved)
+	if err != nil {
+		return fmt.Errorf("can't put goods of %d on storage %d: %w", note.uniqCode, storageId, err)
+	}
+	var remainsId int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM remains WHERE good_id = ? AND storage_id = ?",
+		note.goodId, storageId).Scan(&remainsId)
+	if err != nil {
+		return fmt.Errorf("can't get remains of %d on storage %d: %w", note.uniqCode, storageId, err)
+	}
+	for _, table := range []string{"reservation_lines", "shipment_lines"} {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET remains_id = ? WHERE remains_id = ?", table),
+			remainsId, note.id)
+		if err != nil {
+			return fmt.Errorf("can't move %s of remains note %d: %w", table, note.id, err)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM remains WHERE id = ?", note.id); err != nil {
+		return fmt.Errorf("can't delete remains note with id %d: %w", note.id, err)
+	}
+	if note.count == 0 && note.reserved == 0 {
+		return nil
+	}
+	err = recordMovement(ctx, tx, note.goodId, movements.Movement{
+		UniqCode:      note.uniqCode,
+		StorageId:     note.storageId,
+		Type:          movements.TypeTransferOut,
+		CountDelta:    -note.count,
+		ReservedDelta: -note.reserved,
+	})
+	if err != nil {
+		return err
+	}
+	return recordMovement(ctx, tx, note.goodId, movements.Movement{
+		UniqCode:      note.uniqCode,
+		StorageId:     storageId,
+		Type:          movements.TypeTransferIn,
+		CountDelta:    note.count,
+		ReservedDelta: note.reserved,
+	})
+}
+
+// goodIdByUniqCode finds the good that isn't deleted, the good row is share locked so GoodDelete waits for the transaction.
+func goodIdByUniqCode(ctx context.Context, tx runner, uniqCode int) (int, error) {
+	var id int
+	if err := tx.QueryRowContext(ctx, "SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE", uniqCode).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("can't find good with uniq_code %d: %w", uniqCode, ErrGoodNotFound)
+		}
+		return 0, fmt.Errorf("can't find good with uniq_code %d: %w", uniqCode, err)
+	}
+	return id, nil
+}
+
+// storageAvailable checks that the storage exists and reports whether it is available,
+// the storage row is share locked until the end of the transaction.
+func storageAvailable(ctx context.Context, tx runner, storageId int) (bool, error) {
+	var available bool
+	if err := tx.QueryRowContext(ctx, "SELECT available FROM storages WHERE id = ? FOR SHARE", storageId).Scan(&available); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("can't find storage with id %d: %w", storageId, ErrStorageNotFound)
+		}
+		return false, fmt.Errorf("can't find storage with id %d: %w", storageId, err)
+	}
+	return available, nil
+}
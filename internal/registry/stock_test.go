@@ -0,0 +1,326 @@
+package registry
+
+import (
+	"LamodaTest/internal/entity/movements"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+)
+
+func TestDatabase_ReceiveGood(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx       context.Context
+			uniqCode  int
+			storageId int
+			count     int
+		}
+		goodStr := "SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE"
+		storageStr := "SELECT available FROM storages WHERE id = ? FOR SHARE"
+		sqlStr := "INSERT INTO remains (good_id, storage_id, count, reserved) VALUES (?, ?, ?, 0) ON DUPLICATE KEY UPDATE count = remains.count + ?"
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("4"))
+					mock.ExpectQuery(storageStr).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"available"}).FromCSVString("1"))
+					mock.ExpectExec(sqlStr).WithArgs(4, 3, 10, 10).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 3, movements.TypeReceive, 10, 0).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 1, 3, 10},
+			}, {
+				name: "not positive count",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 3, 0},
+				wantErr: errors.New("count must be positive"),
+			}, {
+				name: "good not found",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 3, 10},
+				wantErr: ErrGoodNotFound,
+			}, {
+				name: "storage not found",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("4"))
+					mock.ExpectQuery(storageStr).WithArgs(3).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 3, 10},
+				wantErr: ErrStorageNotFound,
+			}, {
+				name: "err while begin transaction",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 3, 10},
+				wantErr: errors.New("test"),
+			}, {
+				name: "err in upsert",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("4"))
+					mock.ExpectQuery(storageStr).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"available"}).FromCSVString("1"))
+					mock.ExpectExec(sqlStr).WithArgs(4, 3, 10, 10).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 3, 10},
+				wantErr: errors.New("test"),
+			}, {
+				name: "transaction commit error",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("4"))
+					mock.ExpectQuery(storageStr).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"available"}).FromCSVString("1"))
+					mock.ExpectExec(sqlStr).WithArgs(4, 3, 10, 10).WillReturnResult(sqlmock.NewResult(1, 1))
+					expectMovement(mock, 4, 1, 3, movements.TypeReceive, 10, 0).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit().WillReturnError(errors.New("test"))
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 3, 10},
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				err := d.ReceiveGood(tt.args.ctx, tt.args.uniqCode, tt.args.storageId, tt.args.count)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("ReceiveGood() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Is(tt.wantErr, ErrGoodNotFound) || errors.Is(tt.wantErr, ErrStorageNotFound) {
+					if !errors.Is(err, tt.wantErr) {
+						t.Errorf("ReceiveGood() error = %v, want %v", err, tt.wantErr)
+					}
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("ReceiveGood() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_TransferStock(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect Dialect) {
+		type fields struct {
+			conn *sql.DB
+			mock sqlmock.Sqlmock
+		}
+		type args struct {
+			ctx      context.Context
+			uniqCode int
+			from     int
+			to       int
+			count    int
+		}
+		goodStr := "SELECT id from goods where uniq_code = ? AND deleted_at IS NULL FOR SHARE"
+		storageStr := "SELECT available FROM storages WHERE id = ? FOR SHARE"
+		remainsStr := "SELECT id, count - reserved FROM remains WHERE good_id = ? AND storage_id = ? FOR UPDATE"
+		takeStr := "UPDATE remains SET count = count - ? WHERE id = ?"
+		putStr := "INSERT INTO remains (good_id, storage_id, count, reserved) VALUES (?, ?, ?, 0) ON DUPLICATE KEY UPDATE count = remains.count + ?"
+		prepare := func(mock sqlmock.Sqlmock, toAvailable string) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("4"))
+			mock.ExpectQuery(storageStr).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"available"}).FromCSVString("1"))
+			mock.ExpectQuery(storageStr).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"available"}).FromCSVString(toAvailable))
+		}
+		tests := []struct {
+			name    string
+			fields  fields
+			args    args
+			wantErr error
+		}{
+			{
+				name: "normal",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					prepare(mock, "1")
+					mock.ExpectQuery(remainsStr).WithArgs(4, 2).WillReturnRows(sqlmock.NewRows([]string{"id", "free"}).FromCSVString("7,10"))
+					mock.ExpectExec(takeStr).WithArgs(6, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+					expectMovement(mock, 4, 1, 2, movements.TypeTransferOut, -6, 0).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(putStr).WithArgs(4, 3, 6, 6).WillReturnResult(sqlmock.NewResult(8, 1))
+					expectMovement(mock, 4, 1, 3, movements.TypeTransferIn, 6, 0).WillReturnResult(sqlmock.NewResult(2, 1))
+					mock.ExpectCommit()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args: args{context.TODO(), 1, 2, 3, 6},
+			}, {
+				name: "same storage",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 2, 2, 6},
+				wantErr: errors.New("can't transfer goods from storage 2 to itself"),
+			}, {
+				name: "not positive count",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 2, 3, -1},
+				wantErr: errors.New("count must be positive"),
+			}, {
+				name: "destination storage unavailable",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					prepare(mock, "0")
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 2, 3, 6},
+				wantErr: ErrStorageUnavailable,
+			}, {
+				name: "source storage not found",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					mock.ExpectBegin()
+					mock.ExpectQuery(goodStr).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("4"))
+					mock.ExpectQuery(storageStr).WithArgs(2).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 2, 3, 6},
+				wantErr: ErrStorageNotFound,
+			}, {
+				name: "reserved goods can't be transferred",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					prepare(mock, "1")
+					mock.ExpectQuery(remainsStr).WithArgs(4, 2).WillReturnRows(sqlmock.NewRows([]string{"id", "free"}).FromCSVString("7,5"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 2, 3, 6},
+				wantErr: ErrInsufficientStock,
+			}, {
+				name: "nothing on source storage",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					prepare(mock, "1")
+					mock.ExpectQuery(remainsStr).WithArgs(4, 2).WillReturnError(sql.ErrNoRows)
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 2, 3, 6},
+				wantErr: ErrInsufficientStock,
+			}, {
+				name: "err in upsert",
+				fields: func() fields {
+					db, mock, _ := newDialectMock(dialect)
+					prepare(mock, "1")
+					mock.ExpectQuery(remainsStr).WithArgs(4, 2).WillReturnRows(sqlmock.NewRows([]string{"id", "free"}).FromCSVString("7,10"))
+					mock.ExpectExec(takeStr).WithArgs(6, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+					expectMovement(mock, 4, 1, 2, movements.TypeTransferOut, -6, 0).WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(putStr).WithArgs(4, 3, 6, 6).WillReturnError(errors.New("test"))
+					mock.ExpectRollback()
+					return fields{
+						conn: db,
+						mock: mock,
+					}
+				}(),
+				args:    args{context.TODO(), 1, 2, 3, 6},
+				wantErr: errors.New("test"),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d := &Database{
+					conn:    tt.fields.conn,
+					dialect: dialect,
+				}
+				err := d.TransferStock(tt.args.ctx, tt.args.uniqCode, tt.args.from, tt.args.to, tt.args.count)
+				if (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("TransferStock() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				for _, sentinel := range []error{ErrStorageNotFound, ErrStorageUnavailable, ErrInsufficientStock} {
+					if errors.Is(tt.wantErr, sentinel) && !errors.Is(err, sentinel) {
+						t.Errorf("TransferStock() error = %v, want %v", err, tt.wantErr)
+					}
+				}
+				if err = tt.fields.mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("TransferStock() unmet expectations: %s", err)
+				}
+			})
+		}
+	})
+}
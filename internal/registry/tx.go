@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy bounds the retries of transactions failed by a deadlock, a lock wait timeout
+// or, on PostgreSQL, a serialization failure.
+type RetryPolicy struct {
+	Attempts  int           // all attempts including the first one
+	BaseDelay time.Duration // backoff ceiling before the second attempt, doubled for every next one
+	MaxDelay  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{Attempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 500 * time.Millisecond}
+
+// Transaction retry metrics by transaction name, served with the other expvar metrics.
+var (
+	txRetries   = expvar.NewMap("registry_tx_retries")
+	txExhausted = expvar.NewMap("registry_tx_exhausted")
+)
+
+func (d *Database) retryable(err error) bool {
+	return d.db().dialect.retryable(err)
+}
+
+// backoff returns a random delay between half and the whole ceiling of the attempt, so transactions
+// that collided once don't collide again on the retry.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		ceiling = p.BaseDelay << shift
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return ceiling/2 + rand.N(ceiling/2+1)
+}
+
+// inTx runs fn in a transaction of the dialect isolation level and commits it. When the transaction fails
+// with an error the dialect reports as retryable it is run again from the start, so fn must not keep state
+// between calls.
+func (d *Database) inTx(ctx context.Context, name string, fn func(tx runner) error) error {
+	for attempt := 1; ; attempt++ {
+		err := d.runTx(ctx, name, fn)
+		if err == nil || !d.retryable(err) {
+			return err
+		}
+		log := d.log.WithFields(logrus.Fields{"tx": name, "attempt": attempt})
+		if attempt >= d.retry.Attempts {
+			txExhausted.Add(name, 1)
+			log.Errorf("%s transaction failed after %d attempts: %s", name, attempt, err.Error())
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		delay := d.retry.backoff(attempt)
+		txRetries.Add(name, 1)
+		log.Warnf("retrying %s transaction in %s: %s", name, delay, err.Error())
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (d *Database) runTx(ctx context.Context, name string, fn func(tx runner) error) error {
+	tx, err := d.conn.BeginTx(ctx, &sql.TxOptions{Isolation: d.db().dialect.isolation()})
+	if err != nil {
+		return fmt.Errorf("can't init transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err = fn(d.runner(tx)); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit %s transaction: %w", name, err)
+	}
+	return nil
+}
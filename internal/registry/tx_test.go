@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"testing"
+	"time"
+)
+
+func TestDatabase_inTx(t *testing.T) {
+	updateStr := "UPDATE remains SET reserved = reserved + ? WHERE id = ?"
+	deadlock := &mysql.MySQLError{Number: errDeadlock, Message: "Deadlock found when trying to get lock"}
+	lockWait := &mysql.MySQLError{Number: errLockWaitTimeout, Message: "Lock wait timeout exceeded"}
+	tests := []struct {
+		name          string
+		expect        func(mock sqlmock.Sqlmock)
+		wantCalls     int
+		wantRetries   int64
+		wantExhausted int64
+		wantErr       error
+	}{
+		{
+			name: "no conflict",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCalls: 1,
+		}, {
+			name: "deadlock retried",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnError(deadlock)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCalls:   2,
+			wantRetries: 1,
+		}, {
+			name: "lock wait timeout retried",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnError(lockWait)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnError(deadlock)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCalls:   3,
+			wantRetries: 2,
+		}, {
+			name: "deadlock on commit retried",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(deadlock)
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCalls:   2,
+			wantRetries: 1,
+		}, {
+			name: "attempts exhausted",
+			expect: func(mock sqlmock.Sqlmock) {
+				for i := 0; i < 3; i++ {
+					mock.ExpectBegin()
+					mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnError(deadlock)
+					mock.ExpectRollback()
+				}
+			},
+			wantCalls:     3,
+			wantRetries:   2,
+			wantExhausted: 1,
+			wantErr:       deadlock,
+		}, {
+			name: "other error not retried",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateStr).WithArgs(1, 1).WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+				mock.ExpectRollback()
+			},
+			wantCalls: 1,
+			wantErr:   errors.New("Duplicate entry"),
+		}, {
+			name: "err while begin transaction",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("test"))
+			},
+			wantErr: errors.New("test"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			tt.expect(mock)
+			d := New(db, WithRetryPolicy(RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}))
+			retries, exhausted := txMetric(txRetries, tt.name), txMetric(txExhausted, tt.name)
+
+			calls := 0
+			err := d.inTx(context.TODO(), tt.name, func(tx runner) error {
+				calls++
+				_, err := tx.ExecContext(context.TODO(), updateStr, 1, 1)
+				return err
+			})
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("inTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(tt.wantErr, deadlock) && !errors.Is(err, deadlock) {
+				t.Errorf("inTx() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("inTx() ran the transaction %d times, want %d", calls, tt.wantCalls)
+			}
+			if got := txMetric(txRetries, tt.name) - retries; got != tt.wantRetries {
+				t.Errorf("inTx() retries metric = %d, want %d", got, tt.wantRetries)
+			}
+			if got := txMetric(txExhausted, tt.name) - exhausted; got != tt.wantExhausted {
+				t.Errorf("inTx() exhausted metric = %d, want %d", got, tt.wantExhausted)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("inTx() unmet expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestDatabase_inTxCancelled(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	d := New(db, WithRetryPolicy(RetryPolicy{Attempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}))
+	ctx, cancel := context.WithCancel(context.TODO())
+	deadlock := &mysql.MySQLError{Number: errDeadlock}
+
+	err := d.inTx(ctx, "cancelled", func(tx runner) error {
+		cancel()
+		return deadlock
+	})
+	if !errors.Is(err, deadlock) {
+		t.Errorf("inTx() error = %v, want %v", err, deadlock)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("inTx() unmet expectations: %s", err)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{Attempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond}
+	ceilings := []time.Duration{10, 20, 40, 80, 100, 100, 100}
+	for i, ceiling := range ceilings {
+		ceiling *= time.Millisecond
+		for j := 0; j < 100; j++ {
+			if got := policy.backoff(i + 1); got < ceiling/2 || got > ceiling {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", i+1, got, ceiling/2, ceiling)
+			}
+		}
+	}
+	if got := policy.backoff(100); got < policy.MaxDelay/2 || got > policy.MaxDelay {
+		t.Errorf("backoff(100) = %s, want between %s and %s", got, policy.MaxDelay/2, policy.MaxDelay)
+	}
+	if got := (RetryPolicy{}).backoff(1); got != 0 {
+		t.Errorf("backoff() of the zero policy = %s, want 0", got)
+	}
+}
+
+func txMetric(m *expvar.Map, name string) int64 {
+	if v, ok := m.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
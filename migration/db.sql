@@ -26,9 +26,10 @@ CREATE TABLE `goods` (
   `id` int NOT NULL AUTO_INCREMENT,
   `name` varchar(45) DEFAULT NULL,
   `size` varchar(45) DEFAULT NULL,
-  `uniq_code` int DEFAULT NULL,
+  `uniq_code` int NOT NULL,
+  `deleted_at` datetime DEFAULT NULL,
   PRIMARY KEY (`id`),
-  KEY `goods_uniq_code_index` (`uniq_code`)
+  UNIQUE KEY `goods_uniq_code_index` (`uniq_code`)
 ) ENGINE=InnoDB AUTO_INCREMENT=7 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
@@ -38,7 +39,7 @@ CREATE TABLE `goods` (
 
 LOCK TABLES `goods` WRITE;
 /*!40000 ALTER TABLE `goods` DISABLE KEYS */;
-INSERT INTO `goods` VALUES (1,'Test1','L',1),(2,'Test2','XL',2),(6,'TestAddedFromAPI','XS',565);
+INSERT INTO `goods` VALUES (1,'Test1','L',1,NULL),(2,'Test2','XL',2,NULL),(6,'TestAddedFromAPI','XS',565,NULL);
 /*!40000 ALTER TABLE `goods` ENABLE KEYS */;
 UNLOCK TABLES;
 
@@ -53,8 +54,8 @@ CREATE TABLE `remains` (
   `id` int NOT NULL AUTO_INCREMENT,
   `good_id` int NOT NULL,
   `storage_id` int NOT NULL,
-  `count` varchar(45) NOT NULL,
-  `reserved` int DEFAULT NULL,
+  `count` int NOT NULL DEFAULT '0',
+  `reserved` int NOT NULL DEFAULT '0',
   PRIMARY KEY (`id`),
   UNIQUE KEY `idx_remains_good_id_storage_id` (`good_id`,`storage_id`),
   KEY `remains_storages_id_fk` (`storage_id`),
@@ -69,10 +70,108 @@ CREATE TABLE `remains` (
 
 LOCK TABLES `remains` WRITE;
 /*!40000 ALTER TABLE `remains` DISABLE KEYS */;
-INSERT INTO `remains` VALUES (1,1,1,'15',0),(2,2,1,'10',1),(3,1,2,'10',0),(4,1,3,'10',0),(5,2,3,'10',0);
+INSERT INTO `remains` VALUES (1,1,1,15,0),(2,2,1,10,0),(3,1,2,10,0),(4,1,3,10,0),(5,2,3,10,0);
 /*!40000 ALTER TABLE `remains` ENABLE KEYS */;
 UNLOCK TABLES;
 
+--
+-- Table structure for table `reservation_lines`
+--
+
+DROP TABLE IF EXISTS `reservation_lines`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `reservation_lines` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `reservation_id` int NOT NULL,
+  `remains_id` int NOT NULL,
+  `count` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `reservation_lines_reservations_id_fk` (`reservation_id`),
+  KEY `reservation_lines_remains_id_fk` (`remains_id`),
+  CONSTRAINT `reservation_lines_remains_id_fk` FOREIGN KEY (`remains_id`) REFERENCES `remains` (`id`),
+  CONSTRAINT `reservation_lines_reservations_id_fk` FOREIGN KEY (`reservation_id`) REFERENCES `reservations` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `reservations`
+--
+
+DROP TABLE IF EXISTS `reservations`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `reservations` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `uniq_code` int NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'active',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `reservations_status_expires_at_index` (`status`,`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `shipment_lines`
+--
+
+DROP TABLE IF EXISTS `shipment_lines`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `shipment_lines` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `shipment_id` int NOT NULL,
+  `remains_id` int NOT NULL,
+  `count` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `shipment_lines_shipments_id_fk` (`shipment_id`),
+  KEY `shipment_lines_remains_id_fk` (`remains_id`),
+  CONSTRAINT `shipment_lines_remains_id_fk` FOREIGN KEY (`remains_id`) REFERENCES `remains` (`id`),
+  CONSTRAINT `shipment_lines_shipments_id_fk` FOREIGN KEY (`shipment_id`) REFERENCES `shipments` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `shipments`
+--
+
+DROP TABLE IF EXISTS `shipments`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `shipments` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `reservation_id` int NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `shipments_reservations_id_fk` (`reservation_id`),
+  CONSTRAINT `shipments_reservations_id_fk` FOREIGN KEY (`reservation_id`) REFERENCES `reservations` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `stock_movements`
+--
+
+DROP TABLE IF EXISTS `stock_movements`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `stock_movements` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `good_id` int NOT NULL,
+  `uniq_code` int NOT NULL,
+  `storage_id` int NOT NULL,
+  `type` varchar(16) NOT NULL,
+  `count_delta` int NOT NULL DEFAULT '0',
+  `reserved_delta` int NOT NULL DEFAULT '0',
+  `correlation_id` varchar(64) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `stock_movements_uniq_code_id_index` (`uniq_code`,`id`),
+  KEY `stock_movements_correlation_id_index` (`correlation_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `storages`
 --
@@ -84,6 +183,11 @@ CREATE TABLE `storages` (
   `id` int NOT NULL AUTO_INCREMENT,
   `name` varchar(45) DEFAULT NULL,
   `available` tinyint(1) DEFAULT NULL,
+  `address` varchar(255) NOT NULL DEFAULT '',
+  `region` varchar(64) NOT NULL DEFAULT '',
+  `priority` int NOT NULL DEFAULT '0',
+  `capacity` int NOT NULL DEFAULT '0',
+  `tags` json DEFAULT NULL,
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=7 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -94,7 +198,7 @@ CREATE TABLE `storages` (
 
 LOCK TABLES `storages` WRITE;
 /*!40000 ALTER TABLE `storages` DISABLE KEYS */;
-INSERT INTO `storages` VALUES (1,'TestStore',1),(2,'Storage2',0),(3,'Storage3',1),(6,'TestAddedFromAPI',1);
+INSERT INTO `storages` VALUES (1,'TestStore',1,'','',0,0,NULL),(2,'Storage2',0,'','',0,0,NULL),(3,'Storage3',1,'','',0,0,NULL),(6,'TestAddedFromAPI',1,'','',0,0,NULL);
 /*!40000 ALTER TABLE `storages` ENABLE KEYS */;
 UNLOCK TABLES;
 /*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
@@ -0,0 +1,93 @@
+-- PostgreSQL schema of the registry, the counterpart of db.sql.
+-- Names are citext, so LIKE filters and sorting ignore case the way the MySQL collation does.
+
+CREATE EXTENSION IF NOT EXISTS citext;
+
+DROP TABLE IF EXISTS stock_movements, shipment_lines, shipments, reservation_lines, reservations, remains, storages, goods;
+
+CREATE TABLE goods (
+  id serial PRIMARY KEY,
+  name citext DEFAULT NULL CHECK (char_length(name) <= 45),
+  size varchar(45) DEFAULT NULL,
+  uniq_code int NOT NULL,
+  deleted_at timestamptz DEFAULT NULL,
+  CONSTRAINT goods_uniq_code_index UNIQUE (uniq_code)
+);
+
+CREATE TABLE storages (
+  id serial PRIMARY KEY,
+  name citext DEFAULT NULL CHECK (char_length(name) <= 45),
+  available boolean DEFAULT NULL,
+  address varchar(255) NOT NULL DEFAULT '',
+  region varchar(64) NOT NULL DEFAULT '',
+  priority int NOT NULL DEFAULT 0,
+  capacity int NOT NULL DEFAULT 0,
+  tags jsonb DEFAULT NULL
+);
+
+CREATE TABLE remains (
+  id serial PRIMARY KEY,
+  good_id int NOT NULL CONSTRAINT remains_goods_id_fk REFERENCES goods (id),
+  storage_id int NOT NULL CONSTRAINT remains_storages_id_fk REFERENCES storages (id),
+  count int NOT NULL DEFAULT 0,
+  reserved int NOT NULL DEFAULT 0,
+  CONSTRAINT idx_remains_good_id_storage_id UNIQUE (good_id, storage_id)
+);
+CREATE INDEX remains_storages_id_fk ON remains (storage_id);
+
+CREATE TABLE reservations (
+  id serial PRIMARY KEY,
+  uniq_code int NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'active',
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at timestamptz DEFAULT NULL
+);
+CREATE INDEX reservations_status_expires_at_index ON reservations (status, expires_at);
+
+CREATE TABLE reservation_lines (
+  id serial PRIMARY KEY,
+  reservation_id int NOT NULL CONSTRAINT reservation_lines_reservations_id_fk REFERENCES reservations (id),
+  remains_id int NOT NULL CONSTRAINT reservation_lines_remains_id_fk REFERENCES remains (id),
+  count int NOT NULL
+);
+CREATE INDEX reservation_lines_reservations_id_fk ON reservation_lines (reservation_id);
+CREATE INDEX reservation_lines_remains_id_fk ON reservation_lines (remains_id);
+
+CREATE TABLE shipments (
+  id serial PRIMARY KEY,
+  reservation_id int NOT NULL CONSTRAINT shipments_reservations_id_fk REFERENCES reservations (id),
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX shipments_reservations_id_fk ON shipments (reservation_id);
+
+CREATE TABLE shipment_lines (
+  id serial PRIMARY KEY,
+  shipment_id int NOT NULL CONSTRAINT shipment_lines_shipments_id_fk REFERENCES shipments (id),
+  remains_id int NOT NULL CONSTRAINT shipment_lines_remains_id_fk REFERENCES remains (id),
+  count int NOT NULL
+);
+CREATE INDEX shipment_lines_shipments_id_fk ON shipment_lines (shipment_id);
+CREATE INDEX shipment_lines_remains_id_fk ON shipment_lines (remains_id);
+
+CREATE TABLE stock_movements (
+  id bigserial PRIMARY KEY,
+  good_id int NOT NULL,
+  uniq_code int NOT NULL,
+  storage_id int NOT NULL,
+  type varchar(16) NOT NULL,
+  count_delta int NOT NULL DEFAULT 0,
+  reserved_delta int NOT NULL DEFAULT 0,
+  correlation_id varchar(64) NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX stock_movements_uniq_code_id_index ON stock_movements (uniq_code, id);
+CREATE INDEX stock_movements_correlation_id_index ON stock_movements (correlation_id);
+
+INSERT INTO goods VALUES (1,'Test1','L',1,NULL),(2,'Test2','XL',2,NULL),(6,'TestAddedFromAPI','XS',565,NULL);
+INSERT INTO storages VALUES (1,'TestStore',true,'','',0,0,NULL),(2,'Storage2',false,'','',0,0,NULL),(3,'Storage3',true,'','',0,0,NULL),(6,'TestAddedFromAPI',true,'','',0,0,NULL);
+INSERT INTO remains VALUES (1,1,1,15,0),(2,2,1,10,0),(3,1,2,10,0),(4,1,3,10,0),(5,2,3,10,0);
+
+-- rows above are inserted with their ids, the sequences continue after them
+SELECT setval('goods_id_seq', (SELECT max(id) FROM goods));
+SELECT setval('storages_id_seq', (SELECT max(id) FROM storages));
+SELECT setval('remains_id_seq', (SELECT max(id) FROM remains));
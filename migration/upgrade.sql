@@ -0,0 +1,79 @@
+-- Upgrades a MySQL database created from the first db.sql to the schema of the current db.sql.
+-- Repeated uniq_code values must be removed before running it, the unique index can't be built otherwise.
+-- The reserved quantity already in remains has no reservations to release it by, it stays reserved.
+
+ALTER TABLE `goods`
+  MODIFY `uniq_code` int NOT NULL,
+  DROP INDEX `goods_uniq_code_index`,
+  ADD UNIQUE KEY `goods_uniq_code_index` (`uniq_code`),
+  ADD COLUMN `deleted_at` datetime DEFAULT NULL;
+
+ALTER TABLE `storages`
+  ADD COLUMN `address` varchar(255) NOT NULL DEFAULT '',
+  ADD COLUMN `region` varchar(64) NOT NULL DEFAULT '',
+  ADD COLUMN `priority` int NOT NULL DEFAULT '0',
+  ADD COLUMN `capacity` int NOT NULL DEFAULT '0',
+  ADD COLUMN `tags` json DEFAULT NULL;
+
+UPDATE `remains` SET `reserved` = 0 WHERE `reserved` IS NULL;
+ALTER TABLE `remains`
+  MODIFY `count` int NOT NULL DEFAULT '0',
+  MODIFY `reserved` int NOT NULL DEFAULT '0';
+
+CREATE TABLE `reservations` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `uniq_code` int NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'active',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `reservations_status_expires_at_index` (`status`,`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `reservation_lines` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `reservation_id` int NOT NULL,
+  `remains_id` int NOT NULL,
+  `count` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `reservation_lines_reservations_id_fk` (`reservation_id`),
+  KEY `reservation_lines_remains_id_fk` (`remains_id`),
+  CONSTRAINT `reservation_lines_remains_id_fk` FOREIGN KEY (`remains_id`) REFERENCES `remains` (`id`),
+  CONSTRAINT `reservation_lines_reservations_id_fk` FOREIGN KEY (`reservation_id`) REFERENCES `reservations` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `shipments` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `reservation_id` int NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `shipments_reservations_id_fk` (`reservation_id`),
+  CONSTRAINT `shipments_reservations_id_fk` FOREIGN KEY (`reservation_id`) REFERENCES `reservations` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `shipment_lines` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `shipment_id` int NOT NULL,
+  `remains_id` int NOT NULL,
+  `count` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `shipment_lines_shipments_id_fk` (`shipment_id`),
+  KEY `shipment_lines_remains_id_fk` (`remains_id`),
+  CONSTRAINT `shipment_lines_remains_id_fk` FOREIGN KEY (`remains_id`) REFERENCES `remains` (`id`),
+  CONSTRAINT `shipment_lines_shipments_id_fk` FOREIGN KEY (`shipment_id`) REFERENCES `shipments` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `stock_movements` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `good_id` int NOT NULL,
+  `uniq_code` int NOT NULL,
+  `storage_id` int NOT NULL,
+  `type` varchar(16) NOT NULL,
+  `count_delta` int NOT NULL DEFAULT '0',
+  `reserved_delta` int NOT NULL DEFAULT '0',
+  `correlation_id` varchar(64) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `stock_movements_uniq_code_id_index` (`uniq_code`,`id`),
+  KEY `stock_movements_correlation_id_index` (`correlation_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;